@@ -1,6 +1,11 @@
 package err
 
-import "github.com/asim/go-micro/v3/errors"
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"github.com/asim/go-micro/v3/errors"
+)
 
 const (
 	SUCCESS = 0
@@ -9,6 +14,20 @@ const (
 	ERR_UPDATE_PROFILE_FAILED = 100002
 	ERR_DELETE_PROFILE_FAILED = 100003
 	ERR_CREATE_PROFILE_FAILED = 100004
+	ERR_INVALID_ATTRIBUTE     = 100005
+	ERR_PROFILE_NOT_FOUND     = 100006
+	ERR_INVALID_PRIVACY       = 100007
+	ERR_GET_PROFILE_REQUEST   = 100008
+
+	ERR_GET_PROFILE_HISTORY_FAILED  = 100009
+	ERR_GET_PROFILE_HISTORY_REQUEST = 100010
+	ERR_INVALID_PROFILE             = 100011
+	ERR_PROFILE_EXISTS              = 100012
+	ERR_DELETE_PROFILE_REQUEST      = 100013
+	ERR_RESTORE_PROFILE_FAILED      = 100014
+	ERR_USERNAME_TAKEN              = 100015
+	ERR_USERNAME_CHANGE_COOLDOWN    = 100016
+	ERR_CHANGE_USERNAME_FAILED      = 100017
 
 	ERR_EMAIL_IS_REGISTERED = 200001
 	ERR_REGISTER_INTERNAL   = 200002
@@ -19,6 +38,18 @@ const (
 	ERR_PASSWORD_MISMATCH   = 200007
 	ERR_AUTH_FAILED         = 200008
 	ERR_TOKEN_EXPIRED       = 200009
+
+	ERR_INVALID_RELATION           = 300001
+	ERR_RELATION_BLOCKED           = 300002
+	ERR_RELATION_USER_NOT_FOUND    = 300003
+	ERR_FRIEND_REQUEST_NOT_FOUND   = 300004
+	ERR_UPDATE_RELATION_FAILED     = 300005
+	ERR_LIST_RELATIONS_FAILED      = 300006
+	ERR_LIST_RELATIONS_REQUEST     = 300007
+	ERR_GET_RELATION_COUNTS_FAILED = 300008
+
+	ERR_DEADLINE_EXCEEDED  = 900001
+	ERR_SHARD_RANGE_MOVING = 900002
 )
 
 var errMsg = map[int32]string{
@@ -28,6 +59,20 @@ var errMsg = map[int32]string{
 	ERR_UPDATE_PROFILE_FAILED: "Update profile failed.",
 	ERR_DELETE_PROFILE_FAILED: "Delete profile failed.",
 	ERR_CREATE_PROFILE_FAILED: "Create profile failed.",
+	ERR_INVALID_ATTRIBUTE:     "Invalid profile attribute.",
+	ERR_PROFILE_NOT_FOUND:     "Profile not found.",
+	ERR_INVALID_PRIVACY:       "Invalid privacy settings.",
+	ERR_GET_PROFILE_REQUEST:   "Get profile failed, bad request.",
+
+	ERR_GET_PROFILE_HISTORY_FAILED:  "Get profile history failed.",
+	ERR_GET_PROFILE_HISTORY_REQUEST: "Get profile history failed, bad request.",
+	ERR_INVALID_PROFILE:             "Invalid profile.",
+	ERR_PROFILE_EXISTS:              "Create profile failed, profile already exists.",
+	ERR_DELETE_PROFILE_REQUEST:      "Delete profile failed, deletion is not confirmed.",
+	ERR_RESTORE_PROFILE_FAILED:      "Restore profile failed.",
+	ERR_USERNAME_TAKEN:              "Username is taken or reserved by another user.",
+	ERR_USERNAME_CHANGE_COOLDOWN:    "Change username failed, username was changed recently.",
+	ERR_CHANGE_USERNAME_FAILED:      "Change username failed.",
 
 	ERR_EMAIL_IS_REGISTERED: "Register failed, email has been registered.",
 	ERR_REGISTER_INTERNAL:   "Register failed, internal server error.",
@@ -38,16 +83,75 @@ var errMsg = map[int32]string{
 	ERR_LOGIN_REQUEST:       "Login failed, bad request.",
 	ERR_AUTH_FAILED:         "Auth failed, invalid token.",
 	ERR_TOKEN_EXPIRED:       "Auth failed, login status expired",
+
+	ERR_INVALID_RELATION:           "Invalid relation, bad request.",
+	ERR_RELATION_BLOCKED:           "Relation is not allowed, user is blocked.",
+	ERR_RELATION_USER_NOT_FOUND:    "Relation failed, no such user.",
+	ERR_FRIEND_REQUEST_NOT_FOUND:   "Friend request not found.",
+	ERR_UPDATE_RELATION_FAILED:     "Update relation failed.",
+	ERR_LIST_RELATIONS_FAILED:      "List relations failed.",
+	ERR_LIST_RELATIONS_REQUEST:     "List relations failed, bad request.",
+	ERR_GET_RELATION_COUNTS_FAILED: "Get relation counts failed.",
+
+	ERR_DEADLINE_EXCEEDED:  "Request timed out, please try again later.",
+	ERR_SHARD_RANGE_MOVING: "Data of the user is being moved, please try again later.",
 }
 
 func New(code int32) error {
 	return errors.New("", errMsg[code], code)
 }
 
+// NewFromErr returns the error of code, or ERR_DEADLINE_EXCEEDED if err is caused by a deadline,
+// e.g. the timeout of a query or the deadline of the caller.
+func NewFromErr(code int32, err error) error {
+	if stderrors.Is(err, context.DeadlineExceeded) {
+		return New(ERR_DEADLINE_EXCEEDED)
+	}
+	return New(code)
+}
+
 func GetMsg(code int32) string {
 	return errMsg[code]
 }
 
+// Violation describes why a field of request is invalid.
+type Violation struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func (v *Violation) String() string {
+	return v.Field + ": " + v.Reason
+}
+
+type violationDetail struct {
+	Msg        string       `json:"msg"`
+	Violations []*Violation `json:"violations"`
+}
+
+// NewWithViolations returns an error with field-level violations encoded in its detail.
+// Use ParseDetail to get them back from the detail.
+func NewWithViolations(code int32, violations []*Violation) error {
+	detail, err := json.Marshal(&violationDetail{
+		Msg:        errMsg[code],
+		Violations: violations,
+	})
+	if err != nil {
+		return New(code)
+	}
+	return errors.New("", string(detail), code)
+}
+
+// ParseDetail returns the message and violations of an error detail.
+// Violations are nil if the error was not created by NewWithViolations.
+func ParseDetail(detail string) (string, []*Violation) {
+	d := &violationDetail{}
+	if err := json.Unmarshal([]byte(detail), d); err != nil || d.Violations == nil {
+		return detail, nil
+	}
+	return d.Msg, d.Violations
+}
+
 //type Err struct {
 //	Code uint32
 //}
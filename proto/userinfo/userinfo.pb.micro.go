@@ -38,12 +38,29 @@ func NewUserinfoEndpoints() []*api.Endpoint {
 type UserinfoService interface {
 	GetProfile(ctx context.Context, in *GetProfileRequest, opts ...client.CallOption) (*GetProfileResponse, error)
 	DeleteProfile(ctx context.Context, in *DeleteProfileRequest, opts ...client.CallOption) (*DeleteProfileResponse, error)
+	RestoreProfile(ctx context.Context, in *RestoreProfileRequest, opts ...client.CallOption) (*RestoreProfileResponse, error)
 	CreateProfile(ctx context.Context, in *CreateProfileRequest, opts ...client.CallOption) (*CreateProfileResponse, error)
 	UpdateProfile(ctx context.Context, in *UpdateProfileRequest, opts ...client.CallOption) (*UpdateProfileResponse, error)
+	GetPublicProfile(ctx context.Context, in *GetPublicProfileRequest, opts ...client.CallOption) (*GetPublicProfileResponse, error)
+	ChangeUsername(ctx context.Context, in *ChangeUsernameRequest, opts ...client.CallOption) (*ChangeUsernameResponse, error)
+	GetProfileHistory(ctx context.Context, in *GetProfileHistoryRequest, opts ...client.CallOption) (*GetProfileHistoryResponse, error)
+	GetProfileAt(ctx context.Context, in *GetProfileAtRequest, opts ...client.CallOption) (*GetProfileAtResponse, error)
 	Register(ctx context.Context, in *RegisterRequest, opts ...client.CallOption) (*RegisterResponse, error)
 	Login(ctx context.Context, in *LoginRequest, opts ...client.CallOption) (*LoginResponse, error)
 	Logout(ctx context.Context, in *LogoutRequest, opts ...client.CallOption) (*LogoutResponse, error)
 	Authenticate(ctx context.Context, in *AuthRequest, opts ...client.CallOption) (*AuthResponse, error)
+	Follow(ctx context.Context, in *RelationRequest, opts ...client.CallOption) (*RelationResponse, error)
+	Unfollow(ctx context.Context, in *RelationRequest, opts ...client.CallOption) (*RelationResponse, error)
+	RequestFriend(ctx context.Context, in *RelationRequest, opts ...client.CallOption) (*RelationResponse, error)
+	AcceptFriend(ctx context.Context, in *RelationRequest, opts ...client.CallOption) (*RelationResponse, error)
+	RejectFriend(ctx context.Context, in *RelationRequest, opts ...client.CallOption) (*RelationResponse, error)
+	Block(ctx context.Context, in *RelationRequest, opts ...client.CallOption) (*RelationResponse, error)
+	Unblock(ctx context.Context, in *RelationRequest, opts ...client.CallOption) (*RelationResponse, error)
+	ListFollowers(ctx context.Context, in *ListRelationsRequest, opts ...client.CallOption) (*ListRelationsResponse, error)
+	ListFollowing(ctx context.Context, in *ListRelationsRequest, opts ...client.CallOption) (*ListRelationsResponse, error)
+	ListFriends(ctx context.Context, in *ListRelationsRequest, opts ...client.CallOption) (*ListRelationsResponse, error)
+	ListFriendRequests(ctx context.Context, in *ListRelationsRequest, opts ...client.CallOption) (*ListRelationsResponse, error)
+	GetRelationCounts(ctx context.Context, in *GetRelationCountsRequest, opts ...client.CallOption) (*GetRelationCountsResponse, error)
 }
 
 type userinfoService struct {
@@ -78,6 +95,16 @@ func (c *userinfoService) DeleteProfile(ctx context.Context, in *DeleteProfileRe
 	return out, nil
 }
 
+func (c *userinfoService) RestoreProfile(ctx context.Context, in *RestoreProfileRequest, opts ...client.CallOption) (*RestoreProfileResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.RestoreProfile", in)
+	out := new(RestoreProfileResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *userinfoService) CreateProfile(ctx context.Context, in *CreateProfileRequest, opts ...client.CallOption) (*CreateProfileResponse, error) {
 	req := c.c.NewRequest(c.name, "Userinfo.CreateProfile", in)
 	out := new(CreateProfileResponse)
@@ -98,6 +125,46 @@ func (c *userinfoService) UpdateProfile(ctx context.Context, in *UpdateProfileRe
 	return out, nil
 }
 
+func (c *userinfoService) GetPublicProfile(ctx context.Context, in *GetPublicProfileRequest, opts ...client.CallOption) (*GetPublicProfileResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.GetPublicProfile", in)
+	out := new(GetPublicProfileResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userinfoService) ChangeUsername(ctx context.Context, in *ChangeUsernameRequest, opts ...client.CallOption) (*ChangeUsernameResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.ChangeUsername", in)
+	out := new(ChangeUsernameResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userinfoService) GetProfileHistory(ctx context.Context, in *GetProfileHistoryRequest, opts ...client.CallOption) (*GetProfileHistoryResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.GetProfileHistory", in)
+	out := new(GetProfileHistoryResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userinfoService) GetProfileAt(ctx context.Context, in *GetProfileAtRequest, opts ...client.CallOption) (*GetProfileAtResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.GetProfileAt", in)
+	out := new(GetProfileAtResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *userinfoService) Register(ctx context.Context, in *RegisterRequest, opts ...client.CallOption) (*RegisterResponse, error) {
 	req := c.c.NewRequest(c.name, "Userinfo.Register", in)
 	out := new(RegisterResponse)
@@ -138,29 +205,183 @@ func (c *userinfoService) Authenticate(ctx context.Context, in *AuthRequest, opt
 	return out, nil
 }
 
+func (c *userinfoService) Follow(ctx context.Context, in *RelationRequest, opts ...client.CallOption) (*RelationResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.Follow", in)
+	out := new(RelationResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userinfoService) Unfollow(ctx context.Context, in *RelationRequest, opts ...client.CallOption) (*RelationResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.Unfollow", in)
+	out := new(RelationResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userinfoService) RequestFriend(ctx context.Context, in *RelationRequest, opts ...client.CallOption) (*RelationResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.RequestFriend", in)
+	out := new(RelationResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userinfoService) AcceptFriend(ctx context.Context, in *RelationRequest, opts ...client.CallOption) (*RelationResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.AcceptFriend", in)
+	out := new(RelationResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userinfoService) RejectFriend(ctx context.Context, in *RelationRequest, opts ...client.CallOption) (*RelationResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.RejectFriend", in)
+	out := new(RelationResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userinfoService) Block(ctx context.Context, in *RelationRequest, opts ...client.CallOption) (*RelationResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.Block", in)
+	out := new(RelationResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userinfoService) Unblock(ctx context.Context, in *RelationRequest, opts ...client.CallOption) (*RelationResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.Unblock", in)
+	out := new(RelationResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userinfoService) ListFollowers(ctx context.Context, in *ListRelationsRequest, opts ...client.CallOption) (*ListRelationsResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.ListFollowers", in)
+	out := new(ListRelationsResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userinfoService) ListFollowing(ctx context.Context, in *ListRelationsRequest, opts ...client.CallOption) (*ListRelationsResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.ListFollowing", in)
+	out := new(ListRelationsResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userinfoService) ListFriends(ctx context.Context, in *ListRelationsRequest, opts ...client.CallOption) (*ListRelationsResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.ListFriends", in)
+	out := new(ListRelationsResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userinfoService) ListFriendRequests(ctx context.Context, in *ListRelationsRequest, opts ...client.CallOption) (*ListRelationsResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.ListFriendRequests", in)
+	out := new(ListRelationsResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userinfoService) GetRelationCounts(ctx context.Context, in *GetRelationCountsRequest, opts ...client.CallOption) (*GetRelationCountsResponse, error) {
+	req := c.c.NewRequest(c.name, "Userinfo.GetRelationCounts", in)
+	out := new(GetRelationCountsResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Userinfo service
 
 type UserinfoHandler interface {
 	GetProfile(context.Context, *GetProfileRequest, *GetProfileResponse) error
 	DeleteProfile(context.Context, *DeleteProfileRequest, *DeleteProfileResponse) error
+	RestoreProfile(context.Context, *RestoreProfileRequest, *RestoreProfileResponse) error
 	CreateProfile(context.Context, *CreateProfileRequest, *CreateProfileResponse) error
 	UpdateProfile(context.Context, *UpdateProfileRequest, *UpdateProfileResponse) error
+	GetPublicProfile(context.Context, *GetPublicProfileRequest, *GetPublicProfileResponse) error
+	ChangeUsername(context.Context, *ChangeUsernameRequest, *ChangeUsernameResponse) error
+	GetProfileHistory(context.Context, *GetProfileHistoryRequest, *GetProfileHistoryResponse) error
+	GetProfileAt(context.Context, *GetProfileAtRequest, *GetProfileAtResponse) error
 	Register(context.Context, *RegisterRequest, *RegisterResponse) error
 	Login(context.Context, *LoginRequest, *LoginResponse) error
 	Logout(context.Context, *LogoutRequest, *LogoutResponse) error
 	Authenticate(context.Context, *AuthRequest, *AuthResponse) error
+	Follow(context.Context, *RelationRequest, *RelationResponse) error
+	Unfollow(context.Context, *RelationRequest, *RelationResponse) error
+	RequestFriend(context.Context, *RelationRequest, *RelationResponse) error
+	AcceptFriend(context.Context, *RelationRequest, *RelationResponse) error
+	RejectFriend(context.Context, *RelationRequest, *RelationResponse) error
+	Block(context.Context, *RelationRequest, *RelationResponse) error
+	Unblock(context.Context, *RelationRequest, *RelationResponse) error
+	ListFollowers(context.Context, *ListRelationsRequest, *ListRelationsResponse) error
+	ListFollowing(context.Context, *ListRelationsRequest, *ListRelationsResponse) error
+	ListFriends(context.Context, *ListRelationsRequest, *ListRelationsResponse) error
+	ListFriendRequests(context.Context, *ListRelationsRequest, *ListRelationsResponse) error
+	GetRelationCounts(context.Context, *GetRelationCountsRequest, *GetRelationCountsResponse) error
 }
 
 func RegisterUserinfoHandler(s server.Server, hdlr UserinfoHandler, opts ...server.HandlerOption) error {
 	type userinfo interface {
 		GetProfile(ctx context.Context, in *GetProfileRequest, out *GetProfileResponse) error
 		DeleteProfile(ctx context.Context, in *DeleteProfileRequest, out *DeleteProfileResponse) error
+		RestoreProfile(ctx context.Context, in *RestoreProfileRequest, out *RestoreProfileResponse) error
 		CreateProfile(ctx context.Context, in *CreateProfileRequest, out *CreateProfileResponse) error
 		UpdateProfile(ctx context.Context, in *UpdateProfileRequest, out *UpdateProfileResponse) error
+		GetPublicProfile(ctx context.Context, in *GetPublicProfileRequest, out *GetPublicProfileResponse) error
+		ChangeUsername(ctx context.Context, in *ChangeUsernameRequest, out *ChangeUsernameResponse) error
+		GetProfileHistory(ctx context.Context, in *GetProfileHistoryRequest, out *GetProfileHistoryResponse) error
+		GetProfileAt(ctx context.Context, in *GetProfileAtRequest, out *GetProfileAtResponse) error
 		Register(ctx context.Context, in *RegisterRequest, out *RegisterResponse) error
 		Login(ctx context.Context, in *LoginRequest, out *LoginResponse) error
 		Logout(ctx context.Context, in *LogoutRequest, out *LogoutResponse) error
 		Authenticate(ctx context.Context, in *AuthRequest, out *AuthResponse) error
+		Follow(ctx context.Context, in *RelationRequest, out *RelationResponse) error
+		Unfollow(ctx context.Context, in *RelationRequest, out *RelationResponse) error
+		RequestFriend(ctx context.Context, in *RelationRequest, out *RelationResponse) error
+		AcceptFriend(ctx context.Context, in *RelationRequest, out *RelationResponse) error
+		RejectFriend(ctx context.Context, in *RelationRequest, out *RelationResponse) error
+		Block(ctx context.Context, in *RelationRequest, out *RelationResponse) error
+		Unblock(ctx context.Context, in *RelationRequest, out *RelationResponse) error
+		ListFollowers(ctx context.Context, in *ListRelationsRequest, out *ListRelationsResponse) error
+		ListFollowing(ctx context.Context, in *ListRelationsRequest, out *ListRelationsResponse) error
+		ListFriends(ctx context.Context, in *ListRelationsRequest, out *ListRelationsResponse) error
+		ListFriendRequests(ctx context.Context, in *ListRelationsRequest, out *ListRelationsResponse) error
+		GetRelationCounts(ctx context.Context, in *GetRelationCountsRequest, out *GetRelationCountsResponse) error
 	}
 	type Userinfo struct {
 		userinfo
@@ -181,6 +402,10 @@ func (h *userinfoHandler) DeleteProfile(ctx context.Context, in *DeleteProfileRe
 	return h.UserinfoHandler.DeleteProfile(ctx, in, out)
 }
 
+func (h *userinfoHandler) RestoreProfile(ctx context.Context, in *RestoreProfileRequest, out *RestoreProfileResponse) error {
+	return h.UserinfoHandler.RestoreProfile(ctx, in, out)
+}
+
 func (h *userinfoHandler) CreateProfile(ctx context.Context, in *CreateProfileRequest, out *CreateProfileResponse) error {
 	return h.UserinfoHandler.CreateProfile(ctx, in, out)
 }
@@ -189,6 +414,22 @@ func (h *userinfoHandler) UpdateProfile(ctx context.Context, in *UpdateProfileRe
 	return h.UserinfoHandler.UpdateProfile(ctx, in, out)
 }
 
+func (h *userinfoHandler) GetPublicProfile(ctx context.Context, in *GetPublicProfileRequest, out *GetPublicProfileResponse) error {
+	return h.UserinfoHandler.GetPublicProfile(ctx, in, out)
+}
+
+func (h *userinfoHandler) ChangeUsername(ctx context.Context, in *ChangeUsernameRequest, out *ChangeUsernameResponse) error {
+	return h.UserinfoHandler.ChangeUsername(ctx, in, out)
+}
+
+func (h *userinfoHandler) GetProfileHistory(ctx context.Context, in *GetProfileHistoryRequest, out *GetProfileHistoryResponse) error {
+	return h.UserinfoHandler.GetProfileHistory(ctx, in, out)
+}
+
+func (h *userinfoHandler) GetProfileAt(ctx context.Context, in *GetProfileAtRequest, out *GetProfileAtResponse) error {
+	return h.UserinfoHandler.GetProfileAt(ctx, in, out)
+}
+
 func (h *userinfoHandler) Register(ctx context.Context, in *RegisterRequest, out *RegisterResponse) error {
 	return h.UserinfoHandler.Register(ctx, in, out)
 }
@@ -204,3 +445,51 @@ func (h *userinfoHandler) Logout(ctx context.Context, in *LogoutRequest, out *Lo
 func (h *userinfoHandler) Authenticate(ctx context.Context, in *AuthRequest, out *AuthResponse) error {
 	return h.UserinfoHandler.Authenticate(ctx, in, out)
 }
+
+func (h *userinfoHandler) Follow(ctx context.Context, in *RelationRequest, out *RelationResponse) error {
+	return h.UserinfoHandler.Follow(ctx, in, out)
+}
+
+func (h *userinfoHandler) Unfollow(ctx context.Context, in *RelationRequest, out *RelationResponse) error {
+	return h.UserinfoHandler.Unfollow(ctx, in, out)
+}
+
+func (h *userinfoHandler) RequestFriend(ctx context.Context, in *RelationRequest, out *RelationResponse) error {
+	return h.UserinfoHandler.RequestFriend(ctx, in, out)
+}
+
+func (h *userinfoHandler) AcceptFriend(ctx context.Context, in *RelationRequest, out *RelationResponse) error {
+	return h.UserinfoHandler.AcceptFriend(ctx, in, out)
+}
+
+func (h *userinfoHandler) RejectFriend(ctx context.Context, in *RelationRequest, out *RelationResponse) error {
+	return h.UserinfoHandler.RejectFriend(ctx, in, out)
+}
+
+func (h *userinfoHandler) Block(ctx context.Context, in *RelationRequest, out *RelationResponse) error {
+	return h.UserinfoHandler.Block(ctx, in, out)
+}
+
+func (h *userinfoHandler) Unblock(ctx context.Context, in *RelationRequest, out *RelationResponse) error {
+	return h.UserinfoHandler.Unblock(ctx, in, out)
+}
+
+func (h *userinfoHandler) ListFollowers(ctx context.Context, in *ListRelationsRequest, out *ListRelationsResponse) error {
+	return h.UserinfoHandler.ListFollowers(ctx, in, out)
+}
+
+func (h *userinfoHandler) ListFollowing(ctx context.Context, in *ListRelationsRequest, out *ListRelationsResponse) error {
+	return h.UserinfoHandler.ListFollowing(ctx, in, out)
+}
+
+func (h *userinfoHandler) ListFriends(ctx context.Context, in *ListRelationsRequest, out *ListRelationsResponse) error {
+	return h.UserinfoHandler.ListFriends(ctx, in, out)
+}
+
+func (h *userinfoHandler) ListFriendRequests(ctx context.Context, in *ListRelationsRequest, out *ListRelationsResponse) error {
+	return h.UserinfoHandler.ListFriendRequests(ctx, in, out)
+}
+
+func (h *userinfoHandler) GetRelationCounts(ctx context.Context, in *GetRelationCountsRequest, out *GetRelationCountsResponse) error {
+	return h.UserinfoHandler.GetRelationCounts(ctx, in, out)
+}
@@ -3,6 +3,7 @@ package conf
 import (
 	"gopkg.in/yaml.v3"
 	"os"
+	"time"
 )
 
 type Config struct {
@@ -11,8 +12,11 @@ type Config struct {
 	Etcd   Etcd   `yaml:"etcd"`
 }
 
+// Server serves the api at addr. Each request is given up after request-timeout, e.g. 3s, and the deadline is passed
+// on to the rpc calls of the request.
 type Server struct {
-	Addr string `yaml:"addr"`
+	Addr           string        `yaml:"addr"`
+	RequestTimeout time.Duration `yaml:"request-timeout"`
 }
 
 type Micro struct {
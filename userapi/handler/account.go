@@ -59,8 +59,8 @@ func (c *Client) Logout(context *gin.Context) {
 }
 
 func (c *Client) Register(context *gin.Context) {
-	account := &Account{}
-	if err := context.ShouldBind(account); err != nil {
+	registration := &Registration{}
+	if err := context.ShouldBind(registration); err != nil {
 		c.logger.Error(c.context, "Bind request data error, err: ", err.Error())
 		context.JSON(http.StatusBadRequest, gin.H{
 			"code": errs.ERR_REGISTER_REQUEST,
@@ -71,22 +71,20 @@ func (c *Client) Register(context *gin.Context) {
 		return
 	}
 	r := &userinfo.RegisterRequest{
-		Email:     account.Email,
-		Password:  account.Password,
-		RequestId: GetRequestId(context),
+		Email:    registration.Email,
+		Password: registration.Password,
+		Username: registration.Username,
+		Birthday: registration.Birthday,
+		Locale:   registration.Locale,
+		Timezone: registration.Timezone,
+		// the locale defaults to the preferred language of the client.
+		AcceptLanguage: context.GetHeader("Accept-Language"),
+		RequestId:      GetRequestId(context),
 	}
 	c.logger.Info(c.context, "Call register, request: ", r)
 	_, err := c.userinfoClient.Register(context, r)
 	if err != nil {
-		c.logger.Error(c.context, "Call rpc server failed, error: ", err, "request: ", r)
-		code := errors.Parse(err.Error()).Code
-		msg := errors.Parse(err.Error()).Detail
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"code": code,
-			"msg":  msg,
-			"data": nil,
-		})
-		context.Abort()
+		c.abortWithRpcError(context, err)
 		return
 	}
 
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"protos/userinfo"
+)
+
+// ProfileData is the profile returned to clients, with attributes as plain json values.
+type ProfileData struct {
+	*userinfo.Profile
+	Attributes map[string]any `json:"attributes,omitempty"`
+	// RedirectedFrom is the requested previous username, clients should redirect to the current username.
+	RedirectedFrom string `json:"redirected_from,omitempty"`
+}
+
+func NewProfileData(p *userinfo.Profile) *ProfileData {
+	return &ProfileData{
+		Profile:    p,
+		Attributes: attributesToMap(p.GetAttributes()),
+	}
+}
+
+// parseAttributes parses attributes json object from request. A null value removes the attribute.
+func parseAttributes(raw string) (map[string]*userinfo.Value, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	attributes := make(map[string]any)
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&attributes); err != nil {
+		return nil, err
+	}
+	values := make(map[string]*userinfo.Value, len(attributes))
+	for name, attribute := range attributes {
+		value := &userinfo.Value{}
+		switch v := attribute.(type) {
+		case nil:
+		case string:
+			value.Kind = &userinfo.Value_StringValue{StringValue: v}
+		case bool:
+			value.Kind = &userinfo.Value_BoolValue{BoolValue: v}
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				value.Kind = &userinfo.Value_IntValue{IntValue: n}
+			} else if f, err := v.Float64(); err == nil {
+				value.Kind = &userinfo.Value_DoubleValue{DoubleValue: f}
+			} else {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("attribute %v should be a string, number, bool or null", name)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// parsePrivacy parses privacy json object from request. An empty visibility resets the field to default.
+func parsePrivacy(raw string) (map[string]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	privacy := make(map[string]string)
+	if err := json.Unmarshal([]byte(raw), &privacy); err != nil {
+		return nil, err
+	}
+	return privacy, nil
+}
+
+func attributesToMap(values map[string]*userinfo.Value) map[string]any {
+	if len(values) == 0 {
+		return nil
+	}
+	attributes := make(map[string]any, len(values))
+	for name, value := range values {
+		switch v := value.GetKind().(type) {
+		case *userinfo.Value_StringValue:
+			attributes[name] = v.StringValue
+		case *userinfo.Value_IntValue:
+			attributes[name] = v.IntValue
+		case *userinfo.Value_DoubleValue:
+			attributes[name] = v.DoubleValue
+		case *userinfo.Value_BoolValue:
+			attributes[name] = v.BoolValue
+		}
+	}
+	return attributes
+}
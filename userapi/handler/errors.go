@@ -0,0 +1,54 @@
+package handler
+
+import (
+	errs "errs"
+	"github.com/asim/go-micro/v3/errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// rpcErrorStatus maps error codes returned by rpc server to http status.
+// Codes not listed here are handled as internal server errors.
+var rpcErrorStatus = map[int32]int{
+	errs.ERR_INVALID_PROFILE:             http.StatusBadRequest,
+	errs.ERR_GET_PROFILE_REQUEST:         http.StatusBadRequest,
+	errs.ERR_GET_PROFILE_HISTORY_REQUEST: http.StatusBadRequest,
+	errs.ERR_DELETE_PROFILE_REQUEST:      http.StatusBadRequest,
+	errs.ERR_PROFILE_NOT_FOUND:           http.StatusNotFound,
+	errs.ERR_PROFILE_EXISTS:              http.StatusConflict,
+	errs.ERR_USERNAME_TAKEN:              http.StatusConflict,
+	errs.ERR_USERNAME_CHANGE_COOLDOWN:    http.StatusTooManyRequests,
+	errs.ERR_INVALID_RELATION:            http.StatusBadRequest,
+	errs.ERR_LIST_RELATIONS_REQUEST:      http.StatusBadRequest,
+	errs.ERR_RELATION_BLOCKED:            http.StatusForbidden,
+	errs.ERR_RELATION_USER_NOT_FOUND:     http.StatusNotFound,
+	errs.ERR_FRIEND_REQUEST_NOT_FOUND:    http.StatusNotFound,
+	errs.ERR_DEADLINE_EXCEEDED:           http.StatusGatewayTimeout,
+	errs.ERR_SHARD_RANGE_MOVING:          http.StatusServiceUnavailable,
+}
+
+// abortWithRpcError responds the error returned by rpc server.
+// Field-level violations of invalid requests are returned in data.
+func (c *Client) abortWithRpcError(context *gin.Context, err error) {
+	c.logger.Error(c.context, "Call rpc server failed, error: ", err)
+	e := errors.Parse(err.Error())
+	if e.Code == http.StatusRequestTimeout {
+		// the rpc client gave up at the deadline of the request.
+		e = &errors.Error{Code: errs.ERR_DEADLINE_EXCEEDED, Detail: errs.GetMsg(errs.ERR_DEADLINE_EXCEEDED)}
+	}
+	msg, violations := errs.ParseDetail(e.Detail)
+	status, ok := rpcErrorStatus[e.Code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	var data any
+	if violations != nil {
+		data = gin.H{"violations": violations}
+	}
+	context.JSON(status, gin.H{
+		"code": e.Code,
+		"msg":  msg,
+		"data": data,
+	})
+	context.Abort()
+}
@@ -9,9 +9,12 @@ import (
 	"loggers"
 	"net/http"
 	"protos/userinfo"
+	"time"
 )
 
 const (
+	REQUEST_TIMEOUT_DEFAULT = time.Second * 3
+
 	KEY_REQUEST_ID     = "request_id"
 	KEY_ACCESS_TOKEN   = "access_token"
 	KEY_USER_ID        = "user_id"
@@ -19,6 +22,20 @@ const (
 	COOKIE_EXPIRE_TIME = 3600 * 24
 )
 
+// Deadline sets the deadline of each request to timeout later. The rpc calls made with gin.Context carry the
+// deadline to the rpc server, which stops the work of the request after it.
+func Deadline(timeout time.Duration) gin.HandlerFunc {
+	if timeout <= 0 {
+		timeout = REQUEST_TIMEOUT_DEFAULT
+	}
+	return func(ctx *gin.Context) {
+		deadlineCtx, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+		defer cancel()
+		ctx.Request = ctx.Request.WithContext(deadlineCtx)
+		ctx.Next()
+	}
+}
+
 func (c *Client) Authenticate(context *gin.Context) {
 	c.logger.Info(c.context, "Authenticate for api request.")
 	token, err := context.Cookie(KEY_ACCESS_TOKEN)
@@ -57,6 +74,33 @@ func (c *Client) Authenticate(context *gin.Context) {
 	context.Next()
 }
 
+// OptionalAuthenticate sets the user id like Authenticate if the request carries a valid token,
+// otherwise it lets the request through as an anonymous one.
+func (c *Client) OptionalAuthenticate(context *gin.Context) {
+	token, err := context.Cookie(KEY_ACCESS_TOKEN)
+	if err != nil || token == "" {
+		c.logger.Info(c.context, "No access_token, handle as anonymous request.")
+		context.Next()
+		return
+	}
+
+	req := &userinfo.AuthRequest{
+		Token:     token,
+		RequestId: GetRequestId(context),
+	}
+	resp, err := c.userinfoClient.Authenticate(context, req)
+	if err != nil {
+		c.logger.Warning(c.context, "Authenticate failed, handle as anonymous request. err: ", err.Error())
+		context.Next()
+		return
+	}
+
+	c.logger.Info(c.context, "Authenticate succeed, userId: ", resp.GetUserId())
+	context.Set(KEY_USER_ID, resp.GetUserId())
+	context.Set(KEY_EMAIL, resp.GetEmail())
+	context.Next()
+}
+
 func (c *Client) Log(context *gin.Context) {
 	c.logger.Info(c.context, "Handling request: ", context.FullPath(), " method: ", context.Request.Method)
 }
@@ -3,9 +3,59 @@ package handler
 type Profile struct {
 	Username string `form:"username"`
 	Birthday string `form:"birthday"`
+	Locale   string `form:"locale"`
+	Timezone string `form:"timezone"`
+	// Attributes is a json object of custom attributes, e.g. {"nickname": "lgk"}.
+	Attributes string `form:"attributes"`
+	// Privacy is a json object of visibility of fields, e.g. {"birthday": "logged_in"}.
+	Privacy string `form:"privacy"`
+}
+
+// PublicProfileQuery finds a profile by user_id, or by username if user_id is not given.
+type PublicProfileQuery struct {
+	UserId   uint64 `form:"user_id"`
+	Username string `form:"username"`
+}
+
+type ProfileHistoryQuery struct {
+	BeforeVersion uint64 `form:"before_version"`
+	Limit         uint32 `form:"limit"`
+}
+
+type ProfileAtQuery struct {
+	Timestamp int64 `form:"timestamp" binding:"required"`
+}
+
+type UsernameForm struct {
+	Username string `form:"username" binding:"required"`
+}
+
+// DeleteProfileQuery requires confirm=true, so that a profile is not deleted by an accidental request.
+type DeleteProfileQuery struct {
+	Confirm bool `form:"confirm"`
+}
+
+type RelationForm struct {
+	TargetId uint64 `form:"target_id" binding:"required"`
+}
+
+// RelationsQuery lists relations of user_id, or of the logged-in user if user_id is not given.
+type RelationsQuery struct {
+	UserId   uint64 `form:"user_id"`
+	BeforeId uint64 `form:"before_id"`
+	Limit    uint32 `form:"limit"`
 }
 
 type Account struct {
 	Email    string `form:"email"`
 	Password string `form:"password"`
 }
+
+// Registration is an account with optional fields of the default profile.
+type Registration struct {
+	Account
+	Username string `form:"username"`
+	Birthday string `form:"birthday"`
+	Locale   string `form:"locale"`
+	Timezone string `form:"timezone"`
+}
@@ -3,7 +3,6 @@ package handler
 import (
 	"encoding/json"
 	errs "errs"
-	"github.com/asim/go-micro/v3/errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"protos/userinfo"
@@ -21,19 +20,64 @@ func (c *Client) GetProfile(context *gin.Context) {
 	}
 	resp, err := c.userinfoClient.GetProfile(context, &r)
 	if err != nil {
-		c.logger.Error(c.context, "Call rpc server failed, error: ", err)
-		code := errors.Parse(err.Error()).Code
-		msg := errors.Parse(err.Error()).Detail
+		c.abortWithRpcError(context, err)
+		return
+	}
+
+	p, err := json.Marshal(NewProfileData(resp.GetProfile()))
+	if err != nil {
+		c.logger.Error(c.context, "Marshal profile tp json failed, err: ", err.Error())
 		context.JSON(http.StatusInternalServerError, gin.H{
-			"code": code,
-			"msg":  msg,
+			"code": errs.ERR_GET_PROFILE_FAILED,
+			"msg":  errs.GetMsg(errs.ERR_GET_PROFILE_FAILED),
 			"data": nil,
 		})
 		context.Abort()
 		return
 	}
 
-	p, err := json.Marshal(resp.GetProfile())
+	c.logger.Info(c.context, "Handle get profile success, profile: ", string(p))
+	context.JSON(http.StatusOK, gin.H{
+		"code": errs.SUCCESS,
+		"msg":  errs.GetMsg(errs.SUCCESS),
+		"data": string(p),
+	})
+}
+
+// GetPublicProfile returns the profile of any user, projected by the privacy settings of the owner.
+// Logged-in viewers may see more fields than anonymous viewers.
+func (c *Client) GetPublicProfile(context *gin.Context) {
+	query := &PublicProfileQuery{}
+	if err := context.ShouldBindQuery(query); err != nil || (query.UserId == 0 && query.Username == "") {
+		c.logger.Error(c.context, "Bind request data error, query: ", context.Request.URL.RawQuery)
+		context.JSON(http.StatusBadRequest, gin.H{
+			"code": errs.ERR_GET_PROFILE_REQUEST,
+			"msg":  errs.GetMsg(errs.ERR_GET_PROFILE_REQUEST),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
+
+	viewerId, ok := context.Get(KEY_USER_ID)
+	if !ok {
+		viewerId = uint64(0)
+	}
+	r := &userinfo.GetPublicProfileRequest{
+		UserId:    query.UserId,
+		Username:  query.Username,
+		ViewerId:  viewerId.(uint64),
+		RequestId: GetRequestId(context),
+	}
+	resp, err := c.userinfoClient.GetPublicProfile(context, r)
+	if err != nil {
+		c.abortWithRpcError(context, err)
+		return
+	}
+
+	data := NewProfileData(resp.GetProfile())
+	data.RedirectedFrom = resp.GetRedirectedFrom()
+	p, err := json.Marshal(data)
 	if err != nil {
 		c.logger.Error(c.context, "Marshal profile tp json failed, err: ", err.Error())
 		context.JSON(http.StatusInternalServerError, gin.H{
@@ -45,7 +89,7 @@ func (c *Client) GetProfile(context *gin.Context) {
 		return
 	}
 
-	c.logger.Info(c.context, "Handle get profile success, profile: ", string(p))
+	c.logger.Info(c.context, "Handle get public profile success, profile: ", string(p))
 	context.JSON(http.StatusOK, gin.H{
 		"code": errs.SUCCESS,
 		"msg":  errs.GetMsg(errs.SUCCESS),
@@ -65,6 +109,28 @@ func (c *Client) UpdateProfile(context *gin.Context) {
 		context.Abort()
 		return
 	}
+	attributes, err := parseAttributes(profile.Attributes)
+	if err != nil {
+		c.logger.Error(c.context, "Parse attributes error, err: ", err.Error())
+		context.JSON(http.StatusBadRequest, gin.H{
+			"code": errs.ERR_INVALID_ATTRIBUTE,
+			"msg":  errs.GetMsg(errs.ERR_INVALID_ATTRIBUTE),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
+	privacy, err := parsePrivacy(profile.Privacy)
+	if err != nil {
+		c.logger.Error(c.context, "Parse privacy error, err: ", err.Error())
+		context.JSON(http.StatusBadRequest, gin.H{
+			"code": errs.ERR_INVALID_PRIVACY,
+			"msg":  errs.GetMsg(errs.ERR_INVALID_PRIVACY),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
 
 	userId := c.getAuthedData(context, KEY_USER_ID)
 	if userId == nil {
@@ -73,23 +139,19 @@ func (c *Client) UpdateProfile(context *gin.Context) {
 
 	r := &userinfo.UpdateProfileRequest{
 		Profile: &userinfo.Profile{
-			UserId:   userId.(uint64),
-			Username: profile.Username,
-			Birthday: profile.Birthday,
+			UserId:     userId.(uint64),
+			Username:   profile.Username,
+			Birthday:   profile.Birthday,
+			Locale:     profile.Locale,
+			Timezone:   profile.Timezone,
+			Attributes: attributes,
+			Privacy:    privacy,
 		},
 		RequestId: GetRequestId(context),
 	}
-	_, err := c.userinfoClient.UpdateProfile(context, r)
+	_, err = c.userinfoClient.UpdateProfile(context, r)
 	if err != nil {
-		c.logger.Error(c.context, "Call rpc server failed, error: ", err)
-		code := errors.Parse(err.Error()).Code
-		msg := errors.Parse(err.Error()).Detail
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"code": code,
-			"msg":  msg,
-			"data": nil,
-		})
-		context.Abort()
+		c.abortWithRpcError(context, err)
 		return
 	}
 
@@ -113,6 +175,28 @@ func (c *Client) CreateProfile(context *gin.Context) {
 		context.Abort()
 		return
 	}
+	attributes, err := parseAttributes(profile.Attributes)
+	if err != nil {
+		c.logger.Error(c.context, "Parse attributes error, err: ", err.Error())
+		context.JSON(http.StatusBadRequest, gin.H{
+			"code": errs.ERR_INVALID_ATTRIBUTE,
+			"msg":  errs.GetMsg(errs.ERR_INVALID_ATTRIBUTE),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
+	privacy, err := parsePrivacy(profile.Privacy)
+	if err != nil {
+		c.logger.Error(c.context, "Parse privacy error, err: ", err.Error())
+		context.JSON(http.StatusBadRequest, gin.H{
+			"code": errs.ERR_INVALID_PRIVACY,
+			"msg":  errs.GetMsg(errs.ERR_INVALID_PRIVACY),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
 
 	userId := c.getAuthedData(context, KEY_USER_ID)
 	email := c.getAuthedData(context, KEY_EMAIL)
@@ -121,28 +205,137 @@ func (c *Client) CreateProfile(context *gin.Context) {
 	}
 	r := &userinfo.CreateProfileRequest{
 		Profile: &userinfo.Profile{
-			UserId:   userId.(uint64),
-			Email:    email.(string),
-			Username: profile.Username,
-			Birthday: profile.Birthday,
+			UserId:     userId.(uint64),
+			Email:      email.(string),
+			Username:   profile.Username,
+			Birthday:   profile.Birthday,
+			Locale:     profile.Locale,
+			Timezone:   profile.Timezone,
+			Attributes: attributes,
+			Privacy:    privacy,
 		},
 		RequestId: GetRequestId(context),
+		// "If-None-Match: *" asks to create only if there is no profile, 409 is responded otherwise.
+		FailIfExists: context.GetHeader("If-None-Match") == "*",
 	}
-	_, err := c.userinfoClient.CreateProfile(context, r)
+	_, err = c.userinfoClient.CreateProfile(context, r)
 	if err != nil {
-		c.logger.Error(c.context, "Call rpc server failed, error: ", err)
-		code := errors.Parse(err.Error()).Code
-		msg := errors.Parse(err.Error()).Detail
+		c.abortWithRpcError(context, err)
+		return
+	}
+
+	c.logger.Info(c.context, "Handle create profile success.")
+	context.JSON(http.StatusOK, gin.H{
+		"code": errs.SUCCESS,
+		"msg":  errs.GetMsg(errs.SUCCESS),
+		"data": nil,
+	})
+}
+
+// DeleteProfile soft deletes the profile of the logged-in user.
+// The profile can be restored by RestoreProfile before the returned restore_deadline.
+func (c *Client) DeleteProfile(context *gin.Context) {
+	query := &DeleteProfileQuery{}
+	if err := context.ShouldBindQuery(query); err != nil || !query.Confirm {
+		c.logger.Error(c.context, "Deletion is not confirmed, query: ", context.Request.URL.RawQuery)
+		context.JSON(http.StatusBadRequest, gin.H{
+			"code": errs.ERR_DELETE_PROFILE_REQUEST,
+			"msg":  errs.GetMsg(errs.ERR_DELETE_PROFILE_REQUEST),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
+
+	userId := c.getAuthedData(context, KEY_USER_ID)
+	if userId == nil {
+		return
+	}
+	r := &userinfo.DeleteProfileRequest{
+		UserId:    userId.(uint64),
+		RequestId: GetRequestId(context),
+	}
+	resp, err := c.userinfoClient.DeleteProfile(context, r)
+	if err != nil {
+		c.abortWithRpcError(context, err)
+		return
+	}
+
+	d, err := json.Marshal(gin.H{"restore_deadline": resp.GetRestoreDeadline()})
+	if err != nil {
+		c.logger.Error(c.context, "Marshal restore deadline to json failed, err: ", err.Error())
 		context.JSON(http.StatusInternalServerError, gin.H{
-			"code": code,
-			"msg":  msg,
+			"code": errs.ERR_DELETE_PROFILE_FAILED,
+			"msg":  errs.GetMsg(errs.ERR_DELETE_PROFILE_FAILED),
 			"data": nil,
 		})
 		context.Abort()
 		return
 	}
 
-	c.logger.Info(c.context, "Handle create profile success.")
+	c.logger.Info(c.context, "Handle delete profile success.")
+	context.JSON(http.StatusOK, gin.H{
+		"code": errs.SUCCESS,
+		"msg":  errs.GetMsg(errs.SUCCESS),
+		"data": string(d),
+	})
+}
+
+// RestoreProfile restores the profile deleted by DeleteProfile within the restore window.
+func (c *Client) RestoreProfile(context *gin.Context) {
+	userId := c.getAuthedData(context, KEY_USER_ID)
+	if userId == nil {
+		return
+	}
+	r := &userinfo.RestoreProfileRequest{
+		UserId:    userId.(uint64),
+		RequestId: GetRequestId(context),
+	}
+	_, err := c.userinfoClient.RestoreProfile(context, r)
+	if err != nil {
+		c.abortWithRpcError(context, err)
+		return
+	}
+
+	c.logger.Info(c.context, "Handle restore profile success.")
+	context.JSON(http.StatusOK, gin.H{
+		"code": errs.SUCCESS,
+		"msg":  errs.GetMsg(errs.SUCCESS),
+		"data": nil,
+	})
+}
+
+// ChangeUsername changes the username of the logged-in user. The previous username keeps redirecting
+// to the user for a while, and it can not be changed again within the cooldown.
+func (c *Client) ChangeUsername(context *gin.Context) {
+	form := &UsernameForm{}
+	if err := context.ShouldBind(form); err != nil {
+		c.logger.Error(c.context, "Bind request data error, err: ", err.Error())
+		context.JSON(http.StatusBadRequest, gin.H{
+			"code": errs.ERR_CHANGE_USERNAME_FAILED,
+			"msg":  errs.GetMsg(errs.ERR_CHANGE_USERNAME_FAILED),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
+
+	userId := c.getAuthedData(context, KEY_USER_ID)
+	if userId == nil {
+		return
+	}
+	r := &userinfo.ChangeUsernameRequest{
+		UserId:    userId.(uint64),
+		Username:  form.Username,
+		RequestId: GetRequestId(context),
+	}
+	_, err := c.userinfoClient.ChangeUsername(context, r)
+	if err != nil {
+		c.abortWithRpcError(context, err)
+		return
+	}
+
+	c.logger.Info(c.context, "Handle change username success.")
 	context.JSON(http.StatusOK, gin.H{
 		"code": errs.SUCCESS,
 		"msg":  errs.GetMsg(errs.SUCCESS),
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	errs "errs"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"protos/userinfo"
+)
+
+func (c *Client) GetProfileHistory(context *gin.Context) {
+	query := &ProfileHistoryQuery{}
+	if err := context.ShouldBindQuery(query); err != nil {
+		c.logger.Error(c.context, "Bind request data error, err: ", err.Error())
+		context.JSON(http.StatusBadRequest, gin.H{
+			"code": errs.ERR_GET_PROFILE_HISTORY_REQUEST,
+			"msg":  errs.GetMsg(errs.ERR_GET_PROFILE_HISTORY_REQUEST),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
+
+	userId := c.getAuthedData(context, KEY_USER_ID)
+	if userId == nil {
+		return
+	}
+
+	r := &userinfo.GetProfileHistoryRequest{
+		UserId:        userId.(uint64),
+		BeforeVersion: query.BeforeVersion,
+		Limit:         query.Limit,
+		RequestId:     GetRequestId(context),
+	}
+	resp, err := c.userinfoClient.GetProfileHistory(context, r)
+	if err != nil {
+		c.abortWithRpcError(context, err)
+		return
+	}
+
+	h, err := json.Marshal(resp)
+	if err != nil {
+		c.logger.Error(c.context, "Marshal profile history to json failed, err: ", err.Error())
+		context.JSON(http.StatusInternalServerError, gin.H{
+			"code": errs.ERR_GET_PROFILE_HISTORY_FAILED,
+			"msg":  errs.GetMsg(errs.ERR_GET_PROFILE_HISTORY_FAILED),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
+
+	c.logger.Info(c.context, "Handle get profile history success.")
+	context.JSON(http.StatusOK, gin.H{
+		"code": errs.SUCCESS,
+		"msg":  errs.GetMsg(errs.SUCCESS),
+		"data": string(h),
+	})
+}
+
+// GetProfileAt returns the profile as it was at the given unix timestamp.
+func (c *Client) GetProfileAt(context *gin.Context) {
+	query := &ProfileAtQuery{}
+	if err := context.ShouldBindQuery(query); err != nil {
+		c.logger.Error(c.context, "Bind request data error, err: ", err.Error())
+		context.JSON(http.StatusBadRequest, gin.H{
+			"code": errs.ERR_GET_PROFILE_HISTORY_REQUEST,
+			"msg":  errs.GetMsg(errs.ERR_GET_PROFILE_HISTORY_REQUEST),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
+
+	userId := c.getAuthedData(context, KEY_USER_ID)
+	if userId == nil {
+		return
+	}
+
+	r := &userinfo.GetProfileAtRequest{
+		UserId:    userId.(uint64),
+		Timestamp: query.Timestamp,
+		RequestId: GetRequestId(context),
+	}
+	resp, err := c.userinfoClient.GetProfileAt(context, r)
+	if err != nil {
+		c.abortWithRpcError(context, err)
+		return
+	}
+
+	p, err := json.Marshal(gin.H{
+		"profile": NewProfileData(resp.GetProfile()),
+		"version": resp.GetVersion(),
+	})
+	if err != nil {
+		c.logger.Error(c.context, "Marshal profile to json failed, err: ", err.Error())
+		context.JSON(http.StatusInternalServerError, gin.H{
+			"code": errs.ERR_GET_PROFILE_HISTORY_FAILED,
+			"msg":  errs.GetMsg(errs.ERR_GET_PROFILE_HISTORY_FAILED),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
+
+	c.logger.Info(c.context, "Handle get profile at timestamp success.")
+	context.JSON(http.StatusOK, gin.H{
+		"code": errs.SUCCESS,
+		"msg":  errs.GetMsg(errs.SUCCESS),
+		"data": string(p),
+	})
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	ctx "context"
+	"encoding/json"
+	errs "errs"
+	"github.com/asim/go-micro/v3/client"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"protos/userinfo"
+)
+
+type updateRelationCall func(ctx.Context, *userinfo.RelationRequest, ...client.CallOption) (*userinfo.RelationResponse, error)
+type listRelationsCall func(ctx.Context, *userinfo.ListRelationsRequest, ...client.CallOption) (*userinfo.ListRelationsResponse, error)
+
+func (c *Client) Follow(context *gin.Context) {
+	c.updateRelation(context, "follow", c.userinfoClient.Follow)
+}
+
+func (c *Client) Unfollow(context *gin.Context) {
+	c.updateRelation(context, "unfollow", c.userinfoClient.Unfollow)
+}
+
+func (c *Client) RequestFriend(context *gin.Context) {
+	c.updateRelation(context, "request friend", c.userinfoClient.RequestFriend)
+}
+
+// AcceptFriend accepts the friend request sent by target_id.
+func (c *Client) AcceptFriend(context *gin.Context) {
+	c.updateRelation(context, "accept friend", c.userinfoClient.AcceptFriend)
+}
+
+// RejectFriend rejects the friend request sent by target_id.
+func (c *Client) RejectFriend(context *gin.Context) {
+	c.updateRelation(context, "reject friend", c.userinfoClient.RejectFriend)
+}
+
+func (c *Client) Block(context *gin.Context) {
+	c.updateRelation(context, "block", c.userinfoClient.Block)
+}
+
+func (c *Client) Unblock(context *gin.Context) {
+	c.updateRelation(context, "unblock", c.userinfoClient.Unblock)
+}
+
+func (c *Client) ListFollowers(context *gin.Context) {
+	c.listRelations(context, "followers", true, c.userinfoClient.ListFollowers)
+}
+
+func (c *Client) ListFollowing(context *gin.Context) {
+	c.listRelations(context, "following", true, c.userinfoClient.ListFollowing)
+}
+
+func (c *Client) ListFriends(context *gin.Context) {
+	c.listRelations(context, "friends", true, c.userinfoClient.ListFriends)
+}
+
+// ListFriendRequests lists the pending friend requests received by the logged-in user only.
+func (c *Client) ListFriendRequests(context *gin.Context) {
+	c.listRelations(context, "friend requests", false, c.userinfoClient.ListFriendRequests)
+}
+
+// GetRelationCounts returns the relation counts of user_id, or of the logged-in user if user_id is not given.
+func (c *Client) GetRelationCounts(context *gin.Context) {
+	query := &RelationsQuery{}
+	if err := context.ShouldBindQuery(query); err != nil {
+		c.logger.Error(c.context, "Bind request data error, err: ", err.Error())
+		context.JSON(http.StatusBadRequest, gin.H{
+			"code": errs.ERR_LIST_RELATIONS_REQUEST,
+			"msg":  errs.GetMsg(errs.ERR_LIST_RELATIONS_REQUEST),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
+	userId := c.getAuthedData(context, KEY_USER_ID)
+	if userId == nil {
+		return
+	}
+	if query.UserId == 0 {
+		query.UserId = userId.(uint64)
+	}
+
+	r := &userinfo.GetRelationCountsRequest{
+		UserId:    query.UserId,
+		RequestId: GetRequestId(context),
+	}
+	resp, err := c.userinfoClient.GetRelationCounts(context, r)
+	if err != nil {
+		c.abortWithRpcError(context, err)
+		return
+	}
+
+	counts, err := json.Marshal(resp.GetCounts())
+	if err != nil {
+		c.logger.Error(c.context, "Marshal relation counts to json failed, err: ", err.Error())
+		context.JSON(http.StatusInternalServerError, gin.H{
+			"code": errs.ERR_GET_RELATION_COUNTS_FAILED,
+			"msg":  errs.GetMsg(errs.ERR_GET_RELATION_COUNTS_FAILED),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
+
+	c.logger.Info(c.context, "Handle get relation counts success.")
+	context.JSON(http.StatusOK, gin.H{
+		"code": errs.SUCCESS,
+		"msg":  errs.GetMsg(errs.SUCCESS),
+		"data": string(counts),
+	})
+}
+
+func (c *Client) updateRelation(context *gin.Context, name string, call updateRelationCall) {
+	form := &RelationForm{}
+	if err := context.ShouldBind(form); err != nil {
+		c.logger.Error(c.context, "Bind request data error, err: ", err.Error())
+		context.JSON(http.StatusBadRequest, gin.H{
+			"code": errs.ERR_INVALID_RELATION,
+			"msg":  errs.GetMsg(errs.ERR_INVALID_RELATION),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
+	userId := c.getAuthedData(context, KEY_USER_ID)
+	if userId == nil {
+		return
+	}
+
+	r := &userinfo.RelationRequest{
+		UserId:    userId.(uint64),
+		TargetId:  form.TargetId,
+		RequestId: GetRequestId(context),
+	}
+	_, err := call(context, r)
+	if err != nil {
+		c.abortWithRpcError(context, err)
+		return
+	}
+
+	c.logger.Info(c.context, "Handle ", name, " success.")
+	context.JSON(http.StatusOK, gin.H{
+		"code": errs.SUCCESS,
+		"msg":  errs.GetMsg(errs.SUCCESS),
+		"data": nil,
+	})
+}
+
+// listRelations lists a page of relations. If public is false, only the relations of the logged-in user can be listed.
+func (c *Client) listRelations(context *gin.Context, name string, public bool, call listRelationsCall) {
+	query := &RelationsQuery{}
+	if err := context.ShouldBindQuery(query); err != nil {
+		c.logger.Error(c.context, "Bind request data error, err: ", err.Error())
+		context.JSON(http.StatusBadRequest, gin.H{
+			"code": errs.ERR_LIST_RELATIONS_REQUEST,
+			"msg":  errs.GetMsg(errs.ERR_LIST_RELATIONS_REQUEST),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
+	userId := c.getAuthedData(context, KEY_USER_ID)
+	if userId == nil {
+		return
+	}
+	if query.UserId == 0 || !public {
+		query.UserId = userId.(uint64)
+	}
+
+	r := &userinfo.ListRelationsRequest{
+		UserId:    query.UserId,
+		BeforeId:  query.BeforeId,
+		Limit:     query.Limit,
+		RequestId: GetRequestId(context),
+		ViewerId:  userId.(uint64),
+	}
+	resp, err := call(context, r)
+	if err != nil {
+		c.abortWithRpcError(context, err)
+		return
+	}
+
+	l, err := json.Marshal(resp)
+	if err != nil {
+		c.logger.Error(c.context, "Marshal ", name, " to json failed, err: ", err.Error())
+		context.JSON(http.StatusInternalServerError, gin.H{
+			"code": errs.ERR_LIST_RELATIONS_FAILED,
+			"msg":  errs.GetMsg(errs.ERR_LIST_RELATIONS_FAILED),
+			"data": nil,
+		})
+		context.Abort()
+		return
+	}
+
+	c.logger.Info(c.context, "Handle list ", name, " success.")
+	context.JSON(http.StatusOK, gin.H{
+		"code": errs.SUCCESS,
+		"msg":  errs.GetMsg(errs.SUCCESS),
+		"data": string(l),
+	})
+}
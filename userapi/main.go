@@ -16,7 +16,9 @@ func main() {
 	client := handler.NewClient(context.Background(), server.UserinfoClient, logger.NewLogger())
 
 	r := gin.Default()
-	r.Use(client.GenRequestId, client.SetTraceData, client.Log)
+	// let gin.Context carry the deadline of the request, which is passed to the rpc calls made with it.
+	r.ContextWithFallback = true
+	r.Use(handler.Deadline(server.RequestTimeout), client.GenRequestId, client.SetTraceData, client.Log)
 	apiAccount := r.Group("api/account")
 	{
 		apiAccount.POST("login", client.Login)
@@ -30,6 +32,34 @@ func main() {
 		apiProfile.GET("", client.GetProfile)
 		apiProfile.POST("", client.CreateProfile)
 		apiProfile.PUT("", client.UpdateProfile)
+		apiProfile.DELETE("", client.DeleteProfile)
+		apiProfile.POST("restore", client.RestoreProfile)
+		apiProfile.PUT("username", client.ChangeUsername)
+		apiProfile.GET("history", client.GetProfileHistory)
+		apiProfile.GET("at", client.GetProfileAt)
+	}
+
+	apiRelation := r.Group("api/user/relation")
+	apiRelation.Use(client.Authenticate)
+	{
+		apiRelation.POST("follow", client.Follow)
+		apiRelation.POST("unfollow", client.Unfollow)
+		apiRelation.POST("friend/request", client.RequestFriend)
+		apiRelation.POST("friend/accept", client.AcceptFriend)
+		apiRelation.POST("friend/reject", client.RejectFriend)
+		apiRelation.POST("block", client.Block)
+		apiRelation.POST("unblock", client.Unblock)
+		apiRelation.GET("followers", client.ListFollowers)
+		apiRelation.GET("following", client.ListFollowing)
+		apiRelation.GET("friends", client.ListFriends)
+		apiRelation.GET("friend/requests", client.ListFriendRequests)
+		apiRelation.GET("counts", client.GetRelationCounts)
+	}
+
+	apiPublicProfile := r.Group("api/profile")
+	apiPublicProfile.Use(client.OptionalAuthenticate)
+	{
+		apiPublicProfile.GET("", client.GetPublicProfile)
 	}
 
 	if err := r.Run(server.Addr); err != nil {
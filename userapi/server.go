@@ -7,11 +7,13 @@ import (
 	"github.com/asim/go-micro/v3/registry"
 	"log"
 	"protos/userinfo"
+	"time"
 	"user-api/conf"
 )
 
 type Server struct {
 	Addr           string
+	RequestTimeout time.Duration
 	UserinfoClient userinfo.UserinfoService
 }
 
@@ -33,6 +35,7 @@ func (s *Server) Init() error {
 	etcdConf := config.Etcd
 
 	s.Addr = serverConf.Addr
+	s.RequestTimeout = serverConf.RequestTimeout
 
 	// 2. init microservice client
 	etcdReg := etcd.NewRegistry(
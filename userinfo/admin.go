@@ -0,0 +1,69 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+	"user-server/dao"
+)
+
+// DBStats is the response of /debug/dbstats, with the pool statistics of mysql-master and each replica, and those
+// of each shard if sharded.
+type DBStats struct {
+	Master   sql.DBStats     `json:"master"`
+	Replicas []*ReplicaStats `json:"replicas"`
+	Shards   []*ShardStats   `json:"shards,omitempty"`
+}
+
+type ShardStats struct {
+	Name     string          `json:"name"`
+	Master   sql.DBStats     `json:"master"`
+	Replicas []*ReplicaStats `json:"replicas"`
+}
+
+type ReplicaStats struct {
+	Name    string        `json:"name"`
+	Healthy bool          `json:"healthy"`
+	Lag     time.Duration `json:"lag"`
+	Stats   sql.DBStats   `json:"stats"`
+}
+
+// serveAdmin serves the statistics of the service at addr until it fails.
+//
+//	curl http://localhost:8091/debug/dbstats
+func serveAdmin(addr string, dbMaster *dao.DBMaster, dbSlave *dao.DBSlave, shards []*dao.Shard) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/dbstats", func(w http.ResponseWriter, r *http.Request) {
+		stats := &DBStats{Master: dbMaster.Stats(), Replicas: replicaStats(dbSlave)}
+		for _, shard := range shards {
+			stats.Shards = append(stats.Shards, &ShardStats{
+				Name:     shard.Name,
+				Master:   shard.Master.Stats(),
+				Replicas: replicaStats(shard.Slave),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			log.Println("write db stats failed, err: ", err.Error())
+		}
+	})
+	log.Println("admin server listening on ", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Println("admin server failed, err: ", err.Error())
+	}
+}
+
+func replicaStats(dbSlave *dao.DBSlave) []*ReplicaStats {
+	stats := make([]*ReplicaStats, 0, len(dbSlave.Replicas()))
+	for _, replica := range dbSlave.Replicas() {
+		stats = append(stats, &ReplicaStats{
+			Name:    replica.Name,
+			Healthy: replica.Healthy(),
+			Lag:     replica.Lag(),
+			Stats:   replica.Stats(),
+		})
+	}
+	return stats
+}
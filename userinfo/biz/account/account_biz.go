@@ -4,6 +4,7 @@ import (
 	"context"
 	"loggers"
 	"protos/userinfo"
+	"user-server/model"
 	"user-server/service/account"
 )
 
@@ -21,7 +22,13 @@ func NewAccountBiz(accountService *account.AccountService, logger *logger.Logger
 
 func (b *AccountBiz) Register(ctx context.Context, in *userinfo.RegisterRequest, out *userinfo.RegisterResponse) error {
 	b.logger.Info(ctx, "Call AccountBiz.Register, request: ", in)
-	err := b.accountService.Register(ctx, in.GetEmail(), in.GetPassword())
+	p := &model.Profile{
+		Username: in.GetUsername(),
+		Birthday: in.GetBirthday(),
+		Locale:   in.GetLocale(),
+		Timezone: in.GetTimezone(),
+	}
+	err := b.accountService.Register(ctx, in.GetEmail(), in.GetPassword(), p, in.GetAcceptLanguage())
 	if err != nil {
 		b.logger.Error(ctx, "Register failed, err: ", err.Error())
 		return err
@@ -2,6 +2,7 @@ package profile
 
 import (
 	"context"
+	"encoding/json"
 	"loggers"
 	"protos/userinfo"
 	"user-server/model"
@@ -28,42 +29,93 @@ func (b *ProfileBiz) GetProfile(ctx context.Context, in *userinfo.GetProfileRequ
 		b.logger.Error(ctx, "Get profile failed, err: ", err.Error())
 		return err
 	}
-	out.Profile = &userinfo.Profile{
-		Id:       p.Id,
-		UserId:   p.UserId,
-		Username: p.Username,
-		Birthday: p.Birthday,
-		Email:    p.Email,
-		Avatar:   p.AvatarUrl,
-	}
+	out.Profile = toProtoProfile(p)
 	b.logger.Info(ctx, "Call ProfileBiz.GetProfile successfully.")
 	return nil
 }
 
+func (b *ProfileBiz) GetPublicProfile(ctx context.Context, in *userinfo.GetPublicProfileRequest, out *userinfo.GetPublicProfileResponse) error {
+	b.logger.Info(ctx, "Call ProfileBiz.GetPublicProfile, request: ", in)
+	p, redirectedFrom, err := b.profileService.GetPublicProfile(ctx, in.GetUserId(), in.GetUsername(), in.GetViewerId())
+	if err != nil {
+		b.logger.Error(ctx, "Get public profile failed, err: ", err.Error())
+		return err
+	}
+	out.Profile = toProtoProfile(p)
+	out.RedirectedFrom = redirectedFrom
+	b.logger.Info(ctx, "Call ProfileBiz.GetPublicProfile successfully.")
+	return nil
+}
+
+func (b *ProfileBiz) ChangeUsername(ctx context.Context, in *userinfo.ChangeUsernameRequest, out *userinfo.ChangeUsernameResponse) error {
+	b.logger.Info(ctx, "Call ProfileBiz.ChangeUsername, request: ", in)
+	err := b.profileService.ChangeUsername(ctx, in.GetUserId(), in.GetUsername())
+	if err != nil {
+		b.logger.Error(ctx, "Change username failed, err: ", err.Error())
+		return err
+	}
+	b.logger.Info(ctx, "Call ProfileBiz.ChangeUsername successfully.")
+	return nil
+}
+
+func (b *ProfileBiz) GetProfileHistory(ctx context.Context, in *userinfo.GetProfileHistoryRequest, out *userinfo.GetProfileHistoryResponse) error {
+	b.logger.Info(ctx, "Call ProfileBiz.GetProfileHistory, request: ", in)
+	histories, next, err := b.profileService.GetProfileHistory(ctx, in.GetUserId(), in.GetBeforeVersion(), int(in.GetLimit()))
+	if err != nil {
+		b.logger.Error(ctx, "Get profile history failed, err: ", err.Error())
+		return err
+	}
+	out.Histories = make([]*userinfo.ProfileHistory, 0, len(histories))
+	for _, h := range histories {
+		out.Histories = append(out.Histories, toProtoProfileHistory(h))
+	}
+	out.NextBeforeVersion = next
+	b.logger.Info(ctx, "Call ProfileBiz.GetProfileHistory successfully.")
+	return nil
+}
+
+func (b *ProfileBiz) GetProfileAt(ctx context.Context, in *userinfo.GetProfileAtRequest, out *userinfo.GetProfileAtResponse) error {
+	b.logger.Info(ctx, "Call ProfileBiz.GetProfileAt, request: ", in)
+	p, version, err := b.profileService.GetProfileAt(ctx, in.GetUserId(), in.GetTimestamp())
+	if err != nil {
+		b.logger.Error(ctx, "Get profile at timestamp failed, err: ", err.Error())
+		return err
+	}
+	out.Profile = toProtoProfile(p)
+	out.Version = version
+	b.logger.Info(ctx, "Call ProfileBiz.GetProfileAt successfully.")
+	return nil
+}
+
 func (b *ProfileBiz) DeleteProfile(ctx context.Context, in *userinfo.DeleteProfileRequest, out *userinfo.DeleteProfileResponse) error {
 	b.logger.Info(ctx, "Call ProfileBiz.DeleteProfile, request: ", in)
 	id := in.GetUserId()
-	err := b.profileService.DeleteProfile(ctx, id)
+	deadline, err := b.profileService.DeleteProfile(ctx, id)
 	if err != nil {
 		b.logger.Error(ctx, "Delete profile failed, err: ", err.Error())
 		return err
 	}
+	out.RestoreDeadline = deadline
 	b.logger.Info(ctx, "Call ProfileBiz.DeleteProfile successfully.")
 	return nil
 }
 
+func (b *ProfileBiz) RestoreProfile(ctx context.Context, in *userinfo.RestoreProfileRequest, out *userinfo.RestoreProfileResponse) error {
+	b.logger.Info(ctx, "Call ProfileBiz.RestoreProfile, request: ", in)
+	err := b.profileService.RestoreProfile(ctx, in.GetUserId())
+	if err != nil {
+		b.logger.Error(ctx, "Restore profile failed, err: ", err.Error())
+		return err
+	}
+	b.logger.Info(ctx, "Call ProfileBiz.RestoreProfile successfully.")
+	return nil
+}
+
 func (b *ProfileBiz) CreateProfile(ctx context.Context, in *userinfo.CreateProfileRequest, out *userinfo.CreateProfileResponse) error {
 	b.logger.Info(ctx, "Call ProfileBiz.CreateProfile, request: ", in)
 	p := in.GetProfile()
-	mp := &model.Profile{
-		Id:        p.Id,
-		UserId:    p.UserId,
-		Username:  p.Username,
-		Birthday:  p.Birthday,
-		Email:     p.Email,
-		AvatarUrl: p.Avatar,
-	}
-	err := b.profileService.CreateProfile(ctx, mp)
+	mp := toModelProfile(p)
+	err := b.profileService.CreateProfile(ctx, mp, in.GetFailIfExists())
 	if err != nil {
 		b.logger.Error(ctx, "Create profile failed, err: ", err.Error())
 		return err
@@ -75,14 +127,7 @@ func (b *ProfileBiz) CreateProfile(ctx context.Context, in *userinfo.CreateProfi
 func (b *ProfileBiz) UpdateProfile(ctx context.Context, in *userinfo.UpdateProfileRequest, out *userinfo.UpdateProfileResponse) error {
 	b.logger.Info(ctx, "Call ProfileBiz.UpdateProfile, request: ", in)
 	p := in.GetProfile()
-	mp := &model.Profile{
-		Id:        p.Id,
-		UserId:    p.UserId,
-		Username:  p.Username,
-		Birthday:  p.Birthday,
-		Email:     p.Email,
-		AvatarUrl: p.Avatar,
-	}
+	mp := toModelProfile(p)
 	err := b.profileService.UpdateProfile(ctx, p.UserId, mp)
 	if err != nil {
 		b.logger.Error(ctx, "Update profile failed, err: ", err.Error())
@@ -91,3 +136,100 @@ func (b *ProfileBiz) UpdateProfile(ctx context.Context, in *userinfo.UpdateProfi
 	b.logger.Info(ctx, "Call ProfileBiz.UpdateProfile successfully.")
 	return nil
 }
+
+func toProtoProfile(p *model.Profile) *userinfo.Profile {
+	return &userinfo.Profile{
+		Id:         p.Id,
+		UserId:     p.UserId,
+		Username:   p.Username,
+		Birthday:   p.Birthday,
+		Email:      p.Email,
+		Avatar:     p.AvatarUrl,
+		Locale:     p.Locale,
+		Timezone:   p.Timezone,
+		Attributes: toProtoAttributes(p.Attributes),
+		Privacy:    p.Privacy,
+	}
+}
+
+func toModelProfile(p *userinfo.Profile) *model.Profile {
+	return &model.Profile{
+		Id:         p.GetId(),
+		UserId:     p.GetUserId(),
+		Username:   p.GetUsername(),
+		Birthday:   p.GetBirthday(),
+		Email:      p.GetEmail(),
+		AvatarUrl:  p.GetAvatar(),
+		Locale:     p.GetLocale(),
+		Timezone:   p.GetTimezone(),
+		Attributes: toModelAttributes(p.GetAttributes()),
+		Privacy:    p.GetPrivacy(),
+	}
+}
+
+func toProtoAttributes(attributes map[string]any) map[string]*userinfo.Value {
+	if len(attributes) == 0 {
+		return nil
+	}
+	values := make(map[string]*userinfo.Value, len(attributes))
+	for name, attribute := range attributes {
+		value := &userinfo.Value{}
+		switch v := attribute.(type) {
+		case string:
+			value.Kind = &userinfo.Value_StringValue{StringValue: v}
+		case int64:
+			value.Kind = &userinfo.Value_IntValue{IntValue: v}
+		case float64:
+			value.Kind = &userinfo.Value_DoubleValue{DoubleValue: v}
+		case bool:
+			value.Kind = &userinfo.Value_BoolValue{BoolValue: v}
+		}
+		values[name] = value
+	}
+	return values
+}
+
+// toModelAttributes converts proto values to model attributes. An empty value is converted to nil.
+func toModelAttributes(values map[string]*userinfo.Value) map[string]any {
+	if len(values) == 0 {
+		return nil
+	}
+	attributes := make(map[string]any, len(values))
+	for name, value := range values {
+		switch v := value.GetKind().(type) {
+		case *userinfo.Value_StringValue:
+			attributes[name] = v.StringValue
+		case *userinfo.Value_IntValue:
+			attributes[name] = v.IntValue
+		case *userinfo.Value_DoubleValue:
+			attributes[name] = v.DoubleValue
+		case *userinfo.Value_BoolValue:
+			attributes[name] = v.BoolValue
+		default:
+			attributes[name] = nil
+		}
+	}
+	return attributes
+}
+
+func toProtoProfileHistory(h *model.ProfileHistory) *userinfo.ProfileHistory {
+	changes := make([]*userinfo.FieldChange, 0, len(h.Changes))
+	for _, field := range h.ChangedFields() {
+		oldValue, _ := json.Marshal(h.Changes[field].Old)
+		newValue, _ := json.Marshal(h.Changes[field].New)
+		changes = append(changes, &userinfo.FieldChange{
+			Field:    field,
+			OldValue: string(oldValue),
+			NewValue: string(newValue),
+		})
+	}
+	return &userinfo.ProfileHistory{
+		Version:    h.Version,
+		UserId:     h.UserId,
+		Operation:  h.Operation,
+		Changes:    changes,
+		ActorId:    h.ActorId,
+		RequestId:  h.RequestId,
+		CreateTime: h.CreateTime,
+	}
+}
@@ -0,0 +1,116 @@
+package relation
+
+import (
+	"context"
+	"loggers"
+	"protos/userinfo"
+	"user-server/service/relation"
+)
+
+type RelationBiz struct {
+	relationService *relation.RelationService
+	logger          *logger.Logger
+}
+
+func NewRelationBiz(relationService *relation.RelationService, logger *logger.Logger) *RelationBiz {
+	return &RelationBiz{
+		relationService: relationService,
+		logger:          logger,
+	}
+}
+
+func (b *RelationBiz) Follow(ctx context.Context, in *userinfo.RelationRequest, out *userinfo.RelationResponse) error {
+	b.logger.Info(ctx, "Call RelationBiz.Follow, request: ", in)
+	return b.updateRelation(ctx, "Follow", b.relationService.Follow, in)
+}
+
+func (b *RelationBiz) Unfollow(ctx context.Context, in *userinfo.RelationRequest, out *userinfo.RelationResponse) error {
+	b.logger.Info(ctx, "Call RelationBiz.Unfollow, request: ", in)
+	return b.updateRelation(ctx, "Unfollow", b.relationService.Unfollow, in)
+}
+
+func (b *RelationBiz) RequestFriend(ctx context.Context, in *userinfo.RelationRequest, out *userinfo.RelationResponse) error {
+	b.logger.Info(ctx, "Call RelationBiz.RequestFriend, request: ", in)
+	return b.updateRelation(ctx, "RequestFriend", b.relationService.RequestFriend, in)
+}
+
+func (b *RelationBiz) AcceptFriend(ctx context.Context, in *userinfo.RelationRequest, out *userinfo.RelationResponse) error {
+	b.logger.Info(ctx, "Call RelationBiz.AcceptFriend, request: ", in)
+	return b.updateRelation(ctx, "AcceptFriend", b.relationService.AcceptFriend, in)
+}
+
+func (b *RelationBiz) RejectFriend(ctx context.Context, in *userinfo.RelationRequest, out *userinfo.RelationResponse) error {
+	b.logger.Info(ctx, "Call RelationBiz.RejectFriend, request: ", in)
+	return b.updateRelation(ctx, "RejectFriend", b.relationService.RejectFriend, in)
+}
+
+func (b *RelationBiz) Block(ctx context.Context, in *userinfo.RelationRequest, out *userinfo.RelationResponse) error {
+	b.logger.Info(ctx, "Call RelationBiz.Block, request: ", in)
+	return b.updateRelation(ctx, "Block", b.relationService.Block, in)
+}
+
+func (b *RelationBiz) Unblock(ctx context.Context, in *userinfo.RelationRequest, out *userinfo.RelationResponse) error {
+	b.logger.Info(ctx, "Call RelationBiz.Unblock, request: ", in)
+	return b.updateRelation(ctx, "Unblock", b.relationService.Unblock, in)
+}
+
+func (b *RelationBiz) ListFollowers(ctx context.Context, in *userinfo.ListRelationsRequest, out *userinfo.ListRelationsResponse) error {
+	b.logger.Info(ctx, "Call RelationBiz.ListFollowers, request: ", in)
+	return b.listRelations(ctx, relation.LIST_FOLLOWERS, in, out)
+}
+
+func (b *RelationBiz) ListFollowing(ctx context.Context, in *userinfo.ListRelationsRequest, out *userinfo.ListRelationsResponse) error {
+	b.logger.Info(ctx, "Call RelationBiz.ListFollowing, request: ", in)
+	return b.listRelations(ctx, relation.LIST_FOLLOWING, in, out)
+}
+
+func (b *RelationBiz) ListFriends(ctx context.Context, in *userinfo.ListRelationsRequest, out *userinfo.ListRelationsResponse) error {
+	b.logger.Info(ctx, "Call RelationBiz.ListFriends, request: ", in)
+	return b.listRelations(ctx, relation.LIST_FRIENDS, in, out)
+}
+
+func (b *RelationBiz) ListFriendRequests(ctx context.Context, in *userinfo.ListRelationsRequest, out *userinfo.ListRelationsResponse) error {
+	b.logger.Info(ctx, "Call RelationBiz.ListFriendRequests, request: ", in)
+	return b.listRelations(ctx, relation.LIST_FRIEND_REQUESTS, in, out)
+}
+
+func (b *RelationBiz) GetRelationCounts(ctx context.Context, in *userinfo.GetRelationCountsRequest, out *userinfo.GetRelationCountsResponse) error {
+	b.logger.Info(ctx, "Call RelationBiz.GetRelationCounts, request: ", in)
+	counts, err := b.relationService.GetCounts(ctx, in.GetUserId())
+	if err != nil {
+		b.logger.Error(ctx, "Get relation counts failed, err: ", err.Error())
+		return err
+	}
+	out.Counts = &userinfo.RelationCounts{
+		Followers:      counts.Followers,
+		Following:      counts.Following,
+		Friends:        counts.Friends,
+		FriendRequests: counts.FriendRequests,
+	}
+	b.logger.Info(ctx, "Call RelationBiz.GetRelationCounts successfully.")
+	return nil
+}
+
+func (b *RelationBiz) updateRelation(ctx context.Context, name string, update func(context.Context, uint64, uint64) error, in *userinfo.RelationRequest) error {
+	err := update(ctx, in.GetUserId(), in.GetTargetId())
+	if err != nil {
+		b.logger.Error(ctx, name, " failed, err: ", err.Error())
+		return err
+	}
+	b.logger.Info(ctx, "Call RelationBiz.", name, " successfully.")
+	return nil
+}
+
+func (b *RelationBiz) listRelations(ctx context.Context, list string, in *userinfo.ListRelationsRequest, out *userinfo.ListRelationsResponse) error {
+	userIds, next, total, err := b.relationService.ListRelations(ctx, list, in.GetUserId(), in.GetViewerId(), in.GetBeforeId(),
+		int(in.GetLimit()))
+	if err != nil {
+		b.logger.Error(ctx, "List ", list, " failed, err: ", err.Error())
+		return err
+	}
+	out.UserIds = userIds
+	out.NextBeforeId = next
+	out.Total = total
+	b.logger.Info(ctx, "List ", list, " successfully.")
+	return nil
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	MODE_CLUSTER    = "cluster"
+	MODE_STANDALONE = "standalone"
+	MODE_SENTINEL   = "sentinel"
+	MODE_MEMORY     = "memory"
+)
+
+// ErrMiss is returned by Get if the key does not exist or has expired.
+var ErrMiss = errors.New("cache miss")
+
+// ErrNotFound is returned by Loader.Load if the value does not exist in the sql DB.
+var ErrNotFound = errors.New("not found")
+
+// Cache is the key-value cache in front of the sql DB.
+type Cache interface {
+	// Get returns the value of key, or ErrMiss if there is none.
+	Get(ctx context.Context, key string) (string, error)
+	// Set saves the value of key. Expiration 0 means the key never expires.
+	Set(ctx context.Context, key string, value string, expiration time.Duration) error
+	// SetNX saves the value of key only if it does not exist, and reports whether it is saved.
+	SetNX(ctx context.Context, key string, value string, expiration time.Duration) (bool, error)
+	// Del deletes the keys, keys which do not exist are ignored.
+	Del(ctx context.Context, keys ...string) error
+	// SetBit sets the bit at offset of the bitmap in key to value, 0 or 1. The bitmap grows as needed.
+	SetBit(ctx context.Context, key string, offset uint64, value int) error
+	// GetBit returns the bit at offset of the bitmap in key, bits out of the bitmap are 0.
+	GetBit(ctx context.Context, key string, offset uint64) (int, error)
+	// Publish sends the message to the subscribers of channel in all instances.
+	Publish(ctx context.Context, channel string, message string) error
+	// Subscribe calls handle with each message of channel until ctx is done.
+	Subscribe(ctx context.Context, channel string, handle func(message string)) error
+}
+
+// Stats counts the lookups of a cache tier.
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// HitRatio returns the ratio of hits in all lookups, 0 if there is no lookup.
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/sync/singleflight"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	LOAD_LOCK_KEY_SUFFIX    = ":load_lock"
+	LOAD_LOCK_WAIT_INTERVAL = time.Millisecond * 20
+	// LOAD_TIMEOUT bounds a load shared by the misses of a key, in addition to the wait for the lock. The load is not
+	// cancelled with the request which starts it, since the others wait for it as well.
+	LOAD_TIMEOUT = time.Second * 5
+
+	// TOMBSTONE is cached for values which do not exist, so that looking them up again does not reach the sql DB.
+	TOMBSTONE            = "<tombstone>"
+	TOMBSTONE_EXPIRATION = time.Second * 10
+)
+
+// Loader rebuilds missing keys in the cache, so that a hot key expiring does not send every request to the sql DB.
+// Concurrent misses of the same key in the process share one load. If lockExpiration is positive, a short lock is
+// also taken in the cache, and the other instances wait for the value to be saved instead of loading it as well.
+type Loader struct {
+	cache          Cache
+	group          singleflight.Group
+	lockExpiration time.Duration
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func NewLoader(cache Cache, lockExpiration time.Duration) *Loader {
+	return &Loader{
+		cache:          cache,
+		lockExpiration: lockExpiration,
+	}
+}
+
+// Load returns the value of key in the cache. On a miss, the value is got from load and saved with expiration.
+// If load returns ErrNotFound, a tombstone is saved for TOMBSTONE_EXPIRATION, and ErrNotFound is returned until
+// it expires or is deleted. Failing to get from or save to the cache is not an error, the value from load is
+// returned anyway. If ctx is done before the load, its error is returned while the load goes on for the others,
+// so load must not depend on ctx either.
+func (l *Loader) Load(ctx context.Context, key string, expiration time.Duration, load func() (string, error)) (string, error) {
+	value, err := l.cache.Get(ctx, key)
+	if err == nil {
+		l.hits.Add(1)
+		return found(value)
+	}
+	l.misses.Add(1)
+	result := l.group.DoChan(key, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), l.lockExpiration+LOAD_TIMEOUT)
+		defer cancel()
+		return l.loadWithLock(loadCtx, key, expiration, load)
+	})
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r := <-result:
+		if r.Err != nil {
+			return "", r.Err
+		}
+		return r.Val.(string), nil
+	}
+}
+
+func (l *Loader) loadWithLock(ctx context.Context, key string, expiration time.Duration, load func() (string, error)) (string, error) {
+	if l.lockExpiration > 0 {
+		lockKey := key + LOAD_LOCK_KEY_SUFFIX
+		locked, err := l.cache.SetNX(ctx, lockKey, "1", l.lockExpiration)
+		if err == nil && locked {
+			defer l.cache.Del(ctx, lockKey)
+		} else if err == nil {
+			// another instance is loading, wait for its value, and load anyway if it does not come in time.
+			if value, err := l.wait(ctx, key); err == nil {
+				return found(value)
+			}
+		}
+	}
+	value, err := load()
+	if errors.Is(err, ErrNotFound) {
+		_ = l.cache.Set(ctx, key, TOMBSTONE, TOMBSTONE_EXPIRATION)
+		return "", err
+	}
+	if err != nil {
+		return "", err
+	}
+	_ = l.cache.Set(ctx, key, value, expiration)
+	return value, nil
+}
+
+// Stats returns the lookups in the cache since the loader is created.
+func (l *Loader) Stats() Stats {
+	return Stats{Hits: l.hits.Load(), Misses: l.misses.Load()}
+}
+
+func found(value string) (string, error) {
+	if value == TOMBSTONE {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+// wait polls the cache for key until the lock expires.
+func (l *Loader) wait(ctx context.Context, key string) (string, error) {
+	timer := time.NewTimer(l.lockExpiration)
+	defer timer.Stop()
+	ticker := time.NewTicker(LOAD_LOCK_WAIT_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-timer.C:
+			return "", ErrMiss
+		case <-ticker.C:
+			value, err := l.cache.Get(ctx, key)
+			if err == nil {
+				return value, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowLoad simulates a query on the sql DB and counts how many times it is called.
+func slowLoad(loads *int64) func() (string, error) {
+	return func() (string, error) {
+		atomic.AddInt64(loads, 1)
+		time.Sleep(time.Millisecond * 5)
+		return "v", nil
+	}
+}
+
+func TestLoaderCoalescesMisses(t *testing.T) {
+	ctx := context.Background()
+	loader := NewLoader(NewMemoryCache(), 0)
+	var loads int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := loader.Load(ctx, "k", time.Minute, slowLoad(&loads)); err != nil || v != "v" {
+				t.Errorf("expect v, got %v, err: %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if loads != 1 {
+		t.Errorf("expect 1 load, got %v", loads)
+	}
+}
+
+func TestLoaderWaitsForLock(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache()
+	// two loaders sharing a cache act as two instances of the service.
+	first := NewLoader(c, time.Second)
+	second := NewLoader(c, time.Second)
+	var loads int64
+	var wg sync.WaitGroup
+	for _, loader := range []*Loader{first, second} {
+		wg.Add(1)
+		go func(loader *Loader) {
+			defer wg.Done()
+			if v, err := loader.Load(ctx, "k", time.Minute, slowLoad(&loads)); err != nil || v != "v" {
+				t.Errorf("expect v, got %v, err: %v", v, err)
+			}
+		}(loader)
+	}
+	wg.Wait()
+	if loads != 1 {
+		t.Errorf("expect 1 load, got %v", loads)
+	}
+	if _, err := c.Get(ctx, "k"+LOAD_LOCK_KEY_SUFFIX); err == nil {
+		t.Errorf("expect lock released")
+	}
+}
+
+// ctxCache fails the writes with the error of ctx, as redis does.
+type ctxCache struct {
+	Cache
+}
+
+func (c ctxCache) Set(ctx context.Context, key string, value string, expiration time.Duration) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return c.Cache.Set(ctx, key, value, expiration)
+}
+
+func (c ctxCache) Del(ctx context.Context, keys ...string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return c.Cache.Del(ctx, keys...)
+}
+
+func TestLoaderOutlivesCancelledCaller(t *testing.T) {
+	c := ctxCache{NewMemoryCache()}
+	loader := NewLoader(c, time.Second)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	load := func() (string, error) {
+		close(started)
+		<-release
+		return "v", nil
+	}
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := loader.Load(first, "k", time.Minute, load)
+		firstErr <- err
+	}()
+	<-started
+	secondValue := make(chan string)
+	go func() {
+		v, err := loader.Load(context.Background(), "k", time.Minute, load)
+		if err != nil {
+			t.Errorf("expect no error of the second caller, got %v", err)
+		}
+		secondValue <- v
+	}()
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("expect the first caller cancelled, got %v", err)
+	}
+	close(release)
+	if v := <-secondValue; v != "v" {
+		t.Errorf("expect v of the second caller, got %v", v)
+	}
+	ctx := context.Background()
+	if v, err := c.Get(ctx, "k"); err != nil || v != "v" {
+		t.Errorf("expect v saved, got %v, err: %v", v, err)
+	}
+	if _, err := c.Get(ctx, "k"+LOAD_LOCK_KEY_SUFFIX); !errors.Is(err, ErrMiss) {
+		t.Errorf("expect load lock released, got %v", err)
+	}
+}
+
+func TestLoaderTombstone(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache()
+	loader := NewLoader(c, 0)
+	var loads int64
+	notFound := func() (string, error) {
+		atomic.AddInt64(&loads, 1)
+		return "", ErrNotFound
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := loader.Load(ctx, "k", time.Minute, notFound); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expect not found, got err: %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("expect 1 load, got %v", loads)
+	}
+
+	// the tombstone is deleted when the value is created.
+	_ = c.Del(ctx, "k")
+	if v, err := loader.Load(ctx, "k", time.Minute, slowLoad(&loads)); err != nil || v != "v" {
+		t.Errorf("expect v, got %v, err: %v", v, err)
+	}
+}
+
+// BenchmarkLoadOnMiss compares the loads on the sql DB when a hot key expires under concurrent requests,
+// see the loads/op metric.
+func BenchmarkLoadOnMiss(b *testing.B) {
+	ctx := context.Background()
+	b.Run("cache-aside", func(b *testing.B) {
+		c := NewMemoryCache()
+		var loads int64
+		load := slowLoad(&loads)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := c.Get(ctx, "k"); err == nil {
+					continue
+				}
+				v, _ := load()
+				_ = c.Set(ctx, "k", v, time.Millisecond)
+			}
+		})
+		b.ReportMetric(float64(loads)/float64(b.N), "loads/op")
+	})
+	b.Run("loader", func(b *testing.B) {
+		loader := NewLoader(NewMemoryCache(), 0)
+		var loads int64
+		load := slowLoad(&loads)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_, _ = loader.Load(ctx, "k", time.Millisecond, load)
+			}
+		})
+		b.ReportMetric(float64(loads)/float64(b.N), "loads/op")
+	})
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const LOCAL_EXPIRATION_DEFAULT = time.Second * 10
+
+// Local is a bounded in-process LRU in front of the shared cache, it saves the round trip and the decoding of hot
+// values. Invalidating a key drops it in every instance by broadcasting it over pub/sub of the shared cache.
+// A message may be lost while an instance reconnects, so entries also expire after a short expiration.
+// A nil Local is disabled, it never hits and invalidating does nothing.
+type Local struct {
+	mu         sync.Mutex
+	size       int
+	expiration time.Duration
+	items      map[string]*list.Element
+	order      *list.List
+
+	shared  Cache
+	channel string
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type localItem struct {
+	key      string
+	value    any
+	expireAt time.Time
+}
+
+func NewLocal(shared Cache, channel string, size int, expiration time.Duration) *Local {
+	if expiration <= 0 {
+		expiration = LOCAL_EXPIRATION_DEFAULT
+	}
+	return &Local{
+		size:       size,
+		expiration: expiration,
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+		shared:     shared,
+		channel:    channel,
+	}
+}
+
+// Get returns the value of key, the caller must not modify it.
+func (l *Local) Get(key string) (any, bool) {
+	if l == nil {
+		return nil, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	element, ok := l.items[key]
+	if !ok || time.Now().After(element.Value.(*localItem).expireAt) {
+		l.misses.Add(1)
+		return nil, false
+	}
+	l.order.MoveToFront(element)
+	l.hits.Add(1)
+	return element.Value.(*localItem).value, true
+}
+
+// Add saves the value of key, the least recently used one is evicted if the cache is full.
+func (l *Local) Add(key string, value any) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	item := &localItem{key: key, value: value, expireAt: time.Now().Add(l.expiration)}
+	if element, ok := l.items[key]; ok {
+		element.Value = item
+		l.order.MoveToFront(element)
+		return
+	}
+	l.items[key] = l.order.PushFront(item)
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*localItem).key)
+	}
+}
+
+// Invalidate drops the keys in this instance and broadcasts them to the others.
+func (l *Local) Invalidate(ctx context.Context, keys ...string) error {
+	if l == nil || len(keys) == 0 {
+		return nil
+	}
+	l.remove(keys...)
+	return l.shared.Publish(ctx, l.channel, strings.Join(keys, " "))
+}
+
+// Run drops the keys invalidated by other instances until ctx is done.
+func (l *Local) Run(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	return l.shared.Subscribe(ctx, l.channel, func(message string) {
+		l.remove(strings.Fields(message)...)
+	})
+}
+
+// Stats returns the lookups since the cache is created.
+func (l *Local) Stats() Stats {
+	if l == nil {
+		return Stats{}
+	}
+	return Stats{Hits: l.hits.Load(), Misses: l.misses.Load()}
+}
+
+func (l *Local) remove(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if element, ok := l.items[key]; ok {
+			l.order.Remove(element)
+			delete(l.items, key)
+		}
+	}
+}
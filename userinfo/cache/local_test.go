@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLocalEvictsLeastRecentlyUsed(t *testing.T) {
+	l := NewLocal(NewMemoryCache(), "invalidate", 2, time.Minute)
+	l.Add("a", 1)
+	l.Add("b", 2)
+	l.Get("a")
+	l.Add("c", 3)
+	if _, ok := l.Get("b"); ok {
+		t.Errorf("expect b evicted")
+	}
+	if v, ok := l.Get("a"); !ok || v != 1 {
+		t.Errorf("expect 1, got %v", v)
+	}
+	if stats := l.Stats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("expect 2 hits and 1 miss, got %+v", stats)
+	}
+}
+
+func TestLocalInvalidatesOtherInstances(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	shared := NewMemoryCache()
+	// two locals sharing a cache act as two instances of the service.
+	first := NewLocal(shared, "invalidate", 10, time.Minute)
+	second := NewLocal(shared, "invalidate", 10, time.Minute)
+	go func() {
+		_ = second.Run(ctx)
+	}()
+	// wait for second to subscribe.
+	for i := 0; i < 100 && subscribers(shared, "invalidate") == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	first.Add("k", 1)
+	second.Add("k", 1)
+	if err := first.Invalidate(ctx, "k"); err != nil {
+		t.Fatalf("invalidate failed, err: %v", err)
+	}
+	if _, ok := first.Get("k"); ok {
+		t.Errorf("expect k dropped in first")
+	}
+	if _, ok := second.Get("k"); ok {
+		t.Errorf("expect k dropped in second")
+	}
+}
+
+func subscribers(c *MemoryCache, channel string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.subscribers[channel])
+}
+
+func TestNilLocal(t *testing.T) {
+	var l *Local
+	l.Add("k", 1)
+	if _, ok := l.Get("k"); ok {
+		t.Errorf("expect nil local never hits")
+	}
+	if err := l.Invalidate(context.Background(), "k"); err != nil {
+		t.Errorf("expect no error, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MEMORY_CACHE_SWEEP_INTERVAL is how often expired keys are removed from a MemoryCache.
+const MEMORY_CACHE_SWEEP_INTERVAL = time.Minute
+
+type memoryItem struct {
+	value    string
+	expireAt time.Time
+}
+
+func (i *memoryItem) expired(now time.Time) bool {
+	return !i.expireAt.IsZero() && !now.Before(i.expireAt)
+}
+
+// MemoryCache is an in-process Cache for development and tests, it is not shared between instances.
+type MemoryCache struct {
+	mu          sync.Mutex
+	items       map[string]*memoryItem
+	lastSweep   time.Time
+	subscribers map[string]map[int]func(message string)
+	nextId      int
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		items:       make(map[string]*memoryItem),
+		lastSweep:   time.Now(),
+		subscribers: make(map[string]map[int]func(message string)),
+	}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[key]
+	if !ok || item.expired(time.Now()) {
+		return "", ErrMiss
+	}
+	return item.value, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value string, expiration time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	item := &memoryItem{value: value}
+	if expiration > 0 {
+		item.expireAt = now.Add(expiration)
+	}
+	c.items[key] = item
+	c.sweep(now)
+	return nil
+}
+
+func (c *MemoryCache) SetNX(ctx context.Context, key string, value string, expiration time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if item, ok := c.items[key]; ok && !item.expired(now) {
+		return false, nil
+	}
+	item := &memoryItem{value: value}
+	if expiration > 0 {
+		item.expireAt = now.Add(expiration)
+	}
+	c.items[key] = item
+	return true, nil
+}
+
+func (c *MemoryCache) Del(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.items, key)
+	}
+	return nil
+}
+
+// SetBit follows the bit order of redis, the first bit is the most significant bit of the first byte.
+func (c *MemoryCache) SetBit(ctx context.Context, key string, offset uint64, value int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[key]
+	if !ok || item.expired(time.Now()) {
+		item = &memoryItem{}
+		c.items[key] = item
+	}
+	bitmap := []byte(item.value)
+	if n := int(offset/8) + 1; len(bitmap) < n {
+		bitmap = append(bitmap, make([]byte, n-len(bitmap))...)
+	}
+	mask := byte(1) << (7 - offset%8)
+	if value == 0 {
+		bitmap[offset/8] &^= mask
+	} else {
+		bitmap[offset/8] |= mask
+	}
+	item.value = string(bitmap)
+	return nil
+}
+
+func (c *MemoryCache) GetBit(ctx context.Context, key string, offset uint64) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[key]
+	if !ok || item.expired(time.Now()) || offset/8 >= uint64(len(item.value)) {
+		return 0, nil
+	}
+	if item.value[offset/8]&(byte(1)<<(7-offset%8)) == 0 {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// Publish calls the subscribers of channel in the process synchronously.
+func (c *MemoryCache) Publish(ctx context.Context, channel string, message string) error {
+	c.mu.Lock()
+	handles := make([]func(message string), 0, len(c.subscribers[channel]))
+	for _, handle := range c.subscribers[channel] {
+		handles = append(handles, handle)
+	}
+	c.mu.Unlock()
+	for _, handle := range handles {
+		handle(message)
+	}
+	return nil
+}
+
+func (c *MemoryCache) Subscribe(ctx context.Context, channel string, handle func(message string)) error {
+	c.mu.Lock()
+	id := c.nextId
+	c.nextId++
+	if c.subscribers[channel] == nil {
+		c.subscribers[channel] = make(map[int]func(message string))
+	}
+	c.subscribers[channel][id] = handle
+	c.mu.Unlock()
+
+	<-ctx.Done()
+	c.mu.Lock()
+	delete(c.subscribers[channel], id)
+	c.mu.Unlock()
+	return ctx.Err()
+}
+
+// sweep removes expired keys at most once per MEMORY_CACHE_SWEEP_INTERVAL, so that they do not pile up.
+func (c *MemoryCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < MEMORY_CACHE_SWEEP_INTERVAL {
+		return
+	}
+	c.lastSweep = now
+	for key, item := range c.items {
+		if item.expired(now) {
+			delete(c.items, key)
+		}
+	}
+}
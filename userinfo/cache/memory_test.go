@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache()
+	if _, err := c.Get(ctx, "k"); !errors.Is(err, ErrMiss) {
+		t.Errorf("expect miss, got err: %v", err)
+	}
+
+	_ = c.Set(ctx, "k", "v", 0)
+	if v, err := c.Get(ctx, "k"); err != nil || v != "v" {
+		t.Errorf("expect v, got %v, err: %v", v, err)
+	}
+
+	_ = c.Set(ctx, "expiring", "v", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if _, err := c.Get(ctx, "expiring"); !errors.Is(err, ErrMiss) {
+		t.Errorf("expect expired, got err: %v", err)
+	}
+
+	if ok, err := c.SetNX(ctx, "k", "other", 0); err != nil || ok {
+		t.Errorf("expect existing key not overwritten, got %v, err: %v", ok, err)
+	}
+	if ok, err := c.SetNX(ctx, "expiring", "v", 0); err != nil || !ok {
+		t.Errorf("expect expired key overwritten, got %v, err: %v", ok, err)
+	}
+
+	_ = c.Del(ctx, "k", "absent")
+	if _, err := c.Get(ctx, "k"); !errors.Is(err, ErrMiss) {
+		t.Errorf("expect deleted, got err: %v", err)
+	}
+}
+
+func TestMemoryCacheBitmap(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache()
+	_ = c.SetBit(ctx, "bits", 1, 1)
+	_ = c.SetBit(ctx, "bits", 20, 1)
+	_ = c.SetBit(ctx, "bits", 20, 0)
+	for offset, expected := range map[uint64]int{0: 0, 1: 1, 20: 0, 1000: 0} {
+		if bit, err := c.GetBit(ctx, "bits", offset); err != nil || bit != expected {
+			t.Errorf("expect bit %v at %v, got %v, err: %v", expected, offset, bit, err)
+		}
+	}
+	// same bytes as SETBIT bits 1 1 in redis.
+	if v, _ := c.Get(ctx, "bits"); v[0] != 0x40 {
+		t.Errorf("expect 0x40 in first byte, got %x", v[0])
+	}
+}
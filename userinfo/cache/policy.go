@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	POLICY_SCHEMA_VERSION_DEFAULT = 1
+	POLICY_TTL_DEFAULT            = time.Second * 60
+	POLICY_JITTER_DEFAULT         = time.Second * 30
+
+	// the user id filter takes (max + 1) / 8 bytes, i.e. 16MB by default. Redis bitmaps are at most 2^32 bits.
+	POLICY_USER_ID_FILTER_MAX_DEFAULT = 1<<27 - 1
+	POLICY_USER_ID_FILTER_MAX_LIMIT   = 1<<32 - 1
+)
+
+// Policy decides whether and how long each entity is cached. The schema version is baked into the keys, so values
+// cached in a previous shape are never read after it is bumped, they just expire. User ids above UserIdFilterMax
+// are left out of the user id filter and always pass it.
+type Policy struct {
+	SchemaVersion   int
+	Profile         *EntityPolicy
+	Username        *EntityPolicy
+	RelationCounts  *EntityPolicy
+	UserIdFilterMax uint64
+}
+
+// EntityPolicy expires a key after TTL plus a random duration within Jitter, so that keys cached at the same time
+// do not expire together and cause a cache avalanche.
+type EntityPolicy struct {
+	Enabled bool
+	TTL     time.Duration
+	Jitter  time.Duration
+}
+
+// NewPolicy returns the default policy, which caches every entity.
+func NewPolicy() *Policy {
+	return &Policy{
+		SchemaVersion:   POLICY_SCHEMA_VERSION_DEFAULT,
+		Profile:         NewEntityPolicy(),
+		Username:        NewEntityPolicy(),
+		RelationCounts:  NewEntityPolicy(),
+		UserIdFilterMax: POLICY_USER_ID_FILTER_MAX_DEFAULT,
+	}
+}
+
+func NewEntityPolicy() *EntityPolicy {
+	return &EntityPolicy{
+		Enabled: true,
+		TTL:     POLICY_TTL_DEFAULT,
+		Jitter:  POLICY_JITTER_DEFAULT,
+	}
+}
+
+// Key appends the schema version to key, e.g. userinfo:get_profile:1:v1.
+func (p *Policy) Key(key string) string {
+	return fmt.Sprintf("%v:v%d", key, p.SchemaVersion)
+}
+
+func (e *EntityPolicy) Expiration() time.Duration {
+	if e.Jitter <= 0 {
+		return e.TTL
+	}
+	return e.TTL + time.Duration(rand.Int63n(int64(e.Jitter)))
+}
@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicyKey(t *testing.T) {
+	policy := NewPolicy()
+	policy.SchemaVersion = 2
+	if key := policy.Key("userinfo:get_profile:1"); key != "userinfo:get_profile:1:v2" {
+		t.Errorf("expect versioned key, got %v", key)
+	}
+}
+
+func TestEntityPolicyExpiration(t *testing.T) {
+	entity := &EntityPolicy{Enabled: true, TTL: time.Minute, Jitter: time.Second}
+	for i := 0; i < 100; i++ {
+		if expiration := entity.Expiration(); expiration < time.Minute || expiration >= time.Minute+time.Second {
+			t.Fatalf("expect expiration within jitter, got %v", expiration)
+		}
+	}
+	entity.Jitter = 0
+	if expiration := entity.Expiration(); expiration != time.Minute {
+		t.Errorf("expect ttl without jitter, got %v", expiration)
+	}
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// RedisCache is a Cache backed by redis. The client can be a cluster, standalone or sentinel client.
+type RedisCache struct {
+	client redis.UniversalClient
+}
+
+func NewRedisCache(client redis.UniversalClient) *RedisCache {
+	return &RedisCache{
+		client: client,
+	}
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) (string, error) {
+	value, err := c.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrMiss
+	}
+	return value, err
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value string, expiration time.Duration) error {
+	return c.client.Set(ctx, key, value, expiration).Err()
+}
+
+func (c *RedisCache) SetNX(ctx context.Context, key string, value string, expiration time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, key, value, expiration).Result()
+}
+
+func (c *RedisCache) Del(ctx context.Context, keys ...string) error {
+	// keys may be in different slots of a cluster, so they are deleted one by one.
+	for _, key := range keys {
+		if err := c.client.Del(ctx, key).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *RedisCache) SetBit(ctx context.Context, key string, offset uint64, value int) error {
+	return c.client.SetBit(ctx, key, int64(offset), value).Err()
+}
+
+func (c *RedisCache) GetBit(ctx context.Context, key string, offset uint64) (int, error) {
+	bit, err := c.client.GetBit(ctx, key, int64(offset)).Result()
+	return int(bit), err
+}
+
+func (c *RedisCache) Publish(ctx context.Context, channel string, message string) error {
+	return c.client.Publish(ctx, channel, message).Err()
+}
+
+func (c *RedisCache) Subscribe(ctx context.Context, channel string, handle func(message string)) error {
+	pubsub := c.client.Subscribe(ctx, channel)
+	defer pubsub.Close()
+	// wait for the subscription to be confirmed, the channel reconnects by itself afterwards.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case message, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			handle(message.Payload)
+		}
+	}
+}
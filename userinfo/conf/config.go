@@ -3,27 +3,121 @@ package conf
 import (
 	"gopkg.in/yaml.v3"
 	"os"
+	"time"
 )
 
 type Config struct {
-	MysqlMaster *Mysql `yaml:"mysql-master"`
-	MysqlSlave  *Mysql `yaml:"mysql-slave"`
-	Redis       *Redis `yaml:"redis"`
-	Etcd        *Etcd  `yaml:"etcd"`
-	Micro       *Micro `yaml:"micro"`
+	MysqlMaster       *Mysql              `yaml:"mysql-master"`
+	MysqlSlave        *Mysql              `yaml:"mysql-slave"`
+	MysqlSlaves       []*Mysql            `yaml:"mysql-slaves"`
+	MigrateOnStartup  bool                `yaml:"migrate-on-startup"`
+	Sharding          *Sharding           `yaml:"sharding"`
+	Redis             *Redis              `yaml:"redis"`
+	LocalCache        *LocalCache         `yaml:"local-cache"`
+	Cache             *Cache              `yaml:"cache"`
+	ReadRouting       *ReadRouting        `yaml:"read-routing"`
+	QueryTimeouts     *QueryTimeouts      `yaml:"query-timeouts"`
+	Admin             *Admin              `yaml:"admin"`
+	Etcd              *Etcd               `yaml:"etcd"`
+	Micro             *Micro              `yaml:"micro"`
+	ProfileAttributes []*ProfileAttribute `yaml:"profile-attributes"`
+	Username          *Username           `yaml:"username"`
 }
 
+// Mysql is a mysql server. Weight only applies to the replicas in mysql-slaves, a replica with weight 2 takes twice
+// the reads of one with weight 1, which is the default. mysql-slave is taken as the only replica if mysql-slaves is empty.
+// The pool settings, e.g. max-open-conns: 50 and conn-max-lifetime: 30m, keep the defaults of database/sql if not set.
+// Driver is one of mysql, postgres and sqlite3, the replicas must use the driver of mysql-master. For sqlite3, db is
+// the path of the database file and the replicas open the same file.
 type Mysql struct {
-	Driver   string `yaml:"driver"`
-	Name     string `yaml:"name"`
-	Password string `yaml:"password"`
-	Host     string `yaml:"host"`
-	Port     string `yaml:"port"`
-	DB       string `yaml:"db"`
+	Driver          string        `yaml:"driver"`
+	Name            string        `yaml:"name"`
+	Password        string        `yaml:"password"`
+	Host            string        `yaml:"host"`
+	Port            string        `yaml:"port"`
+	DB              string        `yaml:"db"`
+	Weight          int           `yaml:"weight"`
+	MaxOpenConns    int           `yaml:"max-open-conns"`
+	MaxIdleConns    int           `yaml:"max-idle-conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn-max-lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn-max-idle-time"`
 }
 
+// Sharding splits the users by user id into shards. user_tab, profile_tab and the other tables of a user but the
+// relations are kept in the shards, while mysql-master and mysql-slaves keep the relations, the ranges of user ids
+// of each shard and the email of each user. The service is not sharded if there is no shard.
+// New ranges take range-size user ids, e.g. 1000000, and the ranges are reloaded every refresh-interval, e.g. 10s.
+type Sharding struct {
+	Shards          []*Shard      `yaml:"shards"`
+	RangeSize       uint64        `yaml:"range-size"`
+	RefreshInterval time.Duration `yaml:"refresh-interval"`
+}
+
+// Shard is a pair of master and replicas, configured as mysql-master and mysql-slaves are. The name of a shard is
+// saved with its ranges, it must not be changed once the shard has users.
+type Shard struct {
+	Name        string   `yaml:"name"`
+	MysqlMaster *Mysql   `yaml:"mysql-master"`
+	MysqlSlaves []*Mysql `yaml:"mysql-slaves"`
+}
+
+// Redis selects the cache by mode, one of cluster, standalone, sentinel and memory. Cluster is the default.
+// Standalone uses the first of addrs, and sentinel takes the sentinel addrs together with master-name.
+// If load-lock is set, e.g. 3s, only one instance loads a missing profile while the others wait for it.
 type Redis struct {
-	Addrs []string `yaml:"addrs"`
+	Mode       string        `yaml:"mode"`
+	Addrs      []string      `yaml:"addrs"`
+	MasterName string        `yaml:"master-name"`
+	Password   string        `yaml:"password"`
+	DB         int           `yaml:"db"`
+	LoadLock   time.Duration `yaml:"load-lock"`
+}
+
+// LocalCache is the in-process cache of profiles in front of redis, it is disabled if size is 0.
+// Entries expire after expiration, e.g. 10s, in case an invalidation is lost.
+type LocalCache struct {
+	Size       int           `yaml:"size"`
+	Expiration time.Duration `yaml:"expiration"`
+}
+
+// Cache sets the policy of each cached entity. Bump schema-version when the shape of cached values is changed,
+// so that the values cached in the previous shape are not read. user-id-filter-max bounds the user ids kept in
+// the user id filter, which takes (user-id-filter-max + 1) / 8 bytes of redis, e.g. 134217727 takes 16MB.
+type Cache struct {
+	SchemaVersion   int          `yaml:"schema-version"`
+	Profile         *CacheEntity `yaml:"profile"`
+	Username        *CacheEntity `yaml:"username"`
+	RelationCounts  *CacheEntity `yaml:"relation-counts"`
+	UserIdFilterMax uint64       `yaml:"user-id-filter-max"`
+}
+
+// CacheEntity expires the cached values after ttl plus a random duration within jitter, e.g. ttl: 60s and jitter: 30s.
+type CacheEntity struct {
+	Disabled bool          `yaml:"disabled"`
+	TTL      time.Duration `yaml:"ttl"`
+	Jitter   time.Duration `yaml:"jitter"`
+}
+
+// ReadRouting pins the reads of a user to mysql-master for pin-window after the user writes, e.g. 5s. All reads go to
+// mysql-master while the replication lag of mysql-slave exceeds max-lag, e.g. 2s, which is checked every check-interval.
+type ReadRouting struct {
+	PinWindow     time.Duration `yaml:"pin-window"`
+	MaxLag        time.Duration `yaml:"max-lag"`
+	CheckInterval time.Duration `yaml:"check-interval"`
+}
+
+// QueryTimeouts bounds each DAO operation, e.g. read: 1s and write: 3s. An operation listed in operations takes
+// its own timeout instead, e.g. ProfileDao.GetProfileById: 500ms.
+type QueryTimeouts struct {
+	Read       time.Duration            `yaml:"read"`
+	Write      time.Duration            `yaml:"write"`
+	Operations map[string]time.Duration `yaml:"operations"`
+}
+
+// Admin serves the statistics of the service over http at addr, e.g. 127.0.0.1:8091. It is disabled if addr is empty.
+// It has no auth, so addr should not be reachable from outside the host.
+type Admin struct {
+	Addr string `yaml:"addr"`
 }
 
 type Etcd struct {
@@ -35,6 +129,26 @@ type Micro struct {
 	Addr string `yaml:"addr"`
 }
 
+// ProfileAttribute defines a custom profile attribute.
+// Type is one of string, int, float and bool. Visibility is one of public, logged_in and private.
+type ProfileAttribute struct {
+	Name       string   `yaml:"name"`
+	Type       string   `yaml:"type"`
+	MaxLength  int      `yaml:"max-length"`
+	Pattern    string   `yaml:"pattern"`
+	Min        *float64 `yaml:"min"`
+	Max        *float64 `yaml:"max"`
+	Enum       []string `yaml:"enum"`
+	Visibility string   `yaml:"visibility"`
+}
+
+// Username limits username changes, e.g. change-cooldown: 720h.
+// Previous usernames are reserved for reserve-period after they are changed.
+type Username struct {
+	ChangeCooldown time.Duration `yaml:"change-cooldown"`
+	ReservePeriod  time.Duration `yaml:"reserve-period"`
+}
+
 func LoadConfig(confPath string) (*Config, error) {
 	config := &Config{}
 	data, err := os.ReadFile(confPath)
@@ -0,0 +1,153 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"loggers"
+	"strings"
+	"time"
+	"user-server/model"
+)
+
+const (
+	TAB_NAME_CACHE_OUTBOX = "cache_outbox_tab"
+	// CACHE_OUTBOX_CLAIM puts off the entries being relayed by an instance, it must be longer than relaying a batch.
+	CACHE_OUTBOX_CLAIM = time.Second * 30
+)
+
+type CacheOutboxDao struct {
+	dbMaster *DBMaster
+	timeouts *Timeouts
+	logger   *logger.Logger
+}
+
+func NewCacheOutboxDao(dbMaster *DBMaster, timeouts *Timeouts, logger *logger.Logger) *CacheOutboxDao {
+	return &CacheOutboxDao{
+		dbMaster: dbMaster,
+		timeouts: timeouts,
+		logger:   logger,
+	}
+}
+
+// RelayDue claims at most limit entries which are due, and calls relay with each of them out of any transaction.
+// relay may change the stage and attempts of the entry, and returns whether it is done, or when it is due again.
+// Entries locked by other instances are skipped. The number of relayed entries is returned.
+func (d *CacheOutboxDao) RelayDue(ctx context.Context, limit int, relay func(entry *model.CacheOutboxEntry) (bool, time.Duration)) (int, error) {
+	entries, err := d.claimDue(ctx, limit)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to claim cache outbox, err: ", err.Error())
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	done := make([]bool, len(entries))
+	after := make([]time.Duration, len(entries))
+	for i, entry := range entries {
+		done[i], after[i] = relay(entry)
+	}
+
+	ctx, cancel := d.timeouts.Write(ctx, "CacheOutboxDao.RelayDue")
+	defer cancel()
+	err = d.dbMaster.WithTx(ctx, func(tx *Tx) error {
+		for i, entry := range entries {
+			var err error
+			if done[i] {
+				_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %v WHERE id = ?", TAB_NAME_CACHE_OUTBOX), entry.Id)
+			} else {
+				sqlString := fmt.Sprintf("UPDATE %v SET stage = ?, attempts = ?, next_attempt_at = %v WHERE id = ?",
+					TAB_NAME_CACHE_OUTBOX, tx.Dialect.AddMicroseconds(tx.Dialect.NowMillis()))
+				_, err = tx.ExecContext(ctx, sqlString, entry.Stage, entry.Attempts, after[i].Microseconds(), entry.Id)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// the entries are relayed again after the claim expires, deleting the keys twice does no harm.
+		d.logger.Error(ctx, "Fail to relay cache outbox, err: ", err.Error())
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// claimDue locks at most limit entries which are due and puts them off for CACHE_OUTBOX_CLAIM, so that the other
+// instances skip them while they are relayed without holding the locks. The entries of an instance which fails
+// before finishing them are relayed again after the claim expires.
+func (d *CacheOutboxDao) claimDue(ctx context.Context, limit int) ([]*model.CacheOutboxEntry, error) {
+	ctx, cancel := d.timeouts.Write(ctx, "CacheOutboxDao.RelayDue")
+	defer cancel()
+	entries := make([]*model.CacheOutboxEntry, 0, limit)
+	err := d.dbMaster.WithTx(ctx, func(tx *Tx) error {
+		sqlString := fmt.Sprintf("SELECT id, cache_key, stage, attempts FROM %v WHERE next_attempt_at <= %v"+
+			" ORDER BY next_attempt_at LIMIT ?%v", TAB_NAME_CACHE_OUTBOX, tx.Dialect.NowMillis(), tx.Dialect.ForUpdateSkipLocked())
+		rows, err := tx.QueryContext(ctx, sqlString, limit)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			entry := &model.CacheOutboxEntry{}
+			if err = rows.Scan(&entry.Id, &entry.CacheKey, &entry.Stage, &entry.Attempts); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		placeholders := make([]string, 0, len(entries))
+		args := []any{CACHE_OUTBOX_CLAIM.Microseconds()}
+		for _, entry := range entries {
+			placeholders = append(placeholders, "?")
+			args = append(args, entry.Id)
+		}
+		sqlString = fmt.Sprintf("UPDATE %v SET next_attempt_at = %v WHERE id IN (%v)", TAB_NAME_CACHE_OUTBOX,
+			tx.Dialect.AddMicroseconds(tx.Dialect.NowMillis()), strings.Join(placeholders, ","))
+		_, err = tx.ExecContext(ctx, sqlString, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetLag returns the number of pending entries, and the age of the oldest change whose key is not deleted yet.
+func (d *CacheOutboxDao) GetLag(ctx context.Context) (uint64, time.Duration, error) {
+	ctx, cancel := d.timeouts.Read(ctx, "CacheOutboxDao.GetLag")
+	defer cancel()
+	sqlDialect := d.dbMaster.Dialect
+	sqlString := fmt.Sprintf("SELECT COUNT(*), COALESCE(%v, 0) FROM %v",
+		sqlDialect.MicrosecondsBetween("MIN(CASE WHEN stage = ? THEN create_time END)", sqlDialect.NowMillis()), TAB_NAME_CACHE_OUTBOX)
+	var pending uint64
+	var lag int64
+	err := d.dbMaster.QueryRowContext(ctx, sqlString, model.CACHE_OUTBOX_STAGE_DELETE).Scan(&pending, &lag)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to get cache outbox lag, err: ", err.Error())
+		return 0, 0, err
+	}
+	return pending, time.Duration(lag) * time.Microsecond, nil
+}
+
+// insertCacheOutbox records the cache keys to delete in the transaction of the change.
+func insertCacheOutbox(ctx context.Context, tx *Tx, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	placeholders := make([]string, 0, len(keys))
+	args := make([]any, 0, len(keys))
+	for _, key := range keys {
+		placeholders = append(placeholders, "(?)")
+		args = append(args, key)
+	}
+	sqlString := fmt.Sprintf("INSERT INTO %v (cache_key) VALUES %v", TAB_NAME_CACHE_OUTBOX, strings.Join(placeholders, ","))
+	_, err := tx.ExecContext(ctx, sqlString, args...)
+	return err
+}
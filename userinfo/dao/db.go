@@ -1,12 +1,131 @@
 package dao
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+	"user-server/dialect"
+)
+
+// ErrNoHealthyReplica is returned when all replicas of mysql-slave are ejected.
+var ErrNoHealthyReplica = errors.New("no healthy replica")
 
 // DBMaster and DBSlave
 // Wrapped structs for avoiding wire’s error when there are two same types of input parameters.
 type DBMaster struct {
 	*sql.DB
+	// Dialect is the dialect of master and all replicas.
+	Dialect dialect.Dialect
+}
+
+// Tx is a transaction on master with the dialect of master.
+type Tx struct {
+	*sql.Tx
+	Dialect dialect.Dialect
 }
+
+// DBSlave is the set of read replicas of mysql-slave. A replica is picked by smooth weighted round-robin among the
+// healthy ones, the replicas are checked by DBRouter.
 type DBSlave struct {
+	mu       sync.Mutex
+	replicas []*Replica
+}
+
+// Replica is a read replica of mysql-slave. It is healthy when it is created, and it is ejected by DBRouter when it
+// can not be reached or it lags too much.
+type Replica struct {
 	*sql.DB
+	Name   string
+	Weight int
+
+	healthy atomic.Bool
+	lag     atomic.Int64
+	// current is the running weight of smooth weighted round-robin, guarded by DBSlave.mu.
+	current int
+	// passes counts the consecutive good checks of an ejected replica, it is only used by DBRouter.Run.
+	passes int
+}
+
+func NewDBSlave(replicas ...*Replica) *DBSlave {
+	return &DBSlave{replicas: replicas}
+}
+
+// NewReplica creates a replica, weight less than 1 is taken as 1.
+func NewReplica(db *sql.DB, name string, weight int) *Replica {
+	if weight < 1 {
+		weight = 1
+	}
+	replica := &Replica{DB: db, Name: name, Weight: weight}
+	replica.healthy.Store(true)
+	return replica
+}
+
+// Healthy reports whether the replica takes reads.
+func (r *Replica) Healthy() bool {
+	return r.healthy.Load()
+}
+
+// Eject stops the replica from taking reads until it is readmitted by DBRouter.
+func (r *Replica) Eject() {
+	r.healthy.Store(false)
+}
+
+// Lag returns the replication lag of the replica at the last check.
+func (r *Replica) Lag() time.Duration {
+	return time.Duration(r.lag.Load())
+}
+
+// Replicas returns all replicas, including the ejected ones.
+func (db *DBSlave) Replicas() []*Replica {
+	return db.replicas
+}
+
+// Pick returns a healthy replica, the replicas with larger weight are picked more often but not in a row.
+// ErrNoHealthyReplica is returned if all replicas are ejected.
+func (db *DBSlave) Pick() (*sql.DB, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var picked *Replica
+	total := 0
+	for _, replica := range db.replicas {
+		if !replica.Healthy() {
+			continue
+		}
+		replica.current += replica.Weight
+		total += replica.Weight
+		if picked == nil || replica.current > picked.current {
+			picked = replica
+		}
+	}
+	if picked == nil {
+		return nil, ErrNoHealthyReplica
+	}
+	picked.current -= total
+	return picked.DB, nil
+}
+
+// Close closes all replicas.
+func (db *DBSlave) Close() error {
+	var err error
+	for _, replica := range db.replicas {
+		err = errors.Join(err, replica.Close())
+	}
+	return err
+}
+
+// WithTx runs fn in a transaction on master. The transaction is committed if fn returns nil, otherwise rolled back.
+// The transaction is rolled back if ctx is done before it is committed.
+func (db *DBMaster) WithTx(ctx context.Context, fn func(tx *Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(&Tx{Tx: tx, Dialect: db.Dialect}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
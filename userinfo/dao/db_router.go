@@ -0,0 +1,164 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"loggers"
+	"time"
+	"user-server/cache"
+	"user-server/dialect"
+)
+
+const (
+	REDIS_KEY_PIN_MASTER_PREFIX = "userinfo:pin_master:"
+
+	DB_ROUTER_PIN_WINDOW_DEFAULT     = time.Second * 5
+	DB_ROUTER_MAX_LAG_DEFAULT        = time.Second * 2
+	DB_ROUTER_CHECK_INTERVAL_DEFAULT = time.Second
+	// an ejected replica is readmitted after passing the checks in a row, so that a flapping one is kept out.
+	DB_ROUTER_READMIT_PASSES = 3
+)
+
+// DBRouter picks mysql-master or mysql-slave to read from. After the data of a user is written, the reads of it are
+// pinned to mysql-master for pinWindow, so that the writes are read back even if mysql-slave lags behind. The pins are
+// kept in the cache to be shared by all instances. A replica of mysql-slave is ejected while it can not be reached,
+// or its replication lag exceeds maxLag or can not be told. All reads go to mysql-master if all replicas are ejected.
+type DBRouter struct {
+	dbMaster  *DBMaster
+	dbSlave   *DBSlave
+	dbCache   cache.Cache
+	pinWindow time.Duration
+	maxLag    time.Duration
+	logger    *logger.Logger
+}
+
+func NewDBRouter(dbMaster *DBMaster, dbSlave *DBSlave, dbCache cache.Cache, pinWindow time.Duration, maxLag time.Duration,
+	logger *logger.Logger) *DBRouter {
+	if pinWindow <= 0 {
+		pinWindow = DB_ROUTER_PIN_WINDOW_DEFAULT
+	}
+	if maxLag <= 0 {
+		maxLag = DB_ROUTER_MAX_LAG_DEFAULT
+	}
+	return &DBRouter{
+		dbMaster:  dbMaster,
+		dbSlave:   dbSlave,
+		dbCache:   dbCache,
+		pinWindow: pinWindow,
+		maxLag:    maxLag,
+		logger:    logger,
+	}
+}
+
+// PinMaster pins the reads of the data of the users to mysql-master, it must be called after the data is written.
+func (r *DBRouter) PinMaster(ctx context.Context, userIds ...uint64) {
+	for _, userId := range userIds {
+		if userId == 0 {
+			continue
+		}
+		err := r.dbCache.Set(ctx, pinMasterKey(userId), "1", r.pinWindow)
+		if err != nil {
+			r.logger.Error(ctx, "Fail to pin reads to master, err: ", err.Error())
+		}
+	}
+}
+
+// ReadDB returns the DB for the reads of the data of userId, and whether the reads are pinned to mysql-master.
+// Pinned reads should bypass the cache, since it may hold the data read from mysql-slave before the write.
+func (r *DBRouter) ReadDB(ctx context.Context, userId uint64) (*sql.DB, bool) {
+	if userId == 0 {
+		return r.defaultDB(), false
+	}
+	_, err := r.dbCache.Get(ctx, pinMasterKey(userId))
+	if err == nil {
+		r.logger.Info(ctx, "Reads are pinned to master.")
+		return r.dbMaster.DB, true
+	}
+	if !errors.Is(err, cache.ErrMiss) {
+		// the write may not be seen in mysql-slave, so master is safer.
+		r.logger.Error(ctx, "Fail to get pin of reads, err: ", err.Error())
+		return r.dbMaster.DB, true
+	}
+	return r.defaultDB(), false
+}
+
+// Dialect returns the dialect of mysql-master and mysql-slave.
+func (r *DBRouter) Dialect() dialect.Dialect {
+	return r.dbMaster.Dialect
+}
+
+func (r *DBRouter) defaultDB() *sql.DB {
+	db, err := r.dbSlave.Pick()
+	if err != nil {
+		return r.dbMaster.DB
+	}
+	return db
+}
+
+// Run checks the replicas of mysql-slave every interval until ctx is done. Each check must finish within interval.
+func (r *DBRouter) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DB_ROUTER_CHECK_INTERVAL_DEFAULT
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, replica := range r.dbSlave.Replicas() {
+			r.check(ctx, replica, interval)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check ejects the replica at once if it fails the check, and readmits it after DB_ROUTER_READMIT_PASSES checks passed.
+func (r *DBRouter) check(ctx context.Context, replica *Replica, timeout time.Duration) {
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	lag, err := r.dbMaster.Dialect.ReplicationLag(checkCtx, replica.DB)
+	replica.lag.Store(int64(lag))
+	if err == nil && lag > r.maxLag {
+		err = fmt.Errorf("lag %v exceeds %v", lag, r.maxLag)
+	}
+	if err != nil {
+		replica.passes = 0
+		if replica.healthy.Swap(false) {
+			r.logger.Warning(ctx, "Replica is ejected, replica: ", replica.Name, ", err: ", err.Error())
+			r.warnIfNoReplica(ctx)
+		}
+		return
+	}
+	if replica.Healthy() {
+		return
+	}
+	replica.passes++
+	if replica.passes >= DB_ROUTER_READMIT_PASSES {
+		replica.passes = 0
+		replica.healthy.Store(true)
+		r.logger.Info(ctx, "Replica is readmitted, replica: ", replica.Name, ", lag: ", lag)
+	}
+}
+
+func (r *DBRouter) warnIfNoReplica(ctx context.Context) {
+	for _, replica := range r.dbSlave.Replicas() {
+		if replica.Healthy() {
+			return
+		}
+	}
+	r.logger.Warning(ctx, "No healthy replica, all reads go to master.")
+}
+
+func pinMasterKey(userId uint64) string {
+	return fmt.Sprintf("%v%d", REDIS_KEY_PIN_MASTER_PREFIX, userId)
+}
+
+// actorId returns the user who sends the request.
+func actorId(ctx context.Context) uint64 {
+	traceData, _ := ctx.Value(logger.TraceDataKey{}).(logger.TraceData)
+	return traceData.UserId
+}
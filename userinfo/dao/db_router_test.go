@@ -0,0 +1,82 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"user-server/cache"
+)
+
+// unreachableCache fails the reads while fail is set, as an unreachable redis does.
+type unreachableCache struct {
+	cache.Cache
+	fail bool
+}
+
+func (c *unreachableCache) Get(ctx context.Context, key string) (string, error) {
+	if c.fail {
+		return "", errors.New("cache unreachable")
+	}
+	return c.Cache.Get(ctx, key)
+}
+
+func TestDBRouter_ReadDB(t *testing.T) {
+	cases := []struct {
+		name string
+		// pins are the users written before the read.
+		pins        []uint64
+		cacheFailed bool
+		replicaLag  time.Duration
+		userId      uint64
+		master      bool
+		pinned      bool
+	}{
+		{name: "unpinned", userId: 1},
+		{name: "pinned", pins: []uint64{1}, userId: 1, master: true, pinned: true},
+		{name: "pinned by a write of several users", pins: []uint64{2, 1}, userId: 1, master: true, pinned: true},
+		{name: "pin of another user", pins: []uint64{2}, userId: 1},
+		{name: "pin of no user", pins: []uint64{0}, userId: 0},
+		{name: "cache error", cacheFailed: true, userId: 1, master: true, pinned: true},
+		{name: "cache error without user", cacheFailed: true, userId: 0},
+		{name: "lagging replica", replicaLag: time.Second * 2, userId: 1, master: true},
+		{name: "pinned with lagging replica", pins: []uint64{1}, replicaLag: time.Second * 2, userId: 1, master: true,
+			pinned: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			router, stub, replicas := newTestReplicas(t, map[string]int{"a": 1})
+			dbCache := &unreachableCache{Cache: router.dbCache}
+			router.dbCache = dbCache
+			stub.checks[replicas["a"].DB] = replicaCheck{lag: c.replicaLag}
+			router.check(ctx, replicas["a"], time.Second)
+
+			router.PinMaster(ctx, c.pins...)
+			dbCache.fail = c.cacheFailed
+			db, pinned := router.ReadDB(ctx, c.userId)
+			expected := replicas["a"].DB
+			if c.master {
+				expected = router.dbMaster.DB
+			}
+			if db != expected || pinned != c.pinned {
+				t.Errorf("expect reads on master %v and pinned %v, got master %v and pinned %v", c.master, c.pinned,
+					db == router.dbMaster.DB, pinned)
+			}
+		})
+	}
+}
+
+func TestDBRouter_PinWindow(t *testing.T) {
+	ctx := context.Background()
+	router, _, replicas := newTestReplicas(t, map[string]int{"a": 1})
+	router.pinWindow = time.Millisecond * 50
+	router.PinMaster(ctx, 1)
+	if db, pinned := router.ReadDB(ctx, 1); db != router.dbMaster.DB || !pinned {
+		t.Fatalf("expect reads pinned to master within the pin window")
+	}
+	time.Sleep(router.pinWindow * 2)
+	if db, pinned := router.ReadDB(ctx, 1); db != replicas["a"].DB || pinned {
+		t.Errorf("expect reads on the replica after the pin window")
+	}
+}
@@ -0,0 +1,162 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+	"user-server/cache"
+	"user-server/dialect"
+)
+
+// replicaCheck is the result of the health check of a replica.
+type replicaCheck struct {
+	lag time.Duration
+	err error
+}
+
+// stubLagDialect is sqlite with the replication lag of each replica set by the test.
+type stubLagDialect struct {
+	dialect.SQLite
+	checks map[*sql.DB]replicaCheck
+}
+
+func (d *stubLagDialect) ReplicationLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	check := d.checks[db]
+	return check.lag, check.err
+}
+
+// newTestReplicas creates a router over the sqlite replicas with the weights, whose checks are stubbed by the dialect
+// of master. The replicas are named by the keys of weights.
+func newTestReplicas(t *testing.T, weights map[string]int) (*DBRouter, *stubLagDialect, map[string]*Replica) {
+	stub := &stubLagDialect{checks: make(map[*sql.DB]replicaCheck)}
+	dbMaster := newTestDB(t)
+	dbMaster.Dialect = stub
+	replicas := make(map[string]*Replica, len(weights))
+	slaves := make([]*Replica, 0, len(weights))
+	for _, name := range []string{"a", "b"} {
+		if weight, ok := weights[name]; ok {
+			replicas[name] = NewReplica(newTestDB(t).DB, name, weight)
+			slaves = append(slaves, replicas[name])
+		}
+	}
+	router := NewDBRouter(dbMaster, NewDBSlave(slaves...), cache.NewMemoryCache(), 0, time.Second, newTestLogger(t))
+	return router, stub, replicas
+}
+
+func TestDBSlave_Pick(t *testing.T) {
+	errUnreachable := errors.New("replica unreachable")
+	cases := []struct {
+		name    string
+		weights map[string]int
+		// rounds of checks of all replicas, a replica not listed passes the check.
+		rounds   []map[string]replicaCheck
+		expected map[string]int
+		err      error
+	}{
+		{
+			name:     "weighted",
+			weights:  map[string]int{"a": 3, "b": 1},
+			expected: map[string]int{"a": 6, "b": 2},
+		},
+		{
+			name:     "weight less than 1",
+			weights:  map[string]int{"a": 0, "b": 1},
+			expected: map[string]int{"a": 4, "b": 4},
+		},
+		{
+			name:     "ejected on failure",
+			weights:  map[string]int{"a": 3, "b": 1},
+			rounds:   []map[string]replicaCheck{{"b": {err: errUnreachable}}},
+			expected: map[string]int{"a": 8},
+		},
+		{
+			name:     "ejected on lag",
+			weights:  map[string]int{"a": 3, "b": 1},
+			rounds:   []map[string]replicaCheck{{"a": {lag: time.Second * 2}}},
+			expected: map[string]int{"b": 8},
+		},
+		{
+			name:    "kept out until readmitted",
+			weights: map[string]int{"a": 3, "b": 1},
+			rounds: []map[string]replicaCheck{
+				{"b": {err: errUnreachable}},
+				{},
+				{},
+			},
+			expected: map[string]int{"a": 8},
+		},
+		{
+			name:    "readmitted after passes",
+			weights: map[string]int{"a": 3, "b": 1},
+			rounds: []map[string]replicaCheck{
+				{"b": {err: errUnreachable}},
+				{},
+				{},
+				{},
+			},
+			expected: map[string]int{"a": 6, "b": 2},
+		},
+		{
+			name:    "passes reset by a failure",
+			weights: map[string]int{"a": 3, "b": 1},
+			rounds: []map[string]replicaCheck{
+				{"b": {err: errUnreachable}},
+				{},
+				{},
+				{"b": {lag: time.Second * 2}},
+				{},
+			},
+			expected: map[string]int{"a": 8},
+		},
+		{
+			name:    "no healthy replica",
+			weights: map[string]int{"a": 3, "b": 1},
+			rounds:  []map[string]replicaCheck{{"a": {err: errUnreachable}, "b": {err: errUnreachable}}},
+			err:     ErrNoHealthyReplica,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			router, stub, replicas := newTestReplicas(t, c.weights)
+			for _, round := range c.rounds {
+				for name, replica := range replicas {
+					stub.checks[replica.DB] = round[name]
+				}
+				for _, replica := range router.dbSlave.Replicas() {
+					router.check(ctx, replica, time.Second)
+				}
+			}
+
+			names := make(map[*sql.DB]string, len(replicas))
+			for name, replica := range replicas {
+				names[replica.DB] = name
+			}
+			picked := make(map[string]int)
+			for i := 0; i < 8; i++ {
+				db, err := router.dbSlave.Pick()
+				if !errors.Is(err, c.err) {
+					t.Fatalf("expect err %v, got %v", c.err, err)
+				}
+				if err != nil {
+					// the reads fall back to master.
+					if db, pinned := router.ReadDB(ctx, 0); db != router.dbMaster.DB || pinned {
+						t.Errorf("expect reads on master without a pin")
+					}
+					return
+				}
+				picked[names[db]]++
+			}
+			if len(picked) != len(c.expected) {
+				t.Fatalf("expect picks %v, got %v", c.expected, picked)
+			}
+			for name, n := range c.expected {
+				if picked[name] != n {
+					t.Errorf("expect picks %v, got %v", c.expected, picked)
+				}
+			}
+		})
+	}
+}
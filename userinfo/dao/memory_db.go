@@ -0,0 +1,185 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"loggers"
+	"sync"
+	"time"
+	"user-server/model"
+)
+
+// errDuplicateEntry is returned by the in-memory DAOs for the unique keys which have no error of their own.
+var errDuplicateEntry = errors.New("duplicate entry")
+
+// MemoryDB holds the tables of the in-memory DAOs, for tests and for running without sql DB and cache.
+// The DAOs created on the same MemoryDB share it, and each change is made under its lock as a whole, as a transaction
+// on sql DB is. Rows are copied in and out, so that callers can not modify the saved ones.
+type MemoryDB struct {
+	mu sync.Mutex
+	// now is the clock of timestamps, it is replaced in tests.
+	now func() time.Time
+	// ids are allocated per table from 1, as auto increment does.
+	ids map[string]uint64
+
+	users map[uint64]*model.User
+	// profiles are keyed by user id, including the soft deleted ones.
+	profiles          map[uint64]*memoryProfile
+	usernameHistories []*memoryUsernameHistory
+	// profileHistories are keyed by user id, in the order of version.
+	profileHistories map[uint64][]*model.ProfileHistory
+	relations        map[memoryRelationKey]uint64
+	friendRequests   map[memoryFriendRequestKey]*memoryFriendRequest
+}
+
+type memoryProfile struct {
+	profile *model.Profile
+	// deletedAt is zero if the profile is not deleted.
+	deletedAt time.Time
+}
+
+type memoryUsernameHistory struct {
+	id            uint64
+	userId        uint64
+	username      string
+	reservedUntil time.Time
+	createTime    time.Time
+}
+
+type memoryRelationKey struct {
+	userId       uint64
+	targetId     uint64
+	relationType string
+}
+
+type memoryFriendRequestKey struct {
+	fromUserId uint64
+	toUserId   uint64
+}
+
+type memoryFriendRequest struct {
+	id     uint64
+	status string
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		now:              time.Now,
+		ids:              make(map[string]uint64),
+		users:            make(map[uint64]*model.User),
+		profiles:         make(map[uint64]*memoryProfile),
+		profileHistories: make(map[uint64][]*model.ProfileHistory),
+		relations:        make(map[memoryRelationKey]uint64),
+		friendRequests:   make(map[memoryFriendRequestKey]*memoryFriendRequest),
+	}
+}
+
+func (db *MemoryDB) nextId(table string) uint64 {
+	db.ids[table]++
+	return db.ids[table]
+}
+
+// liveProfile returns the profile of the user which is not deleted, or sql.ErrNoRows.
+func (db *MemoryDB) liveProfile(userId uint64) (*model.Profile, error) {
+	row, ok := db.profiles[userId]
+	if !ok || !row.deletedAt.IsZero() {
+		return nil, sql.ErrNoRows
+	}
+	return row.profile, nil
+}
+
+// isUsernameTaken checks the profiles and reservations of other users, soft deleted profiles keep their usernames.
+func (db *MemoryDB) isUsernameTaken(userId uint64, username string) bool {
+	for _, row := range db.profiles {
+		if row.profile.Username == username && row.profile.UserId != userId {
+			return true
+		}
+	}
+	now := db.now()
+	for _, history := range db.usernameHistories {
+		if history.username == username && history.userId != userId && history.reservedUntil.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// insertProfile saves the profile and records the creation, as insertProfile does in a transaction.
+func (db *MemoryDB) insertProfile(ctx context.Context, profile *model.Profile) error {
+	if _, ok := db.profiles[profile.UserId]; ok {
+		return ErrProfileExists
+	}
+	created := &model.Profile{Id: db.nextId(TAB_NAME_PROFILE), UserId: profile.UserId}
+	created.Merge(profile)
+	created, err := copyJSON(created)
+	if err != nil {
+		return err
+	}
+	if err = db.insertProfileHistory(ctx, model.PROFILE_OPERATION_CREATE, created.UserId, nil, created); err != nil {
+		return err
+	}
+	db.profiles[created.UserId] = &memoryProfile{profile: created}
+	return nil
+}
+
+// purgeAndInsertProfile purges the soft deleted profile of the user if any, and then inserts the profile.
+func (db *MemoryDB) purgeAndInsertProfile(ctx context.Context, profile *model.Profile) error {
+	if row, ok := db.profiles[profile.UserId]; ok && !row.deletedAt.IsZero() {
+		delete(db.profiles, profile.UserId)
+	}
+	return db.insertProfile(ctx, profile)
+}
+
+// updateProfile updates the fields set in profile and records the change, as updateProfile does in a transaction.
+func (db *MemoryDB) updateProfile(ctx context.Context, userId uint64, profile *model.Profile, old *model.Profile) error {
+	if updateFields, _ := profile.UpdateFields(); len(updateFields) == 0 {
+		return nil
+	}
+	updated := old.Clone()
+	updated.Merge(profile)
+	updated, err := copyJSON(updated)
+	if err != nil {
+		return err
+	}
+	if err = db.insertProfileHistory(ctx, model.PROFILE_OPERATION_UPDATE, userId, old, updated); err != nil {
+		return err
+	}
+	db.profiles[userId].profile = updated
+	return nil
+}
+
+// insertProfileHistory records a change of profile, the actor and request id are taken from the trace data of ctx.
+func (db *MemoryDB) insertProfileHistory(ctx context.Context, operation string, userId uint64, before *model.Profile, after *model.Profile) error {
+	traceData, _ := ctx.Value(logger.TraceDataKey{}).(logger.TraceData)
+	history, err := copyJSON(&model.ProfileHistory{
+		UserId:     userId,
+		Version:    uint64(len(db.profileHistories[userId]) + 1),
+		Operation:  operation,
+		Changes:    model.DiffProfiles(before, after),
+		Snapshot:   after,
+		ActorId:    traceData.UserId,
+		RequestId:  traceData.RequestId,
+		CreateTime: db.now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	history.Id = db.nextId(TAB_NAME_PROFILE_HISTORY)
+	db.profileHistories[userId] = append(db.profileHistories[userId], history)
+	return nil
+}
+
+// copyJSON copies v through json as it is saved in sql DB, so that e.g. numbers in attributes are float64 in both.
+func copyJSON[T any](v *T) (*T, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	c := new(T)
+	if err = json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,221 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"user-server/model"
+)
+
+// MemoryProfileDao is the ProfileRepository on a MemoryDB.
+type MemoryProfileDao struct {
+	db *MemoryDB
+}
+
+func NewMemoryProfileDao(db *MemoryDB) *MemoryProfileDao {
+	return &MemoryProfileDao{db: db}
+}
+
+func (d *MemoryProfileDao) GetProfileById(ctx context.Context, userId uint64) (*model.Profile, error) {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	profile, err := d.db.liveProfile(userId)
+	if err != nil {
+		return nil, err
+	}
+	return profile.Clone(), nil
+}
+
+func (d *MemoryProfileDao) GetUserIdByUsername(ctx context.Context, username string) (uint64, error) {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	// the first profile of the username is taken, as ORDER BY id does.
+	var found *model.Profile
+	for _, row := range d.db.profiles {
+		if row.deletedAt.IsZero() && row.profile.Username == username && (found == nil || row.profile.Id < found.Id) {
+			found = row.profile
+		}
+	}
+	if found == nil {
+		return 0, sql.ErrNoRows
+	}
+	return found.UserId, nil
+}
+
+func (d *MemoryProfileDao) GetUserIdByPreviousUsername(ctx context.Context, username string) (uint64, error) {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	now := d.db.now()
+	for i := len(d.db.usernameHistories) - 1; i >= 0; i-- {
+		history := d.db.usernameHistories[i]
+		if history.username == username && history.reservedUntil.After(now) {
+			return history.userId, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (d *MemoryProfileDao) IsUsernameTaken(ctx context.Context, userId uint64, username string) (bool, error) {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	return d.db.isUsernameTaken(userId, username), nil
+}
+
+func (d *MemoryProfileDao) ChangeUsername(ctx context.Context, userId uint64, username string, policy *model.UsernamePolicy) error {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	old, err := d.db.liveProfile(userId)
+	if err != nil {
+		return err
+	}
+	previous := old.Username
+	if previous == username {
+		return nil
+	}
+	now := d.db.now()
+	for _, history := range d.db.usernameHistories {
+		if history.userId == userId && history.createTime.After(now.Add(-policy.ChangeCooldown)) {
+			return ErrUsernameCooldown
+		}
+	}
+	if d.db.isUsernameTaken(userId, username) {
+		return ErrUsernameTaken
+	}
+	if err = d.db.updateProfile(ctx, userId, &model.Profile{Username: username}, old); err != nil {
+		return err
+	}
+	if previous != "" {
+		d.db.usernameHistories = append(d.db.usernameHistories, &memoryUsernameHistory{
+			id:            d.db.nextId(TAB_NAME_USERNAME_HISTORY),
+			userId:        userId,
+			username:      previous,
+			reservedUntil: now.Add(policy.ReservePeriod),
+			createTime:    now,
+		})
+	}
+	return nil
+}
+
+// DeleteUsernameFromCache does nothing, usernames are not cached.
+func (d *MemoryProfileDao) DeleteUsernameFromCache(ctx context.Context, username string) {
+}
+
+func (d *MemoryProfileDao) Update(ctx context.Context, userId uint64, profile *model.Profile) error {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	old, err := d.db.liveProfile(userId)
+	if err != nil {
+		return err
+	}
+	if err = d.checkUsernameWrite(userId, profile.Username, old); err != nil {
+		return err
+	}
+	return d.db.updateProfile(ctx, userId, profile, old)
+}
+
+func (d *MemoryProfileDao) Upsert(ctx context.Context, profile *model.Profile) error {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	old, _ := d.db.liveProfile(profile.UserId)
+	if err := d.checkUsernameWrite(profile.UserId, profile.Username, old); err != nil {
+		return err
+	}
+	if old == nil {
+		return d.db.purgeAndInsertProfile(ctx, profile)
+	}
+	return d.db.updateProfile(ctx, profile.UserId, profile, old)
+}
+
+func (d *MemoryProfileDao) Insert(ctx context.Context, profile *model.Profile) error {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	if err := d.checkUsernameWrite(profile.UserId, profile.Username, nil); err != nil {
+		return err
+	}
+	return d.db.purgeAndInsertProfile(ctx, profile)
+}
+
+// checkUsernameWrite mirrors checkUsernameWrite of ProfileDao, old is nil if the profile is being created.
+func (d *MemoryProfileDao) checkUsernameWrite(userId uint64, username string, old *model.Profile) error {
+	if username == "" || old != nil && old.Username == username {
+		return nil
+	}
+	if old != nil && old.Username != "" {
+		return ErrUsernameSet
+	}
+	if d.db.isUsernameTaken(userId, username) {
+		return ErrUsernameTaken
+	}
+	return nil
+}
+
+func (d *MemoryProfileDao) Delete(ctx context.Context, userId uint64) error {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	old, err := d.db.liveProfile(userId)
+	if err != nil {
+		return err
+	}
+	if err = d.db.insertProfileHistory(ctx, model.PROFILE_OPERATION_DELETE, userId, old, nil); err != nil {
+		return err
+	}
+	d.db.profiles[userId].deletedAt = d.db.now()
+	return nil
+}
+
+func (d *MemoryProfileDao) Restore(ctx context.Context, userId uint64, window time.Duration) error {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	row, ok := d.db.profiles[userId]
+	if !ok || row.deletedAt.IsZero() || row.deletedAt.Before(d.db.now().Add(-window)) {
+		return sql.ErrNoRows
+	}
+	if err := d.db.insertProfileHistory(ctx, model.PROFILE_OPERATION_RESTORE, userId, nil, row.profile); err != nil {
+		return err
+	}
+	row.deletedAt = time.Time{}
+	return nil
+}
+
+// MarkProfileExists does nothing, profiles are visible once they are saved.
+func (d *MemoryProfileDao) MarkProfileExists(ctx context.Context, userId uint64) {
+}
+
+// MemoryProfileHistoryDao is the ProfileHistoryRepository on a MemoryDB.
+type MemoryProfileHistoryDao struct {
+	db *MemoryDB
+}
+
+func NewMemoryProfileHistoryDao(db *MemoryDB) *MemoryProfileHistoryDao {
+	return &MemoryProfileHistoryDao{db: db}
+}
+
+func (d *MemoryProfileHistoryDao) List(ctx context.Context, userId uint64, beforeVersion uint64, limit int) ([]*model.ProfileHistory, error) {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	saved := d.db.profileHistories[userId]
+	histories := make([]*model.ProfileHistory, 0, limit)
+	for i := len(saved) - 1; i >= 0 && len(histories) < limit; i-- {
+		if beforeVersion != 0 && saved[i].Version >= beforeVersion {
+			continue
+		}
+		history, err := copyJSON(saved[i])
+		if err != nil {
+			return nil, err
+		}
+		histories = append(histories, history)
+	}
+	return histories, nil
+}
+
+func (d *MemoryProfileHistoryDao) GetAt(ctx context.Context, userId uint64, timestamp int64) (*model.ProfileHistory, error) {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	saved := d.db.profileHistories[userId]
+	for i := len(saved) - 1; i >= 0; i-- {
+		if saved[i].CreateTime <= timestamp {
+			return copyJSON(saved[i])
+		}
+	}
+	return nil, sql.ErrNoRows
+}
@@ -0,0 +1,210 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"user-server/model"
+)
+
+// MemoryRelationDao is the RelationRepository on a MemoryDB.
+type MemoryRelationDao struct {
+	db *MemoryDB
+}
+
+func NewMemoryRelationDao(db *MemoryDB) *MemoryRelationDao {
+	return &MemoryRelationDao{db: db}
+}
+
+func (d *MemoryRelationDao) Follow(ctx context.Context, userId uint64, targetId uint64) error {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	if d.isBlocked(userId, targetId) {
+		return ErrRelationBlocked
+	}
+	d.insertRelation(userId, targetId, model.RELATION_TYPE_FOLLOW)
+	return nil
+}
+
+func (d *MemoryRelationDao) Unfollow(ctx context.Context, userId uint64, targetId uint64) error {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	delete(d.db.relations, memoryRelationKey{userId, targetId, model.RELATION_TYPE_FOLLOW})
+	return nil
+}
+
+func (d *MemoryRelationDao) RequestFriend(ctx context.Context, userId uint64, targetId uint64) error {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	if d.isBlocked(userId, targetId) {
+		return ErrRelationBlocked
+	}
+	if _, ok := d.db.relations[memoryRelationKey{userId, targetId, model.RELATION_TYPE_FRIEND}]; ok {
+		return nil
+	}
+	if d.acceptFriendRequest(targetId, userId) == nil {
+		return nil
+	}
+	key := memoryFriendRequestKey{userId, targetId}
+	if request, ok := d.db.friendRequests[key]; ok {
+		request.status = model.FRIEND_REQUEST_STATUS_PENDING
+		return nil
+	}
+	d.db.friendRequests[key] = &memoryFriendRequest{
+		id:     d.db.nextId(TAB_NAME_FRIEND_REQUEST),
+		status: model.FRIEND_REQUEST_STATUS_PENDING,
+	}
+	return nil
+}
+
+func (d *MemoryRelationDao) AcceptFriend(ctx context.Context, userId uint64, fromUserId uint64) error {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	return d.acceptFriendRequest(fromUserId, userId)
+}
+
+func (d *MemoryRelationDao) RejectFriend(ctx context.Context, userId uint64, fromUserId uint64) error {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	request, ok := d.db.friendRequests[memoryFriendRequestKey{fromUserId, userId}]
+	if !ok || request.status != model.FRIEND_REQUEST_STATUS_PENDING {
+		return sql.ErrNoRows
+	}
+	request.status = model.FRIEND_REQUEST_STATUS_REJECTED
+	return nil
+}
+
+func (d *MemoryRelationDao) Block(ctx context.Context, userId uint64, targetId uint64) error {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	d.insertRelation(userId, targetId, model.RELATION_TYPE_BLOCK)
+	for _, relationType := range []string{model.RELATION_TYPE_FOLLOW, model.RELATION_TYPE_FRIEND} {
+		delete(d.db.relations, memoryRelationKey{userId, targetId, relationType})
+		delete(d.db.relations, memoryRelationKey{targetId, userId, relationType})
+	}
+	delete(d.db.friendRequests, memoryFriendRequestKey{userId, targetId})
+	delete(d.db.friendRequests, memoryFriendRequestKey{targetId, userId})
+	return nil
+}
+
+func (d *MemoryRelationDao) Unblock(ctx context.Context, userId uint64, targetId uint64) error {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	delete(d.db.relations, memoryRelationKey{userId, targetId, model.RELATION_TYPE_BLOCK})
+	return nil
+}
+
+func (d *MemoryRelationDao) ListFollowers(ctx context.Context, userId uint64, beforeId uint64, limit int) ([]*model.Relation, error) {
+	return d.listRelations(func(key memoryRelationKey) bool {
+		return key.targetId == userId && key.relationType == model.RELATION_TYPE_FOLLOW
+	}, beforeId, limit), nil
+}
+
+func (d *MemoryRelationDao) ListFollowing(ctx context.Context, userId uint64, beforeId uint64, limit int) ([]*model.Relation, error) {
+	return d.listRelations(func(key memoryRelationKey) bool {
+		return key.userId == userId && key.relationType == model.RELATION_TYPE_FOLLOW
+	}, beforeId, limit), nil
+}
+
+func (d *MemoryRelationDao) ListFriends(ctx context.Context, userId uint64, beforeId uint64, limit int) ([]*model.Relation, error) {
+	return d.listRelations(func(key memoryRelationKey) bool {
+		return key.userId == userId && key.relationType == model.RELATION_TYPE_FRIEND
+	}, beforeId, limit), nil
+}
+
+func (d *MemoryRelationDao) ListFriendRequests(ctx context.Context, userId uint64, beforeId uint64, limit int) ([]*model.Relation, error) {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	relations := make([]*model.Relation, 0)
+	for key, request := range d.db.friendRequests {
+		if key.toUserId == userId && request.status == model.FRIEND_REQUEST_STATUS_PENDING {
+			relations = append(relations, &model.Relation{Id: request.id, UserId: key.fromUserId, TargetId: key.toUserId})
+		}
+	}
+	return pageRelations(relations, beforeId, limit), nil
+}
+
+func (d *MemoryRelationDao) GetCounts(ctx context.Context, userId uint64) (*model.RelationCounts, error) {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	counts := &model.RelationCounts{}
+	for key := range d.db.relations {
+		switch {
+		case key.relationType == model.RELATION_TYPE_FOLLOW && key.targetId == userId:
+			counts.Followers++
+		case key.relationType == model.RELATION_TYPE_FOLLOW && key.userId == userId:
+			counts.Following++
+		case key.relationType == model.RELATION_TYPE_FRIEND && key.userId == userId:
+			counts.Friends++
+		}
+	}
+	for key, request := range d.db.friendRequests {
+		if key.toUserId == userId && request.status == model.FRIEND_REQUEST_STATUS_PENDING {
+			counts.FriendRequests++
+		}
+	}
+	return counts, nil
+}
+
+func (d *MemoryRelationDao) IsBlocked(ctx context.Context, userId uint64, targetId uint64) (bool, error) {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	_, blocked := d.db.relations[memoryRelationKey{userId, targetId, model.RELATION_TYPE_BLOCK}]
+	return blocked, nil
+}
+
+func (d *MemoryRelationDao) listRelations(match func(key memoryRelationKey) bool, beforeId uint64, limit int) []*model.Relation {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	relations := make([]*model.Relation, 0)
+	for key, id := range d.db.relations {
+		if match(key) {
+			relations = append(relations, &model.Relation{Id: id, UserId: key.userId, TargetId: key.targetId})
+		}
+	}
+	return pageRelations(relations, beforeId, limit)
+}
+
+// acceptFriendRequest accepts the pending request and saves the friendship in both directions.
+func (d *MemoryRelationDao) acceptFriendRequest(fromUserId uint64, toUserId uint64) error {
+	request, ok := d.db.friendRequests[memoryFriendRequestKey{fromUserId, toUserId}]
+	if !ok || request.status != model.FRIEND_REQUEST_STATUS_PENDING {
+		return sql.ErrNoRows
+	}
+	request.status = model.FRIEND_REQUEST_STATUS_ACCEPTED
+	d.insertRelation(fromUserId, toUserId, model.RELATION_TYPE_FRIEND)
+	d.insertRelation(toUserId, fromUserId, model.RELATION_TYPE_FRIEND)
+	return nil
+}
+
+// insertRelation saves the relation unless it exists, as an insert ignoring the unique key does.
+func (d *MemoryRelationDao) insertRelation(userId uint64, targetId uint64, relationType string) {
+	key := memoryRelationKey{userId, targetId, relationType}
+	if _, ok := d.db.relations[key]; !ok {
+		d.db.relations[key] = d.db.nextId(TAB_NAME_RELATION)
+	}
+}
+
+// isBlocked reports whether either of the users has blocked the other.
+func (d *MemoryRelationDao) isBlocked(userId uint64, targetId uint64) bool {
+	_, blocked := d.db.relations[memoryRelationKey{userId, targetId, model.RELATION_TYPE_BLOCK}]
+	_, blockedBy := d.db.relations[memoryRelationKey{targetId, userId, model.RELATION_TYPE_BLOCK}]
+	return blocked || blockedBy
+}
+
+// pageRelations returns at most limit relations with id less than beforeId, newest first.
+func pageRelations(relations []*model.Relation, beforeId uint64, limit int) []*model.Relation {
+	sort.Slice(relations, func(i, j int) bool {
+		return relations[i].Id > relations[j].Id
+	})
+	page := make([]*model.Relation, 0, limit)
+	for _, relation := range relations {
+		if len(page) == limit {
+			break
+		}
+		if beforeId == 0 || relation.Id < beforeId {
+			page = append(page, relation)
+		}
+	}
+	return page
+}
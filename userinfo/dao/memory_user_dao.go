@@ -0,0 +1,66 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"loggers"
+	"user-server/model"
+)
+
+// MemoryUserDao is the UserRepository on a MemoryDB.
+type MemoryUserDao struct {
+	db *MemoryDB
+}
+
+func NewMemoryUserDao(db *MemoryDB) *MemoryUserDao {
+	return &MemoryUserDao{db: db}
+}
+
+func (d *MemoryUserDao) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	for _, user := range d.db.users {
+		if user.Email == email {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *MemoryUserDao) GetUserById(ctx context.Context, userId uint64) (*model.User, error) {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	user, ok := d.db.users[userId]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	found := *user
+	return &found, nil
+}
+
+func (d *MemoryUserDao) InsertWithProfile(ctx context.Context, user *model.User, profile *model.Profile) (uint64, error) {
+	d.db.mu.Lock()
+	defer d.db.mu.Unlock()
+	for _, saved := range d.db.users {
+		if saved.Email == user.Email {
+			return 0, fmt.Errorf("%w '%v' for key '%v.email'", errDuplicateEntry, user.Email, TAB_NAME_USER)
+		}
+	}
+	inserted := *user
+	inserted.Id = d.db.nextId(TAB_NAME_USER)
+
+	// the new user is the actor who creates the profile.
+	traceData, _ := ctx.Value(logger.TraceDataKey{}).(logger.TraceData)
+	traceData.UserId = inserted.Id
+	profile.UserId = inserted.Id
+	if profile.Username != "" && d.db.isUsernameTaken(inserted.Id, profile.Username) {
+		return 0, ErrUsernameTaken
+	}
+	if err := d.db.insertProfile(context.WithValue(ctx, logger.TraceDataKey{}, traceData), profile); err != nil {
+		return 0, err
+	}
+	d.db.users[inserted.Id] = &inserted
+	return inserted.Id, nil
+}
@@ -2,114 +2,402 @@ package dao
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/redis/go-redis/v9"
 	"loggers"
-	"math/rand"
+	"strconv"
+	"sync/atomic"
 	"time"
+	"user-server/cache"
+	"user-server/dialect"
 	"user-server/model"
 )
 
 const TAB_NAME_PROFILE = "profile_tab"
+const TAB_NAME_USERNAME_HISTORY = "username_history_tab"
+const PROFILE_COLUMNS = "id, user_id, username, birthday, email, avatar_url, locale, timezone, attributes, privacy"
 const (
-	REDIS_KEY_GET_PROFILE_PREFIX           = "userinfo:get_profile:"
-	REDIS_KEY_GET_PROFILE_EXPIRE_BASE      = time.Second * 60
-	REDIS_KEY_GET_PROFILE_EXPIRE_MAX_SHIFT = 30
+	// keys are versioned by cache.Policy before they are used, and expire as the policy says.
+	REDIS_KEY_GET_PROFILE_PREFIX             = "userinfo:get_profile:"
+	REDIS_KEY_GET_USER_ID_BY_USERNAME_PREFIX = "userinfo:get_user_id_by_username:"
+
+	// REDIS_CHANNEL_INVALIDATE_PROFILE broadcasts the keys of changed profiles to the local caches of all instances.
+	REDIS_CHANNEL_INVALIDATE_PROFILE = "userinfo:invalidate_profile"
+)
+
+var PROFILE_UNIQUE_KEY_USER_ID = dialect.UniqueKey{Table: TAB_NAME_PROFILE, Name: "uk_user_id", Columns: []string{"user_id"}}
+
+// ErrProfileExists is returned when inserting a profile for a user who already has one.
+var ErrProfileExists = errors.New("profile exists")
+
+var (
+	// ErrUsernameTaken is returned when the username is used or reserved by another user.
+	ErrUsernameTaken = errors.New("username taken")
+	// ErrUsernameCooldown is returned when the username is changed again within the cooldown.
+	ErrUsernameCooldown = errors.New("username changed within cooldown")
+	// ErrUsernameSet is returned when a profile write would change a username which is set, only ChangeUsername can.
+	ErrUsernameSet = errors.New("username set")
 )
 
 type ProfileDao struct {
 	dbMaster *DBMaster
 	dbSlave  *DBSlave
-	dbRedis  *redis.ClusterClient
+	dbCache  cache.Cache
+	loader   *cache.Loader
+	// local is nil if the local cache is disabled.
+	local    *cache.Local
+	policy   *cache.Policy
+	router   *DBRouter
+	timeouts *Timeouts
 	logger   *logger.Logger
+	// filterSlaves are scanned to rebuild the user id filter, which are the replicas of all shards if sharded.
+	filterSlaves []*DBSlave
+	// filterRebuildStarted is the unix nanoseconds when a rebuild of the user id filter was last started in the
+	// background, it is shared by the shards.
+	filterRebuildStarted *atomic.Int64
 }
 
-func NewProfileDao(dbMaster *DBMaster, dbSlave *DBSlave, dbRedis *redis.ClusterClient, logger *logger.Logger) *ProfileDao {
+func NewProfileDao(dbMaster *DBMaster, dbSlave *DBSlave, dbCache cache.Cache, loader *cache.Loader, local *cache.Local,
+	policy *cache.Policy, router *DBRouter, timeouts *Timeouts, logger *logger.Logger) *ProfileDao {
 	return &ProfileDao{
 		dbMaster: dbMaster,
 		dbSlave:  dbSlave,
-		dbRedis:  dbRedis,
+		dbCache:  dbCache,
+		loader:   loader,
+		local:    local,
+		policy:   policy,
+		router:   router,
+		timeouts: timeouts,
 		logger:   logger,
+
+		filterSlaves:         []*DBSlave{dbSlave},
+		filterRebuildStarted: &atomic.Int64{},
 	}
 }
 
 func (d *ProfileDao) GetProfileById(ctx context.Context, userId uint64) (*model.Profile, error) {
 	d.logger.Info(ctx, "Call ProfileDao.GetProfile.")
+	ctx, cancel := d.timeouts.Read(ctx, "ProfileDao.GetProfileById")
+	defer cancel()
 	profile := &model.Profile{}
 
-	// 1. try to get value from redis first.
-	rKey := fmt.Sprintf("%v%d", REDIS_KEY_GET_PROFILE_PREFIX, userId)
-	profileStr, err := d.dbRedis.Get(ctx, rKey).Result()
-	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			d.logger.Info(ctx, "Can not find in cache, go to sql DB.")
-		} else {
-			d.logger.Error(ctx, "Can not get from cache, err: ", err.Error(), ". Go to sql DB")
+	// 1. try to get value from the local cache first. A write drops the profile from the local caches before it
+	// returns, so the pin in redis is only looked up on a miss.
+	rKey := d.policy.Key(profileCacheKey(userId))
+	if d.policy.Profile.Enabled {
+		if cached, ok := d.local.Get(rKey); ok {
+			d.logger.Info(ctx, "Get profile from local cache succeeded.")
+			return cached.(*model.Profile).Clone(), nil
 		}
-	} else {
-		d.logger.Info(ctx, "Get profile json from cache, profile: ", profileStr)
-		err = json.Unmarshal([]byte(profileStr), profile)
+	}
+
+	readDB, pinned := d.router.ReadDB(ctx, userId)
+	if pinned || !d.policy.Profile.Enabled {
+		d.logger.Info(ctx, "Profile cache is bypassed, go to sql DB.")
+		profile, err := d.selectProfile(ctx, readDB, userId)
 		if err != nil {
-			d.logger.Error(ctx, "json.Unmarshal failed, err: ", err.Error(), ". Go to sql DB")
-		} else {
-			d.logger.Info(ctx, "Get profile from cache succeeded.")
-			return profile, nil
+			d.logger.Error(ctx, "Fail to scan data, err: ", err.Error())
+			return nil, err
 		}
+		return profile, nil
 	}
 
-	// 2. get value from mysql-slave if not found in redis.
-	sqlString := fmt.Sprintf("SELECT id, user_id, username, birthday, email, avatar_url"+
-		" FROM %v WHERE user_id = ?", TAB_NAME_PROFILE)
-	row := d.dbSlave.QueryRow(sqlString, userId)
-
-	err = row.Scan(
-		&profile.Id,
-		&profile.UserId,
-		&profile.Username,
-		&profile.Birthday,
-		&profile.Email,
-		&profile.AvatarUrl,
-	)
+	// 2. then try redis, or load it from sql DB and write back to redis.
+	// concurrent misses of the same profile are loaded once, see cache.Loader. The load is shared by them, so it is
+	// not cancelled together with this request.
+	loadCtx, cancelLoad := d.timeouts.Read(context.WithoutCancel(ctx), "ProfileDao.GetProfileById")
+	defer cancelLoad()
+	profileStr, err := d.loader.Load(ctx, rKey, d.policy.Profile.Expiration(), func() (string, error) {
+		d.logger.Info(loadCtx, "Can not find in cache, go to sql DB.")
+		if !d.mayHaveProfile(loadCtx, userId) {
+			d.logger.Info(loadCtx, "User id is not in the filter, skip sql DB.")
+			return "", cache.ErrNotFound
+		}
+		profile, err := d.selectProfile(loadCtx, readDB, userId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", cache.ErrNotFound
+		}
+		if err != nil {
+			return "", err
+		}
+		// write profile as json string back to cache.
+		pBytes, err := json.Marshal(profile)
+		if err != nil {
+			return "", err
+		}
+		return string(pBytes), nil
+	})
+	if errors.Is(err, cache.ErrNotFound) {
+		// not found is cached as a tombstone, see cache.Loader.
+		d.logger.Info(ctx, "Profile does not exist.")
+		return nil, sql.ErrNoRows
+	}
 	if err != nil {
-		d.logger.Error(ctx, "Fail to scan data, err: ", err.Error())
+		d.logger.Error(ctx, "Fail to load profile, err: ", err.Error())
 		return nil, err
 	}
+	err = json.Unmarshal([]byte(profileStr), profile)
+	if err != nil {
+		// 3. the cached value can not be decoded, e.g. its shape is changed without bumping the schema version.
+		// delete it so that it is cached again by the next read, and get value from sql DB this time.
+		d.logger.Error(ctx, "json.Unmarshal failed, err: ", err.Error(), ". Go to sql DB")
+		d.deleteFromCache(ctx, userId)
+		profile, err = d.selectProfile(ctx, readDB, userId)
+		if err != nil {
+			d.logger.Error(ctx, "Fail to scan data, err: ", err.Error())
+			return nil, err
+		}
+		return profile, nil
+	}
 	d.logger.Info(ctx, "Get profile done, profile: ", profile)
+	d.local.Add(rKey, profile.Clone())
+	return profile, nil
+}
 
-	// 3. write profile as json string back to cache.
-	pBytes, err := json.Marshal(profile)
+// WarmCache writes at most limit profiles with id greater than afterId from mysql-slave to the cache, in the order
+// of id. Profiles cached by reads meanwhile are kept. The id of the last profile and the number of profiles are
+// returned, fewer profiles than limit means there are no more. The scan is never moved to mysql-master, it fails with
+// ErrNoHealthyReplica instead.
+func (d *ProfileDao) WarmCache(ctx context.Context, afterId uint64, limit int) (uint64, int, error) {
+	d.logger.Info(ctx, "Call ProfileDao.WarmCache, after_id: ", afterId, ", limit: ", limit)
+	db, err := d.dbSlave.Pick()
 	if err != nil {
-		d.logger.Error(ctx, "json.Marshal failed, err: ", err.Error(), ". It will not be saved to cache.")
-		return profile, nil
+		d.logger.Error(ctx, "Fail to pick replica, err: ", err.Error())
+		return afterId, 0, err
 	}
-	// set key expiration time as base time plus random time to avoid cache avalanche.
-	randExp := time.Duration(rand.Intn(REDIS_KEY_GET_PROFILE_EXPIRE_MAX_SHIFT)) * time.Second
-	err = d.dbRedis.Set(ctx, rKey, string(pBytes), REDIS_KEY_GET_PROFILE_EXPIRE_BASE+randExp).Err()
+	sqlString := fmt.Sprintf("SELECT %v FROM %v WHERE id > ? AND deleted_at IS NULL ORDER BY id LIMIT ?", PROFILE_COLUMNS, TAB_NAME_PROFILE)
+	rows, err := db.QueryContext(ctx, sqlString, afterId, limit)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to query profiles, err: ", err.Error())
+		return afterId, 0, err
+	}
+	defer rows.Close()
+	lastId, n := afterId, 0
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			d.logger.Error(ctx, "Fail to scan data, err: ", err.Error())
+			return lastId, n, err
+		}
+		pBytes, err := json.Marshal(profile)
+		if err != nil {
+			return lastId, n, err
+		}
+		_, err = d.dbCache.SetNX(ctx, d.policy.Key(profileCacheKey(profile.UserId)), string(pBytes), d.policy.Profile.Expiration())
+		if err != nil {
+			d.logger.Error(ctx, "redis set failed, err: ", err.Error())
+			return lastId, n, err
+		}
+		lastId = profile.Id
+		n++
+	}
+	return lastId, n, rows.Err()
+}
+
+// CountProfiles returns the number of profiles with id greater than afterId which are not deleted.
+func (d *ProfileDao) CountProfiles(ctx context.Context, afterId uint64) (uint64, error) {
+	db, err := d.dbSlave.Pick()
+	if err != nil {
+		d.logger.Error(ctx, "Fail to pick replica, err: ", err.Error())
+		return 0, err
+	}
+	var count uint64
+	sqlString := fmt.Sprintf("SELECT COUNT(*) FROM %v WHERE id > ? AND deleted_at IS NULL", TAB_NAME_PROFILE)
+	err = db.QueryRowContext(ctx, sqlString, afterId).Scan(&count)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to count profiles, err: ", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
+
+// selectProfile gets the profile from db, see DBRouter.ReadDB.
+func (d *ProfileDao) selectProfile(ctx context.Context, db *sql.DB, userId uint64) (*model.Profile, error) {
+	sqlString := fmt.Sprintf("SELECT %v FROM %v WHERE user_id = ? AND deleted_at IS NULL", PROFILE_COLUMNS, TAB_NAME_PROFILE)
+	return scanProfile(db.QueryRowContext(ctx, sqlString, userId))
+}
+
+// GetUserIdByUsername finds the owner of a username. The mapping is cached, and it is verified against
+// the profile by the caller since usernames can be changed.
+func (d *ProfileDao) GetUserIdByUsername(ctx context.Context, username string) (uint64, error) {
+	d.logger.Info(ctx, "Call ProfileDao.GetUserIdByUsername, username: ", username)
+	ctx, cancel := d.timeouts.Read(ctx, "ProfileDao.GetUserIdByUsername")
+	defer cancel()
+
+	// 1. try to get value from redis first.
+	rKey := d.policy.Key(usernameCacheKey(username))
+	// the owner is not known before the read, so it is pinned if the requester has just changed their username.
+	readDB, pinned := d.router.ReadDB(ctx, actorId(ctx))
+	useCache := d.policy.Username.Enabled && !pinned
+	if useCache {
+		userIdStr, err := d.dbCache.Get(ctx, rKey)
+		if err == nil {
+			userId, err := strconv.ParseUint(userIdStr, 10, 64)
+			if err == nil {
+				d.logger.Info(ctx, "Get user id from cache succeeded, user_id: ", userId)
+				return userId, nil
+			}
+			d.logger.Error(ctx, "Can not parse user id from cache, err: ", err.Error(), ". Go to sql DB")
+			d.DeleteUsernameFromCache(ctx, username)
+		} else if !errors.Is(err, cache.ErrMiss) {
+			d.logger.Error(ctx, "Can not get from cache, err: ", err.Error(), ". Go to sql DB")
+		}
+	}
+
+	// 2. get value from sql DB if not found in redis.
+	sqlString := fmt.Sprintf("SELECT user_id FROM %v WHERE username = ? AND deleted_at IS NULL ORDER BY id LIMIT 1", TAB_NAME_PROFILE)
+	var userId uint64
+	err := readDB.QueryRowContext(ctx, sqlString, username).Scan(&userId)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to scan data, err: ", err.Error())
+		return 0, err
+	}
+
+	// 3. write back to cache.
+	if !useCache {
+		return userId, nil
+	}
+	err = d.dbCache.Set(ctx, rKey, strconv.FormatUint(userId, 10), d.policy.Username.Expiration())
 	if err != nil {
 		d.logger.Error(ctx, "redis set failed, err: ", err.Error(), ". It will not be saved to cache.")
-		return profile, nil
 	}
-	d.logger.Info(ctx, "Write back to redis done. key: ", rKey, ", value: ", string(pBytes),
-		", expiration: ", REDIS_KEY_GET_PROFILE_EXPIRE_BASE+randExp)
+	return userId, nil
+}
 
-	return profile, nil
+// GetUserIdByPreviousUsername finds the user who used the username and still reserves it.
+func (d *ProfileDao) GetUserIdByPreviousUsername(ctx context.Context, username string) (uint64, error) {
+	d.logger.Info(ctx, "Call ProfileDao.GetUserIdByPreviousUsername, username: ", username)
+	ctx, cancel := d.timeouts.Read(ctx, "ProfileDao.GetUserIdByPreviousUsername")
+	defer cancel()
+	sqlString := fmt.Sprintf("SELECT user_id FROM %v WHERE username = ? AND reserved_until > %v"+
+		" ORDER BY id DESC LIMIT 1", TAB_NAME_USERNAME_HISTORY, d.dbMaster.Dialect.Now())
+	var userId uint64
+	readDB, _ := d.router.ReadDB(ctx, actorId(ctx))
+	err := readDB.QueryRowContext(ctx, sqlString, username).Scan(&userId)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to scan data, err: ", err.Error())
+		return 0, err
+	}
+	return userId, nil
 }
 
+// IsUsernameTaken checks whether the username is used or reserved by users other than userId.
+func (d *ProfileDao) IsUsernameTaken(ctx context.Context, userId uint64, username string) (bool, error) {
+	d.logger.Info(ctx, "Call ProfileDao.IsUsernameTaken, username: ", username)
+	ctx, cancel := d.timeouts.Read(ctx, "ProfileDao.IsUsernameTaken")
+	defer cancel()
+	var count int
+	readDB, _ := d.router.ReadDB(ctx, userId)
+	err := readDB.QueryRowContext(ctx, usernameTakenSql(d.dbMaster.Dialect, false), username, userId, username, userId).Scan(&count)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to scan data, err: ", err.Error())
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ChangeUsername changes the username of userId, and reserves the previous one for the user.
+// ErrUsernameTaken, ErrUsernameCooldown or sql.ErrNoRows (the user has no profile) may be returned.
+func (d *ProfileDao) ChangeUsername(ctx context.Context, userId uint64, username string, policy *model.UsernamePolicy) error {
+	d.logger.Info(ctx, "Call ProfileDao.ChangeUsername, username: ", username)
+	ctx, cancel := d.timeouts.Write(ctx, "ProfileDao.ChangeUsername")
+	defer cancel()
+	var previous string
+	err := d.dbMaster.WithTx(ctx, func(tx *Tx) error {
+		old, err := selectProfileForUpdate(ctx, tx, userId)
+		if err != nil {
+			return err
+		}
+		previous = old.Username
+		if previous == username {
+			return nil
+		}
+
+		var count int
+		sqlString := fmt.Sprintf("SELECT COUNT(*) FROM %v WHERE user_id = ? AND create_time > %v",
+			TAB_NAME_USERNAME_HISTORY, tx.Dialect.AddSeconds(tx.Dialect.Now()))
+		err = tx.QueryRowContext(ctx, sqlString, userId, -int64(policy.ChangeCooldown.Seconds())).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrUsernameCooldown
+		}
+		if err = lockUsername(ctx, tx, userId, username); err != nil {
+			return err
+		}
+
+		if previous != "" {
+			sqlString = fmt.Sprintf("INSERT INTO %v (user_id, username, reserved_until) VALUES (?,?,%v)",
+				TAB_NAME_USERNAME_HISTORY, tx.Dialect.AddSeconds(tx.Dialect.Now()))
+			_, err = tx.ExecContext(ctx, sqlString, userId, previous, int64(policy.ReservePeriod.Seconds()))
+			if err != nil {
+				return err
+			}
+		}
+		keys := []string{usernameCacheKey(username)}
+		if previous != "" {
+			keys = append(keys, usernameCacheKey(previous))
+		}
+		if err = insertCacheOutbox(ctx, tx, keys...); err != nil {
+			return err
+		}
+		return updateProfile(ctx, tx, userId, &model.Profile{Username: username}, old)
+	})
+	if err != nil {
+		d.logger.Error(ctx, "Fail to change username, err: ", err.Error())
+		return err
+	}
+	d.logger.Info(ctx, "Change username in sql DB succeed, previous username: ", previous)
+	d.router.PinMaster(ctx, userId)
+
+	d.deleteFromCache(ctx, userId)
+	if previous != "" {
+		d.DeleteUsernameFromCache(ctx, previous)
+	}
+	d.DeleteUsernameFromCache(ctx, username)
+
+	return nil
+}
+
+// DeleteUsernameFromCache removes a stale username mapping.
+func (d *ProfileDao) DeleteUsernameFromCache(ctx context.Context, username string) {
+	rKey := d.policy.Key(usernameCacheKey(username))
+	err := d.dbCache.Del(ctx, rKey)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to delete from cache, err: ", err.Error())
+	}
+}
+
+// Update updates the fields set in profile, sql.ErrNoRows is returned if the user has no profile.
 func (d *ProfileDao) Update(ctx context.Context, userId uint64, profile *model.Profile) error {
+	ctx, cancel := d.timeouts.Write(ctx, "ProfileDao.Update")
+	defer cancel()
 	// use cache aside pattern to update DB and then delete from cache.
-	// 1. update data to mysql-master.
+	// 1. update data to mysql-master, and record the change in the same transaction.
 	d.logger.Info(ctx, "Call ProfileDao.Update.")
-	updateFields, args := profile.UpdateFields()
-	sqlString := profile.UpdateSql(updateFields, TAB_NAME_PROFILE)
-	d.logger.Debug(ctx, "sql: ", sqlString)
-	_, err := d.dbMaster.Exec(sqlString, append(args, userId)...)
+	err := d.dbMaster.WithTx(ctx, func(tx *Tx) error {
+		old, err := selectProfileForUpdate(ctx, tx, userId)
+		if err != nil {
+			return err
+		}
+		if err = checkUsernameWrite(ctx, tx, userId, profile.Username, old); err != nil {
+			return err
+		}
+		return updateProfile(ctx, tx, userId, profile, old)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		d.logger.Warning(ctx, "Profile does not exist, nothing to update.")
+		return err
+	}
 	if err != nil {
 		d.logger.Error(ctx, "Fail to update to sql DB, err: ", err.Error())
 		return err
 	}
 	d.logger.Info(ctx, "Update profile to sql DB succeed.")
+	d.router.PinMaster(ctx, userId)
 
 	// 2. delete data from redis.
 	d.deleteFromCache(ctx, userId)
@@ -117,17 +405,79 @@ func (d *ProfileDao) Update(ctx context.Context, userId uint64, profile *model.P
 	return nil
 }
 
+// Upsert creates the profile if the user has none, otherwise updates the existing one with the fields set in profile.
+func (d *ProfileDao) Upsert(ctx context.Context, profile *model.Profile) error {
+	d.logger.Info(ctx, "Call ProfileDao.Upsert, profile: ", profile)
+	ctx, cancel := d.timeouts.Write(ctx, "ProfileDao.Upsert")
+	defer cancel()
+	upsert := func(tx *Tx) error {
+		old, err := selectProfileForUpdate(ctx, tx, profile.UserId)
+		if errors.Is(err, sql.ErrNoRows) {
+			old, err = nil, nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = checkUsernameWrite(ctx, tx, profile.UserId, profile.Username, old); err != nil {
+			return err
+		}
+		if old == nil {
+			return purgeAndInsertProfile(ctx, tx, profile)
+		}
+		return updateProfile(ctx, tx, profile.UserId, profile, old)
+	}
+	err := d.dbMaster.WithTx(ctx, upsert)
+	if errors.Is(err, ErrProfileExists) {
+		// the profile is inserted concurrently after our select, it is updated in a second try.
+		d.logger.Warning(ctx, "Profile is created concurrently, retry to update it.")
+		err = d.dbMaster.WithTx(ctx, upsert)
+	}
+	if err != nil {
+		d.logger.Error(ctx, "Fail to upsert into sql DB, err: ", err.Error())
+		return err
+	}
+	d.logger.Info(ctx, "Upsert profile into sql DB succeed.")
+	d.router.PinMaster(ctx, profile.UserId)
+
+	d.MarkProfileExists(ctx, profile.UserId)
+
+	return nil
+}
+
+// Delete soft deletes the profile, it can be restored by Restore until it is purged.
+// sql.ErrNoRows is returned if the user has no profile.
 func (d *ProfileDao) Delete(ctx context.Context, userId uint64) error {
+	ctx, cancel := d.timeouts.Write(ctx, "ProfileDao.Delete")
+	defer cancel()
 	// use cache aside pattern to delete from DB and then delete from cache.
-	// 1. delete data from mysql-master.
+	// 1. mark data as deleted in mysql-master, and record the change in the same transaction.
 	d.logger.Info(ctx, "Call ProfileDao.Delete.")
-	sqlString := fmt.Sprintf("DELETE FROM %v WHERE user_id = ?", TAB_NAME_PROFILE)
-	_, err := d.dbMaster.Exec(sqlString, userId)
+	sqlString := fmt.Sprintf("UPDATE %v SET deleted_at = %v WHERE user_id = ? AND deleted_at IS NULL", TAB_NAME_PROFILE,
+		d.dbMaster.Dialect.Now())
+	err := d.dbMaster.WithTx(ctx, func(tx *Tx) error {
+		old, err := selectProfileForUpdate(ctx, tx, userId)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, sqlString, userId)
+		if err != nil {
+			return err
+		}
+		if err = insertCacheOutbox(ctx, tx, profileCacheKey(userId)); err != nil {
+			return err
+		}
+		return insertProfileHistory(ctx, tx, model.PROFILE_OPERATION_DELETE, userId, old, nil)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		d.logger.Warning(ctx, "Profile does not exist, nothing to delete.")
+		return err
+	}
 	if err != nil {
 		d.logger.Error(ctx, "Fail to delete from sql DB, err: ", err.Error())
 		return err
 	}
 	d.logger.Info(ctx, "Delete profile from sql DB succeed.")
+	d.router.PinMaster(ctx, userId)
 
 	// 2. delete data from redis.
 	d.deleteFromCache(ctx, userId)
@@ -135,29 +485,263 @@ func (d *ProfileDao) Delete(ctx context.Context, userId uint64) error {
 	return nil
 }
 
+// Restore restores the profile deleted within window.
+// sql.ErrNoRows is returned if there is no such profile.
+func (d *ProfileDao) Restore(ctx context.Context, userId uint64, window time.Duration) error {
+	d.logger.Info(ctx, "Call ProfileDao.Restore, window: ", window)
+	ctx, cancel := d.timeouts.Write(ctx, "ProfileDao.Restore")
+	defer cancel()
+	err := d.dbMaster.WithTx(ctx, func(tx *Tx) error {
+		sqlString := fmt.Sprintf("SELECT id FROM %v WHERE user_id = ? AND deleted_at >= %v%v",
+			TAB_NAME_PROFILE, tx.Dialect.AddSeconds(tx.Dialect.Now()), tx.Dialect.ForUpdate())
+		var id uint64
+		err := tx.QueryRowContext(ctx, sqlString, userId, -int64(window.Seconds())).Scan(&id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("UPDATE %v SET deleted_at = NULL WHERE id = ?", TAB_NAME_PROFILE), id)
+		if err != nil {
+			return err
+		}
+		restored, err := selectProfileForUpdate(ctx, tx, userId)
+		if err != nil {
+			return err
+		}
+		if err = insertCacheOutbox(ctx, tx, profileCacheKey(userId)); err != nil {
+			return err
+		}
+		return insertProfileHistory(ctx, tx, model.PROFILE_OPERATION_RESTORE, userId, nil, restored)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		d.logger.Warning(ctx, "No profile deleted within the window, nothing to restore.")
+		return err
+	}
+	if err != nil {
+		d.logger.Error(ctx, "Fail to restore in sql DB, err: ", err.Error())
+		return err
+	}
+	d.logger.Info(ctx, "Restore profile in sql DB succeed.")
+	d.router.PinMaster(ctx, userId)
+
+	// a read racing with the delete might have cached the profile before it was deleted,
+	// and a read after it might have cached a tombstone.
+	d.MarkProfileExists(ctx, userId)
+
+	return nil
+}
+
+// Insert creates the profile, ErrProfileExists is returned if the user already has one.
 func (d *ProfileDao) Insert(ctx context.Context, profile *model.Profile) error {
-	// we don't operate cache in insert. Cache data will be load when read.
+	ctx, cancel := d.timeouts.Write(ctx, "ProfileDao.Insert")
+	defer cancel()
+	// cache data will be load when read, only the tombstone and the user id filter are updated in insert.
 	d.logger.Info(ctx, "Call ProfileDao.Insert, profile: ", profile)
-	updateFields, args := profile.UpdateFields()
-	sqlString := profile.InsertSql(updateFields, TAB_NAME_PROFILE)
-	_, err := d.dbMaster.Exec(sqlString, args...)
-	d.logger.Debug(ctx, "sql: ", sqlString)
+	err := d.dbMaster.WithTx(ctx, func(tx *Tx) error {
+		if err := checkUsernameWrite(ctx, tx, profile.UserId, profile.Username, nil); err != nil {
+			return err
+		}
+		return purgeAndInsertProfile(ctx, tx, profile)
+	})
+	if errors.Is(err, ErrProfileExists) {
+		d.logger.Warning(ctx, "Profile already exists, user_id: ", profile.UserId)
+		return err
+	}
 	if err != nil {
-		d.logger.Error(ctx, "Fail to insert into sql DB, err: ", err.Error(), " sql: ", sqlString, " args: ", args)
+		d.logger.Error(ctx, "Fail to insert into sql DB, err: ", err.Error())
 		return err
 	}
 	d.logger.Info(ctx, "Insert profile into sql DB succeed.")
+	d.router.PinMaster(ctx, profile.UserId)
+
+	d.MarkProfileExists(ctx, profile.UserId)
 
 	return nil
 }
 
 func (d *ProfileDao) deleteFromCache(ctx context.Context, userId uint64) {
-	rKey := fmt.Sprintf("%v%d", REDIS_KEY_GET_PROFILE_PREFIX, userId)
-	err := d.dbRedis.Del(ctx, rKey).Err()
-	// if delete failed, other process might read the dirty data.
-	// since we have set an expiration time on it, it'll be eventually consist.
+	rKey := d.policy.Key(profileCacheKey(userId))
+	err := d.dbCache.Del(ctx, rKey)
+	// if delete failed, other process might read the dirty data until the key is deleted again
+	// from the cache outbox written with the change, see CacheOutboxRelay.
 	if err != nil {
 		d.logger.Error(ctx, "Fail to delete from cache, err: ", err.Error())
 	}
+	// the local caches of other instances expire soon if the broadcast is lost.
+	err = d.local.Invalidate(ctx, rKey)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to invalidate local cache, err: ", err.Error())
+	}
 	d.logger.Info(ctx, "Delete profile from cache succeed.")
 }
+
+func profileCacheKey(userId uint64) string {
+	return fmt.Sprintf("%v%d", REDIS_KEY_GET_PROFILE_PREFIX, userId)
+}
+
+func usernameCacheKey(username string) string {
+	return fmt.Sprintf("%v%v", REDIS_KEY_GET_USER_ID_BY_USERNAME_PREFIX, username)
+}
+
+// insertProfile inserts the profile and records the creation in tx.
+func insertProfile(ctx context.Context, tx *Tx, profile *model.Profile) error {
+	insertFields, args := profile.UpdateFields()
+	sqlString := profile.InsertSql(insertFields, TAB_NAME_PROFILE)
+	id, err := tx.Dialect.InsertId(ctx, tx, sqlString, args...)
+	if tx.Dialect.IsDuplicateKey(err, PROFILE_UNIQUE_KEY_USER_ID) {
+		return ErrProfileExists
+	}
+	if err != nil {
+		return fmt.Errorf("%w, sql: %v, args: %v", err, sqlString, args)
+	}
+	created, err := scanProfile(tx.QueryRowContext(ctx, fmt.Sprintf("SELECT %v FROM %v WHERE id = ?", PROFILE_COLUMNS, TAB_NAME_PROFILE), id))
+	if err != nil {
+		return err
+	}
+	if err = insertCacheOutbox(ctx, tx, profileCacheKey(created.UserId)); err != nil {
+		return err
+	}
+	return insertProfileHistory(ctx, tx, model.PROFILE_OPERATION_CREATE, created.UserId, nil, created)
+}
+
+// purgeAndInsertProfile purges the soft deleted profile of the user if any, and then inserts the profile.
+func purgeAndInsertProfile(ctx context.Context, tx *Tx, profile *model.Profile) error {
+	sqlString := fmt.Sprintf("DELETE FROM %v WHERE user_id = ? AND deleted_at IS NOT NULL", TAB_NAME_PROFILE)
+	_, err := tx.ExecContext(ctx, sqlString, profile.UserId)
+	if err != nil {
+		return err
+	}
+	return insertProfile(ctx, tx, profile)
+}
+
+// updateProfile updates the fields set in profile and records the change in tx.
+// old is the current profile selected by selectProfileForUpdate.
+func updateProfile(ctx context.Context, tx *Tx, userId uint64, profile *model.Profile, old *model.Profile) error {
+	updateFields, args := profile.UpdateFields()
+	if len(updateFields) == 0 {
+		return nil
+	}
+	sqlString := profile.UpdateSql(updateFields, TAB_NAME_PROFILE, tx.Dialect)
+	_, err := tx.ExecContext(ctx, sqlString, append(args, userId)...)
+	if err != nil {
+		return fmt.Errorf("%w, sql: %v, args: %v", err, sqlString, args)
+	}
+	updated, err := selectProfileForUpdate(ctx, tx, userId)
+	if err != nil {
+		return err
+	}
+	if err = insertCacheOutbox(ctx, tx, profileCacheKey(userId)); err != nil {
+		return err
+	}
+	return insertProfileHistory(ctx, tx, model.PROFILE_OPERATION_UPDATE, userId, old, updated)
+}
+
+// selectProfileForUpdate reads the profile from master and locks the row until the transaction ends.
+// Soft deleted profile is not selected.
+func selectProfileForUpdate(ctx context.Context, tx *Tx, userId uint64) (*model.Profile, error) {
+	sqlString := fmt.Sprintf("SELECT %v FROM %v WHERE user_id = ? AND deleted_at IS NULL%v", PROFILE_COLUMNS, TAB_NAME_PROFILE,
+		tx.Dialect.ForUpdate())
+	return scanProfile(tx.QueryRowContext(ctx, sqlString, userId))
+}
+
+// checkUsernameWrite checks the username set by a profile write in tx. A user without a username can set a free one,
+// but a username which is set can only be changed by ChangeUsername. old is nil if the profile is being created.
+func checkUsernameWrite(ctx context.Context, tx *Tx, userId uint64, username string, old *model.Profile) error {
+	if username == "" || old != nil && old.Username == username {
+		return nil
+	}
+	if old != nil && old.Username != "" {
+		return ErrUsernameSet
+	}
+	return lockUsername(ctx, tx, userId, username)
+}
+
+// lockUsername locks the username until tx ends, so that it can not be taken concurrently,
+// and returns ErrUsernameTaken if it is used or reserved by another user.
+func lockUsername(ctx context.Context, tx *Tx, userId uint64, username string) error {
+	if err := tx.Dialect.LockKey(ctx, tx, usernameCacheKey(username)); err != nil {
+		return err
+	}
+	var count int
+	err := tx.QueryRowContext(ctx, usernameTakenSql(tx.Dialect, true), username, userId, username, userId).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUsernameTaken
+	}
+	return nil
+}
+
+// usernameTakenSql counts the profiles and reservations of a username which belong to other users.
+// Args are username, userId, username, userId. Soft deleted profiles keep their usernames since they can be restored.
+// The rows are locked with LockRange if forUpdate, dialects which can not lock them must lock the username by LockKey.
+func usernameTakenSql(sqlDialect dialect.Dialect, forUpdate bool) string {
+	lock := ""
+	if forUpdate {
+		lock = sqlDialect.LockRange(false)
+	}
+	return fmt.Sprintf("SELECT (SELECT COUNT(*) FROM %v WHERE username = ? AND user_id <> ?%v)"+
+		" + (SELECT COUNT(*) FROM %v WHERE username = ? AND user_id <> ? AND reserved_until > %v%v)",
+		TAB_NAME_PROFILE, lock, TAB_NAME_USERNAME_HISTORY, sqlDialect.Now(), lock)
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanProfile scans a row selected with PROFILE_COLUMNS.
+func scanProfile(row rowScanner) (*model.Profile, error) {
+	profile := &model.Profile{}
+	attributes := sql.NullString{}
+	privacy := sql.NullString{}
+	err := row.Scan(
+		&profile.Id,
+		&profile.UserId,
+		&profile.Username,
+		dateString{&profile.Birthday},
+		&profile.Email,
+		&profile.AvatarUrl,
+		&profile.Locale,
+		&profile.Timezone,
+		&attributes,
+		&privacy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if attributes.Valid {
+		err = json.Unmarshal([]byte(attributes.String), &profile.Attributes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if privacy.Valid {
+		err = json.Unmarshal([]byte(privacy.String), &profile.Privacy)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return profile, nil
+}
+
+// dateString scans a DATE column into a string in model.BIRTHDAY_LAYOUT, NULL is scanned as empty.
+// The mysql driver returns the text of the date, while the postgres and sqlite drivers return time.Time.
+type dateString struct {
+	s *string
+}
+
+func (d dateString) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*d.s = ""
+	case time.Time:
+		*d.s = v.Format(model.BIRTHDAY_LAYOUT)
+	case []byte:
+		*d.s = string(v)
+	case string:
+		*d.s = v
+	default:
+		return fmt.Errorf("unsupported type %T of date", value)
+	}
+	return nil
+}
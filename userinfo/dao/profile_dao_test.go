@@ -3,30 +3,265 @@ package dao
 import (
 	"context"
 	"database/sql"
-	_ "github.com/jinzhu/gorm/dialects/mysql"
-	"github.com/redis/go-redis/v9"
+	"errors"
+	"fmt"
+	"loggers"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+	"user-server/cache"
+	"user-server/dialect"
+	"user-server/migration"
+	"user-server/model"
 )
 
-func TestProfileDao_GetProfileById(t *testing.T) {
-	dbMaster, err := sql.Open("mysql", "root:qwer1234@tcp(127.0.0.1:13306)/userinfo")
-	dbSlave, err := sql.Open("mysql", "root:qwer1234@tcp(127.0.0.1:23306)/userinfo")
-	rdb := redis.NewClusterClient(&redis.ClusterOptions{
-		Addrs: []string{"127.0.0.1:6371", "127.0.0.1:6372", "127.0.0.1:6373", "127.0.0.1:6374", "127.0.0.1:6375", "127.0.0.1:6376"},
-	})
-	err = rdb.Set(context.Background(), "q", "1", 0).Err()
+// repositories are the DAOs tested against each other, the sql ones run on sqlite and the memory cache.
+type repositories struct {
+	profiles  ProfileRepository
+	histories ProfileHistoryRepository
+	users     UserRepository
+	relations RelationRepository
+}
+
+// newTestLogger creates a logger writing to a temporary directory instead of the directory of the package.
+func newTestLogger(t *testing.T) *logger.Logger {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("get working directory failed, err: %v", err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("change working directory failed, err: %v", err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+	return logger.NewLogger()
+}
+
+// newTestDB opens a migrated sqlite database in a temporary directory.
+func newTestDB(t *testing.T) *DBMaster {
+	sqlDialect := dialect.SQLite{}
+	db, err := sql.Open(sqlDialect.DriverName(), sqlDialect.DSN("", "", "", "", filepath.Join(t.TempDir(), "userinfo.db")))
+	if err != nil {
+		t.Fatalf("open sqlite failed, err: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	migrator, err := migration.NewMigrator(db, sqlDialect)
+	if err == nil {
+		_, err = migrator.Up(context.Background())
+	}
+	if err != nil {
+		t.Fatalf("migrate failed, err: %v", err)
+	}
+	return &DBMaster{DB: db, Dialect: sqlDialect}
+}
+
+func newSQLRepositories(t *testing.T) *repositories {
+	ctx := context.Background()
+	lgr := newTestLogger(t)
+	dbCache := cache.NewMemoryCache()
+	policy := cache.NewPolicy()
+	dbMaster := newTestDB(t)
+	db := dbMaster.DB
+	dbSlave := NewDBSlave(NewReplica(db, "sqlite", 1))
+	router := NewDBRouter(dbMaster, dbSlave, dbCache, 0, 0, lgr)
+	timeouts := NewTimeouts(0, 0, nil)
+	profileDao := NewProfileDao(dbMaster, dbSlave, dbCache, cache.NewLoader(dbCache, 0), nil, policy, router, timeouts, lgr)
+	if err := profileDao.RebuildUserIdFilter(ctx); err != nil {
+		t.Fatalf("rebuild user id filter failed, err: %v", err)
+	}
+	return &repositories{
+		profiles:  profileDao,
+		histories: NewProfileHistoryDao(router, timeouts, lgr),
+		users:     NewUserDao(dbMaster, timeouts, lgr),
+		relations: NewRelationDao(dbMaster, dbSlave, dbCache, policy, router, timeouts, lgr),
+	}
+}
+
+func newMemoryRepositories(db *MemoryDB) *repositories {
+	return &repositories{
+		profiles:  NewMemoryProfileDao(db),
+		histories: NewMemoryProfileHistoryDao(db),
+		users:     NewMemoryUserDao(db),
+		relations: NewMemoryRelationDao(db),
+	}
+}
+
+func TestProfileDao(t *testing.T) {
+	testProfileRepository(t, newSQLRepositories(t))
+}
+
+func TestProfileDao_ChangeUsernameOutbox(t *testing.T) {
+	ctx := context.Background()
+	lgr := newTestLogger(t)
+	dbCache := cache.NewMemoryCache()
+	dbMaster := newTestDB(t)
+	dbSlave := NewDBSlave(NewReplica(dbMaster.DB, "sqlite", 1))
+	router := NewDBRouter(dbMaster, dbSlave, dbCache, 0, 0, lgr)
+	profileDao := NewProfileDao(dbMaster, dbSlave, dbCache, cache.NewLoader(dbCache, 0), nil, cache.NewPolicy(), router, nil, lgr)
+	userId, err := NewUserDao(dbMaster, nil, lgr).InsertWithProfile(ctx, &model.User{Email: "bob@example.com"},
+		&model.Profile{Email: "bob@example.com"})
 	if err != nil {
-		t.Errorf(err.Error())
-		//return
+		t.Fatalf("insert user failed, err: %v", err)
 	}
-	profileDao := NewProfileDao(&DBMaster{dbMaster}, &DBSlave{dbSlave}, rdb)
-	profile, err := profileDao.GetProfileById(context.Background(), 1)
+	policy := &model.UsernamePolicy{ChangeCooldown: model.USERNAME_CHANGE_COOLDOWN_DEFAULT, ReservePeriod: model.USERNAME_RESERVE_PERIOD_DEFAULT}
+	if err = profileDao.ChangeUsername(ctx, userId, "bob", policy); err != nil {
+		t.Fatalf("change username failed, err: %v", err)
+	}
+	// the user had no username, only the key of the new one is deleted.
+	rows, err := dbMaster.QueryContext(ctx, fmt.Sprintf("SELECT cache_key FROM %v WHERE cache_key LIKE ?", TAB_NAME_CACHE_OUTBOX),
+		usernameCacheKey("")+"%")
 	if err != nil {
-		t.Errorf("call profileDao.GetProfile failed!")
-		return
+		t.Fatalf("select outbox failed, err: %v", err)
+	}
+	defer rows.Close()
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			t.Fatalf("scan outbox failed, err: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) != 1 || keys[0] != usernameCacheKey("bob") {
+		t.Errorf("expect username key of bob in the outbox only, got %v", keys)
+	}
+}
+
+func TestMemoryProfileDao(t *testing.T) {
+	testProfileRepository(t, newMemoryRepositories(NewMemoryDB()))
+}
+
+func testProfileRepository(t *testing.T, r *repositories) {
+	ctx := context.Background()
+	policy := &model.UsernamePolicy{ChangeCooldown: model.USERNAME_CHANGE_COOLDOWN_DEFAULT, ReservePeriod: model.USERNAME_RESERVE_PERIOD_DEFAULT}
+	userId, err := r.users.InsertWithProfile(ctx, &model.User{Email: "alice@example.com", Password: "secret"},
+		&model.Profile{Username: "alice", Email: "alice@example.com", Attributes: map[string]any{"height": 170}})
+	if err != nil || userId == 0 {
+		t.Fatalf("insert user failed, user_id: %v, err: %v", userId, err)
+	}
+	r.profiles.MarkProfileExists(ctx, userId)
+	if user, err := r.users.GetUserByEmail(ctx, "alice@example.com"); err != nil || user.Id != userId {
+		t.Fatalf("expect user %v, got %v, err: %v", userId, user, err)
+	}
+	if _, err = r.users.GetUserById(ctx, userId+1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expect sql.ErrNoRows, got %v", err)
+	}
+
+	profile, err := r.profiles.GetProfileById(ctx, userId)
+	if err != nil || profile.Username != "alice" || profile.Attributes["height"] != float64(170) {
+		t.Fatalf("expect profile of alice with height 170, got %+v, err: %v", profile, err)
+	}
+	if err = r.profiles.Insert(ctx, &model.Profile{UserId: userId}); !errors.Is(err, ErrProfileExists) {
+		t.Errorf("expect ErrProfileExists, got %v", err)
+	}
+	err = r.profiles.Update(ctx, userId, &model.Profile{Timezone: "Europe/Paris", Attributes: map[string]any{"height": nil, "city": "Paris"}})
+	if err != nil {
+		t.Fatalf("update failed, err: %v", err)
+	}
+	profile, err = r.profiles.GetProfileById(ctx, userId)
+	if err != nil || profile.Timezone != "Europe/Paris" || len(profile.Attributes) != 1 || profile.Attributes["city"] != "Paris" {
+		t.Fatalf("expect timezone Europe/Paris and city Paris only, got %+v, err: %v", profile, err)
+	}
+	if err = r.profiles.Update(ctx, userId+1, &model.Profile{Timezone: "UTC"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expect sql.ErrNoRows, got %v", err)
+	}
+
+	if err = r.profiles.ChangeUsername(ctx, userId, "alice2", policy); err != nil {
+		t.Fatalf("change username failed, err: %v", err)
+	}
+	if id, err := r.profiles.GetUserIdByUsername(ctx, "alice2"); err != nil || id != userId {
+		t.Errorf("expect user %v of alice2, got %v, err: %v", userId, id, err)
+	}
+	if id, err := r.profiles.GetUserIdByPreviousUsername(ctx, "alice"); err != nil || id != userId {
+		t.Errorf("expect user %v reserving alice, got %v, err: %v", userId, id, err)
+	}
+	if taken, err := r.profiles.IsUsernameTaken(ctx, 0, "alice"); err != nil || !taken {
+		t.Errorf("expect reserved alice taken, got %v, err: %v", taken, err)
+	}
+	if taken, err := r.profiles.IsUsernameTaken(ctx, userId, "alice"); err != nil || taken {
+		t.Errorf("expect alice not taken for its owner, got %v, err: %v", taken, err)
+	}
+	if err = r.profiles.ChangeUsername(ctx, userId, "alice3", policy); !errors.Is(err, ErrUsernameCooldown) {
+		t.Errorf("expect ErrUsernameCooldown, got %v", err)
+	}
+	if err = r.profiles.Update(ctx, userId, &model.Profile{Username: "alice3"}); !errors.Is(err, ErrUsernameSet) {
+		t.Errorf("expect ErrUsernameSet, got %v", err)
+	}
+	if err = r.profiles.Upsert(ctx, &model.Profile{UserId: userId + 1, Username: "alice"}); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("expect reserved alice taken by upsert, got %v", err)
+	}
+	if err = r.profiles.Insert(ctx, &model.Profile{UserId: userId + 1, Username: "alice2"}); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("expect alice2 taken by insert, got %v", err)
+	}
+	if _, err = r.users.InsertWithProfile(ctx, &model.User{Email: "bob@example.com"}, &model.Profile{Username: "alice2"}); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("expect alice2 taken by registration, got %v", err)
+	}
+
+	if err = r.profiles.Delete(ctx, userId); err != nil {
+		t.Fatalf("delete failed, err: %v", err)
+	}
+	if _, err = r.profiles.GetProfileById(ctx, userId); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expect sql.ErrNoRows after delete, got %v", err)
+	}
+	if err = r.profiles.Delete(ctx, userId); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expect sql.ErrNoRows deleting twice, got %v", err)
+	}
+	if err = r.profiles.Restore(ctx, userId, time.Hour); err != nil {
+		t.Fatalf("restore failed, err: %v", err)
+	}
+	if profile, err = r.profiles.GetProfileById(ctx, userId); err != nil || profile.Username != "alice2" {
+		t.Errorf("expect restored profile of alice2, got %+v, err: %v", profile, err)
+	}
+
+	histories, err := r.histories.List(ctx, userId, 0, 10)
+	if err != nil || len(histories) != 5 {
+		t.Fatalf("expect 5 histories, got %v, err: %v", len(histories), err)
+	}
+	operations := []string{model.PROFILE_OPERATION_RESTORE, model.PROFILE_OPERATION_DELETE, model.PROFILE_OPERATION_UPDATE,
+		model.PROFILE_OPERATION_UPDATE, model.PROFILE_OPERATION_CREATE}
+	for i, history := range histories {
+		if history.Version != uint64(5-i) || history.Operation != operations[i] {
+			t.Errorf("expect version %v of %v, got version %v of %v", 5-i, operations[i], history.Version, history.Operation)
+		}
+	}
+	if histories[4].ActorId != userId || histories[1].Snapshot != nil {
+		t.Errorf("expect creation by user %v and no snapshot of delete, got %v and %+v", userId, histories[4].ActorId, histories[1].Snapshot)
+	}
+	if histories, err = r.histories.List(ctx, userId, 3, 10); err != nil || len(histories) != 2 {
+		t.Errorf("expect 2 histories before version 3, got %v, err: %v", len(histories), err)
+	}
+	history, err := r.histories.GetAt(ctx, userId, time.Now().Unix())
+	if err != nil || history.Version != 5 || history.Snapshot.Username != "alice2" {
+		t.Errorf("expect version 5 of alice2, got %+v, err: %v", history, err)
+	}
+	if _, err = r.histories.GetAt(ctx, userId, 0); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expect sql.ErrNoRows before creation, got %v", err)
+	}
+}
+
+func TestMemoryProfileDao_Restore(t *testing.T) {
+	ctx := context.Background()
+	db := NewMemoryDB()
+	now := time.Now()
+	db.now = func() time.Time { return now }
+	profiles := NewMemoryProfileDao(db)
+	if err := profiles.Insert(ctx, &model.Profile{UserId: 1, Username: "bob"}); err != nil {
+		t.Fatalf("insert failed, err: %v", err)
+	}
+	if err := profiles.Delete(ctx, 1); err != nil {
+		t.Fatalf("delete failed, err: %v", err)
+	}
+	if taken, _ := profiles.IsUsernameTaken(ctx, 2, "bob"); !taken {
+		t.Errorf("expect username of deleted profile taken")
+	}
+	now = now.Add(time.Hour * 2)
+	if err := profiles.Restore(ctx, 1, time.Hour); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expect sql.ErrNoRows out of window, got %v", err)
+	}
+	if err := profiles.Insert(ctx, &model.Profile{UserId: 1, Username: "bob2"}); err != nil {
+		t.Fatalf("insert over deleted profile failed, err: %v", err)
 	}
-	if profile.Id != 1 || profile.UserId != 1 {
-		t.Errorf("got unexpected result!")
-		return
+	if profile, err := profiles.GetProfileById(ctx, 1); err != nil || profile.Username != "bob2" {
+		t.Errorf("expect new profile of bob2, got %+v, err: %v", profile, err)
 	}
 }
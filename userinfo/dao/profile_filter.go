@@ -0,0 +1,152 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The user id filter is a redis bitmap with the bit of user id set if the user has a profile, so that lookups of
+// user ids which never had a profile are rejected before touching the sql DB. Bits are never cleared, profiles
+// deleted afterwards are left to the tombstones. The filter is only used when it is ready, i.e. it has been rebuilt
+// from the sql DB since the cache was last emptied. The bit of user id 0, which is never allocated, marks the filter
+// ready, so a bitmap lost on failover or eviction is never taken as ready. User ids above
+// cache.Policy.UserIdFilterMax are left out and always pass the filter, so that a large user id cannot make redis
+// allocate a huge bitmap.
+const (
+	REDIS_KEY_PROFILE_USER_ID_FILTER         = "userinfo:profile_user_id_filter"
+	REDIS_KEY_PROFILE_USER_ID_FILTER_REBUILD = "userinfo:profile_user_id_filter:rebuild"
+	PROFILE_USER_ID_FILTER_READY_BIT         = 0
+
+	PROFILE_USER_ID_FILTER_REBUILD_BATCH = 1000
+	PROFILE_USER_ID_FILTER_REBUILD_LOCK  = time.Minute * 10
+	// a rebuild is started in the background at most once in the interval by each process while the filter is not
+	// ready, instead of on every cache miss.
+	PROFILE_USER_ID_FILTER_REBUILD_INTERVAL = time.Second * 10
+)
+
+// mayHaveProfile reports whether the user may have a profile according to the user id filter. The filter is
+// rebuilt in the background if it is not ready, and true is returned meanwhile.
+func (d *ProfileDao) mayHaveProfile(ctx context.Context, userId uint64) bool {
+	if userId == 0 {
+		return false
+	}
+	if userId > d.policy.UserIdFilterMax {
+		return true
+	}
+	ready, err := d.dbCache.GetBit(ctx, REDIS_KEY_PROFILE_USER_ID_FILTER, PROFILE_USER_ID_FILTER_READY_BIT)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to get ready bit of user id filter, err: ", err.Error())
+		return true
+	}
+	if ready == 0 {
+		d.logger.Warning(ctx, "User id filter is not ready.")
+		d.rebuildUserIdFilterInBackground(ctx)
+		return true
+	}
+	bit, err := d.dbCache.GetBit(ctx, REDIS_KEY_PROFILE_USER_ID_FILTER, userId)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to get bit of user id filter, err: ", err.Error())
+		return true
+	}
+	return bit == 1
+}
+
+// rebuildUserIdFilterInBackground starts a rebuild unless one has been started within
+// PROFILE_USER_ID_FILTER_REBUILD_INTERVAL.
+func (d *ProfileDao) rebuildUserIdFilterInBackground(ctx context.Context) {
+	now := time.Now().UnixNano()
+	started := d.filterRebuildStarted.Load()
+	if now-started < int64(PROFILE_USER_ID_FILTER_REBUILD_INTERVAL) || !d.filterRebuildStarted.CompareAndSwap(started, now) {
+		return
+	}
+	go d.RebuildUserIdFilter(context.WithoutCancel(ctx))
+}
+
+// MarkProfileExists adds the user to the user id filter and removes the tombstone of the profile,
+// it must be called after a profile is created or restored.
+func (d *ProfileDao) MarkProfileExists(ctx context.Context, userId uint64) {
+	if userId <= d.policy.UserIdFilterMax {
+		err := d.dbCache.SetBit(ctx, REDIS_KEY_PROFILE_USER_ID_FILTER, userId, 1)
+		if err != nil {
+			// the filter would hide the profile, so it is not used until it is rebuilt.
+			d.logger.Error(ctx, "Fail to set bit of user id filter, err: ", err.Error())
+			if err := d.dbCache.SetBit(ctx, REDIS_KEY_PROFILE_USER_ID_FILTER, PROFILE_USER_ID_FILTER_READY_BIT, 0); err != nil {
+				d.logger.Error(ctx, "Fail to disable user id filter, err: ", err.Error())
+			}
+		}
+	}
+	d.deleteFromCache(ctx, userId)
+}
+
+// RebuildUserIdFilter sets the bits of all users with a profile and marks the filter ready.
+// Only one instance rebuilds it at a time, the others return at once.
+func (d *ProfileDao) RebuildUserIdFilter(ctx context.Context) error {
+	locked, err := d.dbCache.SetNX(ctx, REDIS_KEY_PROFILE_USER_ID_FILTER_REBUILD, "1", PROFILE_USER_ID_FILTER_REBUILD_LOCK)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to lock user id filter, err: ", err.Error())
+		return err
+	}
+	if !locked {
+		d.logger.Info(ctx, "User id filter is being rebuilt by another one.")
+		return nil
+	}
+	defer d.dbCache.Del(ctx, REDIS_KEY_PROFILE_USER_ID_FILTER_REBUILD)
+
+	d.logger.Info(ctx, "Call ProfileDao.RebuildUserIdFilter.")
+	var count uint64
+	for _, dbSlave := range d.filterSlaves {
+		n, err := d.setUserIdFilterBits(ctx, dbSlave)
+		if err != nil {
+			d.logger.Error(ctx, "Fail to rebuild user id filter, err: ", err.Error())
+			return err
+		}
+		count += n
+	}
+	err = d.dbCache.SetBit(ctx, REDIS_KEY_PROFILE_USER_ID_FILTER, PROFILE_USER_ID_FILTER_READY_BIT, 1)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to mark user id filter ready, err: ", err.Error())
+		return err
+	}
+	d.logger.Info(ctx, "Rebuild user id filter done, users: ", count)
+	return nil
+}
+
+// setUserIdFilterBits sets the bits of the users with a profile in a replica of dbSlave, and returns the count of them.
+func (d *ProfileDao) setUserIdFilterBits(ctx context.Context, dbSlave *DBSlave) (uint64, error) {
+	db, err := dbSlave.Pick()
+	if err != nil {
+		return 0, err
+	}
+	// soft deleted profiles are included since they can be restored.
+	sqlString := fmt.Sprintf("SELECT id, user_id FROM %v WHERE id > ? ORDER BY id LIMIT ?", TAB_NAME_PROFILE)
+	var lastId, count uint64
+	for {
+		rows, err := db.QueryContext(ctx, sqlString, lastId, PROFILE_USER_ID_FILTER_REBUILD_BATCH)
+		if err != nil {
+			return count, err
+		}
+		n := 0
+		for rows.Next() {
+			var userId uint64
+			if err = rows.Scan(&lastId, &userId); err != nil {
+				break
+			}
+			n++
+			if userId > d.policy.UserIdFilterMax {
+				continue
+			}
+			if err = d.dbCache.SetBit(ctx, REDIS_KEY_PROFILE_USER_ID_FILTER, userId, 1); err != nil {
+				break
+			}
+			count++
+		}
+		if err = errors.Join(err, rows.Err(), rows.Close()); err != nil {
+			return count, err
+		}
+		if n < PROFILE_USER_ID_FILTER_REBUILD_BATCH {
+			return count, nil
+		}
+	}
+}
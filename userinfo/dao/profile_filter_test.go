@@ -0,0 +1,71 @@
+package dao
+
+import (
+	"context"
+	"testing"
+	"user-server/cache"
+	"user-server/model"
+)
+
+func newTestProfileDao(t *testing.T, dbCache cache.Cache, policy *cache.Policy) (*ProfileDao, *DBMaster) {
+	lgr := newTestLogger(t)
+	dbMaster := newTestDB(t)
+	dbSlave := NewDBSlave(NewReplica(dbMaster.DB, "sqlite", 1))
+	router := NewDBRouter(dbMaster, dbSlave, dbCache, 0, 0, lgr)
+	return NewProfileDao(dbMaster, dbSlave, dbCache, cache.NewLoader(dbCache, 0), nil, policy, router, nil, lgr), dbMaster
+}
+
+func TestProfileDao_UserIdFilter(t *testing.T) {
+	ctx := context.Background()
+	dbCache := cache.NewMemoryCache()
+	profileDao, dbMaster := newTestProfileDao(t, dbCache, cache.NewPolicy())
+	userId, err := NewUserDao(dbMaster, nil, newTestLogger(t)).InsertWithProfile(ctx, &model.User{Email: "alice@example.com"},
+		&model.Profile{Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("insert user failed, err: %v", err)
+	}
+	if err = profileDao.RebuildUserIdFilter(ctx); err != nil {
+		t.Fatalf("rebuild user id filter failed, err: %v", err)
+	}
+	if !profileDao.mayHaveProfile(ctx, userId) || profileDao.mayHaveProfile(ctx, userId+1) {
+		t.Errorf("expect only user %v in the filter", userId)
+	}
+
+	// a lost bitmap is not ready, so that every user may have a profile until it is rebuilt.
+	if _, err = dbCache.SetNX(ctx, REDIS_KEY_PROFILE_USER_ID_FILTER_REBUILD, "1", PROFILE_USER_ID_FILTER_REBUILD_LOCK); err != nil {
+		t.Fatalf("lock rebuild failed, err: %v", err)
+	}
+	if err = dbCache.Del(ctx, REDIS_KEY_PROFILE_USER_ID_FILTER); err != nil {
+		t.Fatalf("delete user id filter failed, err: %v", err)
+	}
+	if !profileDao.mayHaveProfile(ctx, userId+1) {
+		t.Errorf("expect user %v to pass the filter which is not ready", userId+1)
+	}
+	// the misses meanwhile do not start another rebuild.
+	started := profileDao.filterRebuildStarted.Load()
+	if started == 0 {
+		t.Fatalf("expect a rebuild started")
+	}
+	profileDao.mayHaveProfile(ctx, userId+1)
+	if profileDao.filterRebuildStarted.Load() != started {
+		t.Errorf("expect no rebuild started again within %v", PROFILE_USER_ID_FILTER_REBUILD_INTERVAL)
+	}
+}
+
+func TestProfileDao_UserIdFilterMax(t *testing.T) {
+	ctx := context.Background()
+	dbCache := cache.NewMemoryCache()
+	policy := cache.NewPolicy()
+	policy.UserIdFilterMax = 1000
+	profileDao, _ := newTestProfileDao(t, dbCache, policy)
+	if err := profileDao.RebuildUserIdFilter(ctx); err != nil {
+		t.Fatalf("rebuild user id filter failed, err: %v", err)
+	}
+	profileDao.MarkProfileExists(ctx, 1<<31)
+	if bit, err := dbCache.GetBit(ctx, REDIS_KEY_PROFILE_USER_ID_FILTER, 1<<31); err != nil || bit != 0 {
+		t.Errorf("expect no bit set above the max, got %v, err: %v", bit, err)
+	}
+	if !profileDao.mayHaveProfile(ctx, 1<<31) || profileDao.mayHaveProfile(ctx, 1000) {
+		t.Errorf("expect only user ids above the max to pass the empty filter")
+	}
+}
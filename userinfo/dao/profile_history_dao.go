@@ -0,0 +1,146 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"loggers"
+	"user-server/model"
+)
+
+const TAB_NAME_PROFILE_HISTORY = "profile_history_tab"
+
+// PROFILE_HISTORY_COLUMNS takes the create_time in unix seconds of the dialect, see ProfileHistoryDao.columns.
+const PROFILE_HISTORY_COLUMNS = "id, user_id, version, operation, changes, snapshot, actor_id, request_id, %v"
+
+type ProfileHistoryDao struct {
+	router   *DBRouter
+	timeouts *Timeouts
+	logger   *logger.Logger
+}
+
+func NewProfileHistoryDao(router *DBRouter, timeouts *Timeouts, logger *logger.Logger) *ProfileHistoryDao {
+	return &ProfileHistoryDao{
+		router:   router,
+		timeouts: timeouts,
+		logger:   logger,
+	}
+}
+
+// List returns at most limit histories of a user older than beforeVersion, newest first.
+// beforeVersion 0 means starting from the latest version.
+func (d *ProfileHistoryDao) List(ctx context.Context, userId uint64, beforeVersion uint64, limit int) ([]*model.ProfileHistory, error) {
+	d.logger.Info(ctx, "Call ProfileHistoryDao.List, before_version: ", beforeVersion, ", limit: ", limit)
+	ctx, cancel := d.timeouts.Read(ctx, "ProfileHistoryDao.List")
+	defer cancel()
+	sqlString := fmt.Sprintf("SELECT %v FROM %v WHERE user_id = ? AND (? = 0 OR version < ?)"+
+		" ORDER BY version DESC LIMIT ?", d.columns(), TAB_NAME_PROFILE_HISTORY)
+	readDB, _ := d.router.ReadDB(ctx, userId)
+	rows, err := readDB.QueryContext(ctx, sqlString, userId, beforeVersion, beforeVersion, limit)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to query histories, err: ", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	histories := make([]*model.ProfileHistory, 0, limit)
+	for rows.Next() {
+		history, err := scanProfileHistory(rows)
+		if err != nil {
+			d.logger.Error(ctx, "Fail to scan data, err: ", err.Error())
+			return nil, err
+		}
+		histories = append(histories, history)
+	}
+	if err = rows.Err(); err != nil {
+		d.logger.Error(ctx, "Fail to iterate rows, err: ", err.Error())
+		return nil, err
+	}
+	return histories, nil
+}
+
+// GetAt returns the latest history of a user recorded no later than timestamp.
+func (d *ProfileHistoryDao) GetAt(ctx context.Context, userId uint64, timestamp int64) (*model.ProfileHistory, error) {
+	d.logger.Info(ctx, "Call ProfileHistoryDao.GetAt, timestamp: ", timestamp)
+	ctx, cancel := d.timeouts.Read(ctx, "ProfileHistoryDao.GetAt")
+	defer cancel()
+	sqlString := fmt.Sprintf("SELECT %v FROM %v WHERE user_id = ? AND create_time <= %v"+
+		" ORDER BY version DESC LIMIT 1", d.columns(), TAB_NAME_PROFILE_HISTORY, d.router.Dialect().FromUnixTimestamp("?"))
+	readDB, _ := d.router.ReadDB(ctx, userId)
+	history, err := scanProfileHistory(readDB.QueryRowContext(ctx, sqlString, userId, timestamp))
+	if err != nil {
+		d.logger.Error(ctx, "Fail to scan data, err: ", err.Error())
+		return nil, err
+	}
+	return history, nil
+}
+
+// columns returns PROFILE_HISTORY_COLUMNS with create_time in unix seconds.
+func (d *ProfileHistoryDao) columns() string {
+	return fmt.Sprintf(PROFILE_HISTORY_COLUMNS, d.router.Dialect().UnixTimestamp("create_time"))
+}
+
+// insertProfileHistory records a change of profile in the transaction of the change.
+// The actor and request id are taken from the trace data of ctx.
+func insertProfileHistory(ctx context.Context, tx *Tx, operation string, userId uint64, before *model.Profile, after *model.Profile) error {
+	changes, err := json.Marshal(model.DiffProfiles(before, after))
+	if err != nil {
+		return err
+	}
+	snapshot := sql.NullString{}
+	if after != nil {
+		s, err := json.Marshal(after)
+		if err != nil {
+			return err
+		}
+		snapshot = sql.NullString{String: string(s), Valid: true}
+	}
+	traceData, _ := ctx.Value(logger.TraceDataKey{}).(logger.TraceData)
+
+	// rows of profile_tab are locked by the caller, so versions of a user are allocated in order.
+	var version uint64
+	sqlString := fmt.Sprintf("SELECT COALESCE(MAX(version), 0) + 1 FROM %v WHERE user_id = ?", TAB_NAME_PROFILE_HISTORY)
+	err = tx.QueryRowContext(ctx, sqlString, userId).Scan(&version)
+	if err != nil {
+		return err
+	}
+	sqlString = fmt.Sprintf("INSERT INTO %v (user_id, version, operation, changes, snapshot, actor_id, request_id)"+
+		" VALUES (?,?,?,?,?,?,?)", TAB_NAME_PROFILE_HISTORY)
+	_, err = tx.ExecContext(ctx, sqlString, userId, version, operation, string(changes), snapshot, traceData.UserId, traceData.RequestId)
+	return err
+}
+
+func scanProfileHistory(row rowScanner) (*model.ProfileHistory, error) {
+	history := &model.ProfileHistory{}
+	changes := sql.NullString{}
+	snapshot := sql.NullString{}
+	err := row.Scan(
+		&history.Id,
+		&history.UserId,
+		&history.Version,
+		&history.Operation,
+		&changes,
+		&snapshot,
+		&history.ActorId,
+		&history.RequestId,
+		&history.CreateTime,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if changes.Valid {
+		err = json.Unmarshal([]byte(changes.String), &history.Changes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if snapshot.Valid {
+		history.Snapshot = &model.Profile{}
+		err = json.Unmarshal([]byte(snapshot.String), history.Snapshot)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
@@ -0,0 +1,401 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"loggers"
+	"user-server/cache"
+	"user-server/model"
+)
+
+const TAB_NAME_RELATION = "relation_tab"
+const TAB_NAME_FRIEND_REQUEST = "friend_request_tab"
+const (
+	REDIS_KEY_GET_RELATION_COUNTS_PREFIX = "userinfo:get_relation_counts:"
+)
+
+// ErrRelationBlocked is returned when either of the users has blocked the other.
+var ErrRelationBlocked = errors.New("relation blocked")
+
+type RelationDao struct {
+	dbMaster *DBMaster
+	dbSlave  *DBSlave
+	dbCache  cache.Cache
+	policy   *cache.Policy
+	router   *DBRouter
+	timeouts *Timeouts
+	logger   *logger.Logger
+}
+
+func NewRelationDao(dbMaster *DBMaster, dbSlave *DBSlave, dbCache cache.Cache, policy *cache.Policy, router *DBRouter,
+	timeouts *Timeouts, logger *logger.Logger) *RelationDao {
+	return &RelationDao{
+		dbMaster: dbMaster,
+		dbSlave:  dbSlave,
+		dbCache:  dbCache,
+		policy:   policy,
+		router:   router,
+		timeouts: timeouts,
+		logger:   logger,
+	}
+}
+
+// Follow makes userId follow targetId. Following twice is a no-op.
+func (d *RelationDao) Follow(ctx context.Context, userId uint64, targetId uint64) error {
+	d.logger.Info(ctx, "Call RelationDao.Follow, target_id: ", targetId)
+	ctx, cancel := d.timeouts.Write(ctx, "RelationDao.Follow")
+	defer cancel()
+	err := d.dbMaster.WithTx(ctx, func(tx *Tx) error {
+		if err := checkNotBlocked(ctx, tx, userId, targetId); err != nil {
+			return err
+		}
+		return insertRelation(ctx, tx, userId, targetId, model.RELATION_TYPE_FOLLOW)
+	})
+	if err != nil {
+		d.logger.Error(ctx, "Fail to follow, err: ", err.Error())
+		return err
+	}
+	d.deleteCountsFromCache(ctx, userId, targetId)
+	d.router.PinMaster(ctx, userId, targetId)
+	return nil
+}
+
+func (d *RelationDao) Unfollow(ctx context.Context, userId uint64, targetId uint64) error {
+	d.logger.Info(ctx, "Call RelationDao.Unfollow, target_id: ", targetId)
+	ctx, cancel := d.timeouts.Write(ctx, "RelationDao.Unfollow")
+	defer cancel()
+	sqlString := fmt.Sprintf("DELETE FROM %v WHERE user_id = ? AND target_id = ? AND type = ?", TAB_NAME_RELATION)
+	_, err := d.dbMaster.ExecContext(ctx, sqlString, userId, targetId, model.RELATION_TYPE_FOLLOW)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to unfollow, err: ", err.Error())
+		return err
+	}
+	d.deleteCountsFromCache(ctx, userId, targetId)
+	d.router.PinMaster(ctx, userId, targetId)
+	return nil
+}
+
+// RequestFriend sends a friend request from userId to targetId. If targetId has sent a pending request to userId,
+// they become friends directly. Nothing is done if they are friends already.
+func (d *RelationDao) RequestFriend(ctx context.Context, userId uint64, targetId uint64) error {
+	d.logger.Info(ctx, "Call RelationDao.RequestFriend, target_id: ", targetId)
+	ctx, cancel := d.timeouts.Write(ctx, "RelationDao.RequestFriend")
+	defer cancel()
+	err := d.dbMaster.WithTx(ctx, func(tx *Tx) error {
+		if err := checkNotBlocked(ctx, tx, userId, targetId); err != nil {
+			return err
+		}
+		isFriend, err := hasRelation(ctx, tx, userId, targetId, model.RELATION_TYPE_FRIEND)
+		if err != nil || isFriend {
+			return err
+		}
+		err = acceptFriendRequest(ctx, tx, targetId, userId)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		sqlString := fmt.Sprintf("INSERT INTO %v (from_user_id, to_user_id, status) VALUES (?,?,?)%v", TAB_NAME_FRIEND_REQUEST,
+			tx.Dialect.OnConflictUpdate([]string{"from_user_id", "to_user_id"}, "status"))
+		_, err = tx.ExecContext(ctx, sqlString, userId, targetId, model.FRIEND_REQUEST_STATUS_PENDING)
+		return err
+	})
+	if err != nil {
+		d.logger.Error(ctx, "Fail to request friend, err: ", err.Error())
+		return err
+	}
+	d.deleteCountsFromCache(ctx, userId, targetId)
+	d.router.PinMaster(ctx, userId, targetId)
+	return nil
+}
+
+// AcceptFriend accepts the pending friend request from fromUserId to userId.
+// sql.ErrNoRows is returned if there is no such request.
+func (d *RelationDao) AcceptFriend(ctx context.Context, userId uint64, fromUserId uint64) error {
+	d.logger.Info(ctx, "Call RelationDao.AcceptFriend, from_user_id: ", fromUserId)
+	ctx, cancel := d.timeouts.Write(ctx, "RelationDao.AcceptFriend")
+	defer cancel()
+	err := d.dbMaster.WithTx(ctx, func(tx *Tx) error {
+		return acceptFriendRequest(ctx, tx, fromUserId, userId)
+	})
+	if err != nil {
+		d.logger.Error(ctx, "Fail to accept friend, err: ", err.Error())
+		return err
+	}
+	d.deleteCountsFromCache(ctx, userId, fromUserId)
+	d.router.PinMaster(ctx, userId, fromUserId)
+	return nil
+}
+
+// RejectFriend rejects the pending friend request from fromUserId to userId.
+// sql.ErrNoRows is returned if there is no such request.
+func (d *RelationDao) RejectFriend(ctx context.Context, userId uint64, fromUserId uint64) error {
+	d.logger.Info(ctx, "Call RelationDao.RejectFriend, from_user_id: ", fromUserId)
+	ctx, cancel := d.timeouts.Write(ctx, "RelationDao.RejectFriend")
+	defer cancel()
+	sqlString := fmt.Sprintf("UPDATE %v SET status = ? WHERE from_user_id = ? AND to_user_id = ? AND status = ?", TAB_NAME_FRIEND_REQUEST)
+	result, err := d.dbMaster.ExecContext(ctx, sqlString, model.FRIEND_REQUEST_STATUS_REJECTED, fromUserId, userId, model.FRIEND_REQUEST_STATUS_PENDING)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to reject friend, err: ", err.Error())
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		d.logger.Warning(ctx, "Friend request does not exist, nothing to reject.")
+		return sql.ErrNoRows
+	}
+	d.deleteCountsFromCache(ctx, userId)
+	d.router.PinMaster(ctx, userId, fromUserId)
+	return nil
+}
+
+// Block makes userId block targetId. Follows, friendship and friend requests between them are removed.
+func (d *RelationDao) Block(ctx context.Context, userId uint64, targetId uint64) error {
+	d.logger.Info(ctx, "Call RelationDao.Block, target_id: ", targetId)
+	ctx, cancel := d.timeouts.Write(ctx, "RelationDao.Block")
+	defer cancel()
+	err := d.dbMaster.WithTx(ctx, func(tx *Tx) error {
+		err := insertRelation(ctx, tx, userId, targetId, model.RELATION_TYPE_BLOCK)
+		if err != nil {
+			return err
+		}
+		sqlString := fmt.Sprintf("DELETE FROM %v WHERE ((user_id = ? AND target_id = ?) OR (user_id = ? AND target_id = ?))"+
+			" AND type IN (?,?)", TAB_NAME_RELATION)
+		_, err = tx.ExecContext(ctx, sqlString, userId, targetId, targetId, userId, model.RELATION_TYPE_FOLLOW, model.RELATION_TYPE_FRIEND)
+		if err != nil {
+			return err
+		}
+		sqlString = fmt.Sprintf("DELETE FROM %v WHERE (from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?)",
+			TAB_NAME_FRIEND_REQUEST)
+		_, err = tx.ExecContext(ctx, sqlString, userId, targetId, targetId, userId)
+		return err
+	})
+	if err != nil {
+		d.logger.Error(ctx, "Fail to block, err: ", err.Error())
+		return err
+	}
+	d.deleteCountsFromCache(ctx, userId, targetId)
+	d.router.PinMaster(ctx, userId, targetId)
+	return nil
+}
+
+func (d *RelationDao) Unblock(ctx context.Context, userId uint64, targetId uint64) error {
+	d.logger.Info(ctx, "Call RelationDao.Unblock, target_id: ", targetId)
+	ctx, cancel := d.timeouts.Write(ctx, "RelationDao.Unblock")
+	defer cancel()
+	sqlString := fmt.Sprintf("DELETE FROM %v WHERE user_id = ? AND target_id = ? AND type = ?", TAB_NAME_RELATION)
+	_, err := d.dbMaster.ExecContext(ctx, sqlString, userId, targetId, model.RELATION_TYPE_BLOCK)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to unblock, err: ", err.Error())
+		return err
+	}
+	d.router.PinMaster(ctx, userId, targetId)
+	return nil
+}
+
+// ListFollowers returns at most limit followers of userId with id less than beforeId, newest first.
+// beforeId 0 means starting from the newest one.
+func (d *RelationDao) ListFollowers(ctx context.Context, userId uint64, beforeId uint64, limit int) ([]*model.Relation, error) {
+	d.logger.Info(ctx, "Call RelationDao.ListFollowers, before_id: ", beforeId, ", limit: ", limit)
+	ctx, cancel := d.timeouts.Read(ctx, "RelationDao.ListFollowers")
+	defer cancel()
+	sqlString := fmt.Sprintf("SELECT id, user_id, target_id FROM %v WHERE target_id = ? AND type = ?"+
+		" AND (? = 0 OR id < ?) ORDER BY id DESC LIMIT ?", TAB_NAME_RELATION)
+	return d.listRelations(ctx, userId, sqlString, userId, model.RELATION_TYPE_FOLLOW, beforeId, beforeId, limit)
+}
+
+// ListFollowing returns at most limit users followed by userId with id less than beforeId, newest first.
+func (d *RelationDao) ListFollowing(ctx context.Context, userId uint64, beforeId uint64, limit int) ([]*model.Relation, error) {
+	d.logger.Info(ctx, "Call RelationDao.ListFollowing, before_id: ", beforeId, ", limit: ", limit)
+	ctx, cancel := d.timeouts.Read(ctx, "RelationDao.ListFollowing")
+	defer cancel()
+	sqlString := fmt.Sprintf("SELECT id, user_id, target_id FROM %v WHERE user_id = ? AND type = ?"+
+		" AND (? = 0 OR id < ?) ORDER BY id DESC LIMIT ?", TAB_NAME_RELATION)
+	return d.listRelations(ctx, userId, sqlString, userId, model.RELATION_TYPE_FOLLOW, beforeId, beforeId, limit)
+}
+
+// ListFriends returns at most limit friends of userId with id less than beforeId, newest first.
+func (d *RelationDao) ListFriends(ctx context.Context, userId uint64, beforeId uint64, limit int) ([]*model.Relation, error) {
+	d.logger.Info(ctx, "Call RelationDao.ListFriends, before_id: ", beforeId, ", limit: ", limit)
+	ctx, cancel := d.timeouts.Read(ctx, "RelationDao.ListFriends")
+	defer cancel()
+	sqlString := fmt.Sprintf("SELECT id, user_id, target_id FROM %v WHERE user_id = ? AND type = ?"+
+		" AND (? = 0 OR id < ?) ORDER BY id DESC LIMIT ?", TAB_NAME_RELATION)
+	return d.listRelations(ctx, userId, sqlString, userId, model.RELATION_TYPE_FRIEND, beforeId, beforeId, limit)
+}
+
+// ListFriendRequests returns at most limit pending friend requests received by userId with id less than beforeId, newest first.
+func (d *RelationDao) ListFriendRequests(ctx context.Context, userId uint64, beforeId uint64, limit int) ([]*model.Relation, error) {
+	d.logger.Info(ctx, "Call RelationDao.ListFriendRequests, before_id: ", beforeId, ", limit: ", limit)
+	ctx, cancel := d.timeouts.Read(ctx, "RelationDao.ListFriendRequests")
+	defer cancel()
+	sqlString := fmt.Sprintf("SELECT id, from_user_id, to_user_id FROM %v WHERE to_user_id = ? AND status = ?"+
+		" AND (? = 0 OR id < ?) ORDER BY id DESC LIMIT ?", TAB_NAME_FRIEND_REQUEST)
+	return d.listRelations(ctx, userId, sqlString, userId, model.FRIEND_REQUEST_STATUS_PENDING, beforeId, beforeId, limit)
+}
+
+// listRelations queries the relations of userId with sqlString.
+func (d *RelationDao) listRelations(ctx context.Context, userId uint64, sqlString string, args ...any) ([]*model.Relation, error) {
+	readDB, _ := d.router.ReadDB(ctx, userId)
+	rows, err := readDB.QueryContext(ctx, sqlString, args...)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to query relations, err: ", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	relations := make([]*model.Relation, 0)
+	for rows.Next() {
+		relation := &model.Relation{}
+		err = rows.Scan(&relation.Id, &relation.UserId, &relation.TargetId)
+		if err != nil {
+			d.logger.Error(ctx, "Fail to scan data, err: ", err.Error())
+			return nil, err
+		}
+		relations = append(relations, relation)
+	}
+	if err = rows.Err(); err != nil {
+		d.logger.Error(ctx, "Fail to iterate rows, err: ", err.Error())
+		return nil, err
+	}
+	return relations, nil
+}
+
+func (d *RelationDao) GetCounts(ctx context.Context, userId uint64) (*model.RelationCounts, error) {
+	d.logger.Info(ctx, "Call RelationDao.GetCounts.")
+	ctx, cancel := d.timeouts.Read(ctx, "RelationDao.GetCounts")
+	defer cancel()
+	counts := &model.RelationCounts{}
+
+	// 1. try to get value from redis first.
+	rKey := d.policy.Key(fmt.Sprintf("%v%d", REDIS_KEY_GET_RELATION_COUNTS_PREFIX, userId))
+	readDB, pinned := d.router.ReadDB(ctx, userId)
+	useCache := d.policy.RelationCounts.Enabled && !pinned
+	if useCache {
+		countsStr, err := d.dbCache.Get(ctx, rKey)
+		if err != nil {
+			if errors.Is(err, cache.ErrMiss) {
+				d.logger.Info(ctx, "Can not find in cache, go to sql DB.")
+			} else {
+				d.logger.Error(ctx, "Can not get from cache, err: ", err.Error(), ". Go to sql DB")
+			}
+		} else {
+			err = json.Unmarshal([]byte(countsStr), counts)
+			if err == nil {
+				d.logger.Info(ctx, "Get relation counts from cache succeeded.")
+				return counts, nil
+			}
+			// delete the value which can not be decoded, so that it is cached again.
+			d.logger.Error(ctx, "json.Unmarshal failed, err: ", err.Error(), ". Go to sql DB")
+			d.deleteCountsFromCache(ctx, userId)
+		}
+	}
+
+	// 2. count from sql DB if not found in redis.
+	sqlString := fmt.Sprintf("SELECT"+
+		" (SELECT COUNT(*) FROM %[1]v WHERE target_id = ? AND type = ?),"+
+		" (SELECT COUNT(*) FROM %[1]v WHERE user_id = ? AND type = ?),"+
+		" (SELECT COUNT(*) FROM %[1]v WHERE user_id = ? AND type = ?),"+
+		" (SELECT COUNT(*) FROM %[2]v WHERE to_user_id = ? AND status = ?)", TAB_NAME_RELATION, TAB_NAME_FRIEND_REQUEST)
+	err := readDB.QueryRowContext(ctx, sqlString,
+		userId, model.RELATION_TYPE_FOLLOW,
+		userId, model.RELATION_TYPE_FOLLOW,
+		userId, model.RELATION_TYPE_FRIEND,
+		userId, model.FRIEND_REQUEST_STATUS_PENDING,
+	).Scan(&counts.Followers, &counts.Following, &counts.Friends, &counts.FriendRequests)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to scan data, err: ", err.Error())
+		return nil, err
+	}
+	if !useCache {
+		return counts, nil
+	}
+
+	// 3. write counts as json string back to cache.
+	cBytes, err := json.Marshal(counts)
+	if err != nil {
+		d.logger.Error(ctx, "json.Marshal failed, err: ", err.Error(), ". It will not be saved to cache.")
+		return counts, nil
+	}
+	err = d.dbCache.Set(ctx, rKey, string(cBytes), d.policy.RelationCounts.Expiration())
+	if err != nil {
+		d.logger.Error(ctx, "redis set failed, err: ", err.Error(), ". It will not be saved to cache.")
+	}
+	return counts, nil
+}
+
+// deleteCountsFromCache invalidates the cached counts of the users whose relations are changed.
+func (d *RelationDao) deleteCountsFromCache(ctx context.Context, userIds ...uint64) {
+	rKeys := make([]string, 0, len(userIds))
+	for _, userId := range userIds {
+		rKeys = append(rKeys, d.policy.Key(fmt.Sprintf("%v%d", REDIS_KEY_GET_RELATION_COUNTS_PREFIX, userId)))
+	}
+	err := d.dbCache.Del(ctx, rKeys...)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to delete from cache, err: ", err.Error())
+	}
+}
+
+// acceptFriendRequest accepts the pending request and saves the friendship in both directions.
+func acceptFriendRequest(ctx context.Context, tx *Tx, fromUserId uint64, toUserId uint64) error {
+	sqlString := fmt.Sprintf("SELECT id FROM %v WHERE from_user_id = ? AND to_user_id = ? AND status = ?%v",
+		TAB_NAME_FRIEND_REQUEST, tx.Dialect.ForUpdate())
+	var id uint64
+	err := tx.QueryRowContext(ctx, sqlString, fromUserId, toUserId, model.FRIEND_REQUEST_STATUS_PENDING).Scan(&id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("UPDATE %v SET status = ? WHERE id = ?", TAB_NAME_FRIEND_REQUEST), model.FRIEND_REQUEST_STATUS_ACCEPTED, id)
+	if err != nil {
+		return err
+	}
+	err = insertRelation(ctx, tx, fromUserId, toUserId, model.RELATION_TYPE_FRIEND)
+	if err != nil {
+		return err
+	}
+	return insertRelation(ctx, tx, toUserId, fromUserId, model.RELATION_TYPE_FRIEND)
+}
+
+func insertRelation(ctx context.Context, tx *Tx, userId uint64, targetId uint64, relationType string) error {
+	sqlString := tx.Dialect.InsertIgnore(fmt.Sprintf("INSERT INTO %v (user_id, target_id, type) VALUES (?,?,?)", TAB_NAME_RELATION))
+	_, err := tx.ExecContext(ctx, sqlString, userId, targetId, relationType)
+	return err
+}
+
+func hasRelation(ctx context.Context, tx *Tx, userId uint64, targetId uint64, relationType string) (bool, error) {
+	sqlString := fmt.Sprintf("SELECT COUNT(*) FROM %v WHERE user_id = ? AND target_id = ? AND type = ?", TAB_NAME_RELATION)
+	var count int
+	err := tx.QueryRowContext(ctx, sqlString, userId, targetId, relationType).Scan(&count)
+	return count > 0, err
+}
+
+// IsBlocked reports whether userId has blocked targetId.
+func (d *RelationDao) IsBlocked(ctx context.Context, userId uint64, targetId uint64) (bool, error) {
+	d.logger.Info(ctx, "Call RelationDao.IsBlocked, target_id: ", targetId)
+	ctx, cancel := d.timeouts.Read(ctx, "RelationDao.IsBlocked")
+	defer cancel()
+	sqlString := fmt.Sprintf("SELECT COUNT(*) FROM %v WHERE user_id = ? AND target_id = ? AND type = ?", TAB_NAME_RELATION)
+	var count int
+	readDB, _ := d.router.ReadDB(ctx, userId)
+	err := readDB.QueryRowContext(ctx, sqlString, userId, targetId, model.RELATION_TYPE_BLOCK).Scan(&count)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to scan data, err: ", err.Error())
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// checkNotBlocked returns ErrRelationBlocked if either of the users has blocked the other.
+func checkNotBlocked(ctx context.Context, tx *Tx, userId uint64, targetId uint64) error {
+	sqlString := fmt.Sprintf("SELECT COUNT(*) FROM %v WHERE ((user_id = ? AND target_id = ?) OR (user_id = ? AND target_id = ?))"+
+		" AND type = ?%v", TAB_NAME_RELATION, tx.Dialect.LockRange(true))
+	var count int
+	err := tx.QueryRowContext(ctx, sqlString, userId, targetId, targetId, userId, model.RELATION_TYPE_BLOCK).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRelationBlocked
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"user-server/model"
+)
+
+func TestRelationDao(t *testing.T) {
+	testRelationRepository(t, newSQLRepositories(t))
+}
+
+func TestMemoryRelationDao(t *testing.T) {
+	testRelationRepository(t, newMemoryRepositories(NewMemoryDB()))
+}
+
+func testRelationRepository(t *testing.T, r *repositories) {
+	ctx := context.Background()
+	relations := r.relations
+	for _, targetId := range []uint64{2, 3, 4} {
+		if err := relations.Follow(ctx, 1, targetId); err != nil {
+			t.Fatalf("follow failed, err: %v", err)
+		}
+	}
+	if err := relations.Follow(ctx, 1, 2); err != nil {
+		t.Fatalf("follow twice failed, err: %v", err)
+	}
+	following, err := relations.ListFollowing(ctx, 1, 0, 2)
+	if err != nil || len(following) != 2 || following[0].TargetId != 4 || following[1].TargetId != 3 {
+		t.Fatalf("expect following 4 and 3, got %v, err: %v", following, err)
+	}
+	following, err = relations.ListFollowing(ctx, 1, following[1].Id, 2)
+	if err != nil || len(following) != 1 || following[0].TargetId != 2 {
+		t.Fatalf("expect following 2 on the next page, got %v, err: %v", following, err)
+	}
+	if err = relations.Unfollow(ctx, 1, 3); err != nil {
+		t.Fatalf("unfollow failed, err: %v", err)
+	}
+
+	if err = relations.RequestFriend(ctx, 2, 1); err != nil {
+		t.Fatalf("request friend failed, err: %v", err)
+	}
+	if err = relations.RequestFriend(ctx, 3, 1); err != nil {
+		t.Fatalf("request friend failed, err: %v", err)
+	}
+	requests, err := relations.ListFriendRequests(ctx, 1, 0, 10)
+	if err != nil || len(requests) != 2 || requests[0].UserId != 3 {
+		t.Fatalf("expect friend requests from 3 and 2, got %v, err: %v", requests, err)
+	}
+	if err = relations.AcceptFriend(ctx, 1, 2); err != nil {
+		t.Fatalf("accept friend failed, err: %v", err)
+	}
+	if err = relations.AcceptFriend(ctx, 1, 2); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expect sql.ErrNoRows accepting twice, got %v", err)
+	}
+	if err = relations.RejectFriend(ctx, 1, 3); err != nil {
+		t.Fatalf("reject friend failed, err: %v", err)
+	}
+	// a pending request of the target is accepted by requesting back.
+	if err = relations.RequestFriend(ctx, 4, 1); err == nil {
+		err = relations.RequestFriend(ctx, 1, 4)
+	}
+	if err != nil {
+		t.Fatalf("request friend back failed, err: %v", err)
+	}
+	friends, err := relations.ListFriends(ctx, 1, 0, 10)
+	if err != nil || len(friends) != 2 || friends[0].TargetId != 4 || friends[1].TargetId != 2 {
+		t.Fatalf("expect friends 4 and 2, got %v, err: %v", friends, err)
+	}
+
+	if err = relations.Block(ctx, 2, 1); err != nil {
+		t.Fatalf("block failed, err: %v", err)
+	}
+	if blocked, err := relations.IsBlocked(ctx, 2, 1); err != nil || !blocked {
+		t.Errorf("expect 1 blocked by 2, got %v, err: %v", blocked, err)
+	}
+	if blocked, err := relations.IsBlocked(ctx, 1, 2); err != nil || blocked {
+		t.Errorf("expect 2 not blocked by 1, got %v, err: %v", blocked, err)
+	}
+	if err = relations.Follow(ctx, 1, 2); !errors.Is(err, ErrRelationBlocked) {
+		t.Errorf("expect ErrRelationBlocked, got %v", err)
+	}
+	counts, err := relations.GetCounts(ctx, 1)
+	expected := model.RelationCounts{Followers: 0, Following: 1, Friends: 1, FriendRequests: 0}
+	if err != nil || *counts != expected {
+		t.Errorf("expect counts %+v, got %+v, err: %v", expected, counts, err)
+	}
+	if err = relations.Unblock(ctx, 2, 1); err == nil {
+		err = relations.Follow(ctx, 1, 2)
+	}
+	if err != nil {
+		t.Errorf("follow after unblock failed, err: %v", err)
+	}
+	if followers, err := relations.ListFollowers(ctx, 2, 0, 10); err != nil || len(followers) != 1 || followers[0].UserId != 1 {
+		t.Errorf("expect follower 1, got %v, err: %v", followers, err)
+	}
+}
@@ -0,0 +1,72 @@
+package dao
+
+import (
+	"context"
+	"time"
+	"user-server/model"
+)
+
+// The repositories are what the services need from the DAOs. They are implemented by the DAOs on sql DB and cache,
+// and by the in-memory DAOs on a MemoryDB, which are used by tests and for running without external services.
+// sql.ErrNoRows is returned by all implementations when nothing is found.
+
+// ProfileRepository saves profiles together with their histories and the reservations of previous usernames.
+type ProfileRepository interface {
+	GetProfileById(ctx context.Context, userId uint64) (*model.Profile, error)
+	GetUserIdByUsername(ctx context.Context, username string) (uint64, error)
+	GetUserIdByPreviousUsername(ctx context.Context, username string) (uint64, error)
+	IsUsernameTaken(ctx context.Context, userId uint64, username string) (bool, error)
+	ChangeUsername(ctx context.Context, userId uint64, username string, policy *model.UsernamePolicy) error
+	// DeleteUsernameFromCache removes a stale username mapping, it does nothing if usernames are not cached.
+	DeleteUsernameFromCache(ctx context.Context, username string)
+	Update(ctx context.Context, userId uint64, profile *model.Profile) error
+	Upsert(ctx context.Context, profile *model.Profile) error
+	Insert(ctx context.Context, profile *model.Profile) error
+	Delete(ctx context.Context, userId uint64) error
+	Restore(ctx context.Context, userId uint64, window time.Duration) error
+	// MarkProfileExists must be called after a profile is created outside of the repository, e.g. by UserRepository.
+	MarkProfileExists(ctx context.Context, userId uint64)
+}
+
+type ProfileHistoryRepository interface {
+	List(ctx context.Context, userId uint64, beforeVersion uint64, limit int) ([]*model.ProfileHistory, error)
+	GetAt(ctx context.Context, userId uint64, timestamp int64) (*model.ProfileHistory, error)
+}
+
+type UserRepository interface {
+	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
+	GetUserById(ctx context.Context, userId uint64) (*model.User, error)
+	InsertWithProfile(ctx context.Context, user *model.User, profile *model.Profile) (uint64, error)
+}
+
+type RelationRepository interface {
+	Follow(ctx context.Context, userId uint64, targetId uint64) error
+	Unfollow(ctx context.Context, userId uint64, targetId uint64) error
+	RequestFriend(ctx context.Context, userId uint64, targetId uint64) error
+	AcceptFriend(ctx context.Context, userId uint64, fromUserId uint64) error
+	RejectFriend(ctx context.Context, userId uint64, fromUserId uint64) error
+	Block(ctx context.Context, userId uint64, targetId uint64) error
+	Unblock(ctx context.Context, userId uint64, targetId uint64) error
+	ListFollowers(ctx context.Context, userId uint64, beforeId uint64, limit int) ([]*model.Relation, error)
+	ListFollowing(ctx context.Context, userId uint64, beforeId uint64, limit int) ([]*model.Relation, error)
+	ListFriends(ctx context.Context, userId uint64, beforeId uint64, limit int) ([]*model.Relation, error)
+	ListFriendRequests(ctx context.Context, userId uint64, beforeId uint64, limit int) ([]*model.Relation, error)
+	GetCounts(ctx context.Context, userId uint64) (*model.RelationCounts, error)
+	IsBlocked(ctx context.Context, userId uint64, targetId uint64) (bool, error)
+}
+
+var (
+	_ ProfileRepository        = (*ProfileDao)(nil)
+	_ ProfileHistoryRepository = (*ProfileHistoryDao)(nil)
+	_ UserRepository           = (*UserDao)(nil)
+	_ RelationRepository       = (*RelationDao)(nil)
+
+	_ ProfileRepository        = (*MemoryProfileDao)(nil)
+	_ ProfileHistoryRepository = (*MemoryProfileHistoryDao)(nil)
+	_ UserRepository           = (*MemoryUserDao)(nil)
+	_ RelationRepository       = (*MemoryRelationDao)(nil)
+
+	_ ProfileRepository        = (*ShardedProfileDao)(nil)
+	_ ProfileHistoryRepository = (*ShardedProfileHistoryDao)(nil)
+	_ UserRepository           = (*ShardedUserDao)(nil)
+)
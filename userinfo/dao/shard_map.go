@@ -0,0 +1,306 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"loggers"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"user-server/cache"
+	"user-server/dialect"
+	"user-server/model"
+)
+
+// The users are sharded by ranges of user ids. The ranges are kept in TAB_NAME_SHARD_RANGE of the global database,
+// i.e. mysql-master, together with TAB_NAME_USER_EMAIL which finds the user id of an email, and the relations which
+// are not sharded. Each shard keeps user_tab, profile_tab and the histories of its users.
+const (
+	TAB_NAME_SHARD_RANGE = "shard_range_tab"
+	TAB_NAME_USER_EMAIL  = "user_email_tab"
+
+	SHARD_RANGE_SIZE_DEFAULT           = 1000000
+	SHARD_MAP_REFRESH_INTERVAL_DEFAULT = time.Second * 10
+	// a user id out of all ranges may be allocated in a range created by another instance since the last refresh,
+	// so the ranges are reloaded, but at most once in the interval.
+	SHARD_MAP_RELOAD_MIN_INTERVAL = time.Second
+)
+
+var SHARD_RANGE_UNIQUE_KEY_START_ID = dialect.UniqueKey{Table: TAB_NAME_SHARD_RANGE, Name: "uk_start_id", Columns: []string{"start_id"}}
+
+var (
+	// ErrNoShard is returned when the user id is in no range, i.e. it has never been allocated.
+	ErrNoShard = errors.New("no shard of user id")
+	// ErrShardRangeMoving is returned when writing to a user whose range is being moved to another shard.
+	ErrShardRangeMoving = errors.New("shard range is moving")
+	// ErrUnknownShard is returned when a range is assigned to a shard which is not configured.
+	ErrUnknownShard = errors.New("unknown shard")
+)
+
+// Shard is a master and its replicas holding the users of some ranges, the reads of a shard are routed by Router.
+type Shard struct {
+	Name   string
+	Master *DBMaster
+	Slave  *DBSlave
+	Router *DBRouter
+}
+
+// ShardMap routes user ids to the shards by the ranges loaded from the global database. The ranges are reloaded
+// by Run, so that a range moved by "userinfo reshard" is routed to the new shard by all instances.
+type ShardMap struct {
+	global    *DBMaster
+	shards    []*Shard
+	rangeSize uint64
+	timeouts  *Timeouts
+	logger    *logger.Logger
+	// next picks the shard of a new user by round-robin.
+	next atomic.Uint64
+
+	mu sync.RWMutex
+	// ranges are sorted by start id.
+	ranges []*model.ShardRange
+	// missReloaded is when the ranges were last reloaded for a user id out of all ranges.
+	missReloaded time.Time
+}
+
+// NewShardMap creates the map of the shards, rangeSize 0 means SHARD_RANGE_SIZE_DEFAULT.
+// The ranges are not loaded until Load or Run is called.
+func NewShardMap(global *DBMaster, shards []*Shard, rangeSize uint64, timeouts *Timeouts, logger *logger.Logger) *ShardMap {
+	if rangeSize == 0 {
+		rangeSize = SHARD_RANGE_SIZE_DEFAULT
+	}
+	return &ShardMap{
+		global:    global,
+		shards:    shards,
+		rangeSize: rangeSize,
+		timeouts:  timeouts,
+		logger:    logger,
+	}
+}
+
+// Global returns the global database.
+func (m *ShardMap) Global() *DBMaster {
+	return m.global
+}
+
+// Shards returns the shards in the order of the config.
+func (m *ShardMap) Shards() []*Shard {
+	return m.shards
+}
+
+// Shard returns the shard of the name, ErrUnknownShard is returned if it is not configured.
+func (m *ShardMap) Shard(name string) (*Shard, error) {
+	for _, shard := range m.shards {
+		if shard.Name == name {
+			return shard, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnknownShard, name)
+}
+
+// Ranges returns a copy of the loaded ranges sorted by start id.
+func (m *ShardMap) Ranges() []*model.ShardRange {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ranges := make([]*model.ShardRange, 0, len(m.ranges))
+	for _, r := range m.ranges {
+		copied := *r
+		ranges = append(ranges, &copied)
+	}
+	return ranges
+}
+
+// Load reloads the ranges from the global database.
+func (m *ShardMap) Load(ctx context.Context) error {
+	ctx, cancel := m.timeouts.Read(ctx, "ShardMap.Load")
+	defer cancel()
+	ranges, err := selectShardRanges(ctx, m.global.DB)
+	if err != nil {
+		m.logger.Error(ctx, "Fail to load shard ranges, err: ", err.Error())
+		return err
+	}
+	m.mu.Lock()
+	m.ranges = ranges
+	m.mu.Unlock()
+	return nil
+}
+
+// Run reloads the ranges every interval until ctx is done, interval 0 means SHARD_MAP_REFRESH_INTERVAL_DEFAULT.
+// The ranges loaded last are kept if a reload fails.
+func (m *ShardMap) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = SHARD_MAP_REFRESH_INTERVAL_DEFAULT
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		_ = m.Load(ctx)
+	}
+}
+
+// ShardOf returns the shard to read the user from. The user is read from the shard it is moved from until the
+// move is done. ErrNoShard is returned if the user id is in no range.
+func (m *ShardMap) ShardOf(ctx context.Context, userId uint64) (*Shard, error) {
+	r, err := m.findRange(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return m.Shard(r.Shard)
+}
+
+// WriteShardOf returns the shard to write the user to, ErrShardRangeMoving is returned while the range of the
+// user is being moved.
+func (m *ShardMap) WriteShardOf(ctx context.Context, userId uint64) (*Shard, error) {
+	r, err := m.findRange(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if r.State == model.SHARD_RANGE_STATE_MOVING {
+		return nil, ErrShardRangeMoving
+	}
+	return m.Shard(r.Shard)
+}
+
+// findRange returns the range of the user id, the ranges are reloaded if none is found, unless they have been
+// reloaded for another user id within SHARD_MAP_RELOAD_MIN_INTERVAL.
+func (m *ShardMap) findRange(ctx context.Context, userId uint64) (*model.ShardRange, error) {
+	if r := m.lookup(userId); r != nil {
+		return r, nil
+	}
+	m.mu.Lock()
+	reload := time.Since(m.missReloaded) >= SHARD_MAP_RELOAD_MIN_INTERVAL
+	if reload {
+		// the others missing meanwhile do not reload again.
+		m.missReloaded = time.Now()
+	}
+	m.mu.Unlock()
+	if !reload {
+		return nil, ErrNoShard
+	}
+	m.logger.Info(ctx, "User id is in no shard range, reload the ranges. user_id: ", userId)
+	if err := m.Load(ctx); err != nil {
+		return nil, err
+	}
+	if r := m.lookup(userId); r != nil {
+		return r, nil
+	}
+	return nil, ErrNoShard
+}
+
+func (m *ShardMap) lookup(userId uint64) *model.ShardRange {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := sort.Search(len(m.ranges), func(i int) bool {
+		return m.ranges[i].EndId > userId
+	})
+	if i < len(m.ranges) && m.ranges[i].Contains(userId) {
+		return m.ranges[i]
+	}
+	return nil
+}
+
+// nextShard picks the shard of a new user.
+func (m *ShardMap) nextShard() *Shard {
+	return m.shards[(m.next.Add(1)-1)%uint64(len(m.shards))]
+}
+
+// allocateUserId allocates the id of a new user on the shard in tx of the global database. The id is taken from the
+// lowest active range of the shard which is not full, or a new range above all ranges is created for the shard.
+// Allocations racing to create the same range fail with a duplicate key on SHARD_RANGE_UNIQUE_KEY_START_ID, and
+// they can be retried in a new transaction.
+func (m *ShardMap) allocateUserId(ctx context.Context, tx *Tx, shard *Shard) (uint64, error) {
+	sqlString := fmt.Sprintf("SELECT id, next_id FROM %v WHERE shard = ? AND state = ? AND next_id < end_id"+
+		" ORDER BY start_id LIMIT 1%v", TAB_NAME_SHARD_RANGE, tx.Dialect.ForUpdate())
+	var rangeId, userId uint64
+	err := tx.QueryRowContext(ctx, sqlString, shard.Name, model.SHARD_RANGE_STATE_ACTIVE).Scan(&rangeId, &userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		sqlString = fmt.Sprintf("SELECT COALESCE(MAX(end_id), 1) FROM %v", TAB_NAME_SHARD_RANGE)
+		err = tx.QueryRowContext(ctx, sqlString).Scan(&userId)
+		if err != nil {
+			return 0, err
+		}
+		sqlString = fmt.Sprintf("INSERT INTO %v (start_id, end_id, next_id, shard, state) VALUES (?,?,?,?,?)",
+			TAB_NAME_SHARD_RANGE)
+		rangeId, err = tx.Dialect.InsertId(ctx, tx, sqlString, userId, userId+m.rangeSize, userId, shard.Name,
+			model.SHARD_RANGE_STATE_ACTIVE)
+		if err == nil {
+			m.logger.Info(ctx, "Create shard range, shard: ", shard.Name, ", start_id: ", userId)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	sqlString = fmt.Sprintf("UPDATE %v SET next_id = ? WHERE id = ?", TAB_NAME_SHARD_RANGE)
+	if _, err = tx.ExecContext(ctx, sqlString, userId+1, rangeId); err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
+
+// selectShardRanges selects all ranges sorted by start id.
+func selectShardRanges(ctx context.Context, db *sql.DB) ([]*model.ShardRange, error) {
+	sqlString := fmt.Sprintf("SELECT id, start_id, end_id, next_id, shard, state FROM %v ORDER BY start_id",
+		TAB_NAME_SHARD_RANGE)
+	rows, err := db.QueryContext(ctx, sqlString)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ranges := make([]*model.ShardRange, 0)
+	for rows.Next() {
+		r := &model.ShardRange{}
+		if err = rows.Scan(&r.Id, &r.StartId, &r.EndId, &r.NextId, &r.Shard, &r.State); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, rows.Err()
+}
+
+// lockUsername locks the username in the cache while it is checked on the shards other than the shard of the user,
+// whose master is given. The shard of the user checks it again in the transaction which takes it. ErrUsernameTaken is
+// returned if it is taken on another shard or being taken by another user. unlock must be called after it is taken.
+func (m *ShardMap) lockUsername(ctx context.Context, dbCache cache.Cache, master *DBMaster, userId uint64,
+	username string) (func(), error) {
+	rKey := REDIS_KEY_LOCK_USERNAME_PREFIX + username
+	locked, err := dbCache.SetNX(ctx, rKey, "1", SHARDED_USERNAME_LOCK)
+	if err != nil {
+		m.logger.Error(ctx, "Fail to lock username, err: ", err.Error())
+		return nil, err
+	}
+	if !locked {
+		m.logger.Warning(ctx, "Username is being taken by another user.")
+		return nil, ErrUsernameTaken
+	}
+	unlock := func() {
+		_ = dbCache.Del(context.WithoutCancel(ctx), rKey)
+	}
+
+	ctx, cancel := m.timeouts.Read(ctx, "ProfileDao.IsUsernameTaken")
+	defer cancel()
+	for _, shard := range m.shards {
+		if shard.Master == master {
+			continue
+		}
+		// masters are read, since a username taken just now may not be in the replicas yet.
+		var count int
+		err = shard.Master.QueryRowContext(ctx, usernameTakenSql(shard.Master.Dialect, false), username, userId,
+			username, userId).Scan(&count)
+		if err == nil && count > 0 {
+			err = ErrUsernameTaken
+		}
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+	}
+	return unlock, nil
+}
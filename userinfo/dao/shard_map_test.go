@@ -0,0 +1,160 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"user-server/cache"
+	"user-server/model"
+)
+
+// newTestShardMap creates the shards a and b on sqlite, with ranges of rangeSize user ids.
+func newTestShardMap(t *testing.T, rangeSize uint64) *ShardMap {
+	lgr := newTestLogger(t)
+	dbCache := cache.NewMemoryCache()
+	shards := make([]*Shard, 0, 2)
+	for _, name := range []string{"a", "b"} {
+		dbMaster := newTestDB(t)
+		dbSlave := NewDBSlave(NewReplica(dbMaster.DB, name, 1))
+		shards = append(shards, &Shard{
+			Name:   name,
+			Master: dbMaster,
+			Slave:  dbSlave,
+			Router: NewDBRouter(dbMaster, dbSlave, dbCache, 0, 0, lgr),
+		})
+	}
+	shardMap := NewShardMap(newTestDB(t), shards, rangeSize, NewTimeouts(0, 0, nil), lgr)
+	if err := shardMap.Load(context.Background()); err != nil {
+		t.Fatalf("load shard ranges failed, err: %v", err)
+	}
+	return shardMap
+}
+
+func newShardedRepositories(t *testing.T, shardMap *ShardMap) *repositories {
+	global := shardMap.Global()
+	dbCache := cache.NewMemoryCache()
+	policy := cache.NewPolicy()
+	timeouts := NewTimeouts(0, 0, nil)
+	lgr := newTestLogger(t)
+	dbSlave := NewDBSlave(NewReplica(global.DB, "global", 1))
+	profileDao := NewShardedProfileDao(shardMap, dbCache, cache.NewLoader(dbCache, 0), nil, policy, timeouts, lgr)
+	if err := profileDao.RebuildUserIdFilter(context.Background()); err != nil {
+		t.Fatalf("rebuild user id filter failed, err: %v", err)
+	}
+	return &repositories{
+		profiles:  profileDao,
+		histories: NewShardedProfileHistoryDao(shardMap, timeouts, lgr),
+		users:     NewShardedUserDao(shardMap, dbCache, timeouts, lgr),
+		relations: NewRelationDao(global, dbSlave, dbCache, policy, NewDBRouter(global, dbSlave, dbCache, 0, 0, lgr), timeouts, lgr),
+	}
+}
+
+func TestShardedProfileDao(t *testing.T) {
+	testProfileRepository(t, newShardedRepositories(t, newTestShardMap(t, 10)))
+}
+
+func TestShardedProfileDao_Username(t *testing.T) {
+	ctx := context.Background()
+	r := newShardedRepositories(t, newTestShardMap(t, 10))
+	// the users are allocated on the shards by round-robin.
+	carolId, err := r.users.InsertWithProfile(ctx, &model.User{Email: "carol@example.com"}, &model.Profile{Username: "carol"})
+	if err != nil {
+		t.Fatalf("insert user failed, err: %v", err)
+	}
+	daveId, err := r.users.InsertWithProfile(ctx, &model.User{Email: "dave@example.com"}, &model.Profile{})
+	if err != nil {
+		t.Fatalf("insert user failed, err: %v", err)
+	}
+	profiles := r.profiles.(*ShardedProfileDao)
+	carolDao, _ := profiles.writeDao(ctx, carolId)
+	daveDao, _ := profiles.writeDao(ctx, daveId)
+	if carolDao == daveDao {
+		t.Fatalf("expect users on different shards")
+	}
+	if err = r.profiles.Update(ctx, daveId, &model.Profile{Username: "carol"}); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("expect carol taken on the other shard, got %v", err)
+	}
+	if err = r.profiles.Upsert(ctx, &model.Profile{UserId: daveId, Username: "carol"}); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("expect carol taken on the other shard by upsert, got %v", err)
+	}
+	if err = r.profiles.Update(ctx, daveId, &model.Profile{Username: "dave"}); err != nil {
+		t.Errorf("update username failed, err: %v", err)
+	}
+}
+
+func TestShardMap_Allocate(t *testing.T) {
+	ctx := context.Background()
+	shardMap := newTestShardMap(t, 2)
+	r := newShardedRepositories(t, shardMap)
+	// new users take turns between the shards, each shard allocates from its own range until it is full.
+	expected := []struct {
+		userId uint64
+		shard  string
+	}{{1, "a"}, {3, "b"}, {2, "a"}, {4, "b"}, {5, "a"}}
+	for i, e := range expected {
+		email := fmt.Sprintf("user%v@example.com", i)
+		userId, err := r.users.InsertWithProfile(ctx, &model.User{Email: email}, &model.Profile{Email: email})
+		if err != nil || userId != e.userId {
+			t.Fatalf("expect user %v, got %v, err: %v", e.userId, userId, err)
+		}
+		shard, err := shardMap.ShardOf(ctx, userId)
+		if err != nil || shard.Name != e.shard {
+			t.Errorf("expect user %v in shard %v, got %+v, err: %v", userId, e.shard, shard, err)
+		}
+	}
+	if _, err := r.users.InsertWithProfile(ctx, &model.User{Email: "user0@example.com"}, &model.Profile{}); err == nil {
+		t.Errorf("expect error registering an email twice across the shards")
+	}
+	if _, err := shardMap.ShardOf(ctx, 100); !errors.Is(err, ErrNoShard) {
+		t.Errorf("expect ErrNoShard, got %v", err)
+	}
+	if user, err := r.users.GetUserByEmail(ctx, "user1@example.com"); err != nil || user.Id != 3 {
+		t.Errorf("expect user 3, got %+v, err: %v", user, err)
+	}
+}
+
+func TestShardMover_Move(t *testing.T) {
+	ctx := context.Background()
+	shardMap := newTestShardMap(t, 10)
+	r := newShardedRepositories(t, shardMap)
+	userId, err := r.users.InsertWithProfile(ctx, &model.User{Email: "alice@example.com"},
+		&model.Profile{Username: "alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("insert user failed, err: %v", err)
+	}
+	r.profiles.MarkProfileExists(ctx, userId)
+	if err = r.profiles.Update(ctx, userId, &model.Profile{Timezone: "Europe/Paris"}); err != nil {
+		t.Fatalf("update failed, err: %v", err)
+	}
+
+	mover := NewShardMover(shardMap, newTestLogger(t))
+	if err = mover.Move(ctx, 1, "b", 0); err != nil {
+		t.Fatalf("move failed, err: %v", err)
+	}
+	if err = shardMap.Load(ctx); err != nil {
+		t.Fatalf("load shard ranges failed, err: %v", err)
+	}
+	if shard, err := shardMap.WriteShardOf(ctx, userId); err != nil || shard.Name != "b" {
+		t.Fatalf("expect user in shard b, got %+v, err: %v", shard, err)
+	}
+	profile, err := r.profiles.GetProfileById(ctx, userId)
+	if err != nil || profile.Username != "alice" || profile.Timezone != "Europe/Paris" {
+		t.Errorf("expect moved profile of alice, got %+v, err: %v", profile, err)
+	}
+	if histories, err := r.histories.List(ctx, userId, 0, 10); err != nil || len(histories) != 2 {
+		t.Errorf("expect 2 moved histories, got %v, err: %v", len(histories), err)
+	}
+	if user, err := r.users.GetUserByEmail(ctx, "alice@example.com"); err != nil || user.Id != userId {
+		t.Errorf("expect user %v, got %+v, err: %v", userId, user, err)
+	}
+	source, _ := shardMap.Shard("a")
+	if _, err = NewUserDao(source.Master, nil, newTestLogger(t)).GetUserById(ctx, userId); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expect user deleted from shard a, got %v", err)
+	}
+	// the moved range goes on allocating the ids of shard b.
+	if userId, err = r.users.InsertWithProfile(ctx, &model.User{Email: "bob@example.com"}, &model.Profile{}); err != nil || userId != 2 {
+		t.Errorf("expect user 2 in the moved range, got %v, err: %v", userId, err)
+	}
+}
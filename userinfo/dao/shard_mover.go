@@ -0,0 +1,335 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"loggers"
+	"strings"
+	"time"
+	"user-server/dialect"
+	"user-server/model"
+)
+
+// SHARD_MOVE_BATCH is the number of user ids copied or deleted in one transaction.
+const SHARD_MOVE_BATCH = 1000
+
+var (
+	// ErrShardsInitialized is returned when initializing the shards which already have ranges.
+	ErrShardsInitialized = errors.New("shard ranges exist")
+	// ErrShardsNotInitialized is returned when there is no range while a shard has users, the users must be
+	// assigned to the shard by ShardMover.Init before new users are allocated.
+	ErrShardsNotInitialized = errors.New("shards not initialized")
+)
+
+// shardTable is a table moved with the users, keyed by the column of user id.
+type shardTable struct {
+	name         string
+	userIdColumn string
+}
+
+// shardTables are the tables kept in the shards. The rows keep their ids only in user_tab, where the id is the
+// user id, the others take the ids generated by the shard they are moved to.
+var shardTables = []shardTable{
+	{name: TAB_NAME_USER, userIdColumn: "id"},
+	{name: TAB_NAME_PROFILE, userIdColumn: "user_id"},
+	{name: TAB_NAME_PROFILE_HISTORY, userIdColumn: "user_id"},
+	{name: TAB_NAME_USERNAME_HISTORY, userIdColumn: "user_id"},
+}
+
+// ShardMover assigns the users to the shards and moves ranges of users between them, see "userinfo reshard".
+type ShardMover struct {
+	shards *ShardMap
+	logger *logger.Logger
+}
+
+func NewShardMover(shards *ShardMap, logger *logger.Logger) *ShardMover {
+	return &ShardMover{
+		shards: shards,
+		logger: logger,
+	}
+}
+
+// Verify returns ErrShardsNotInitialized if there is no range while a shard has users, since new users would be
+// allocated the ids of the existing ones.
+func (m *ShardMover) Verify(ctx context.Context) error {
+	ranges, err := selectShardRanges(ctx, m.shards.global.DB)
+	if err != nil || len(ranges) > 0 {
+		return err
+	}
+	for _, shard := range m.shards.Shards() {
+		var maxId uint64
+		if maxId, err = maxUserId(ctx, shard); err != nil {
+			return err
+		}
+		if maxId > 0 {
+			return fmt.Errorf("%w: shard %v has users", ErrShardsNotInitialized, shard.Name)
+		}
+	}
+	return nil
+}
+
+// Init assigns the users existing in the shard to a full range, so that no more users are allocated in it, and
+// saves their emails in the global database. It is run once before the service is started with the shards.
+func (m *ShardMover) Init(ctx context.Context, shardName string) error {
+	shard, err := m.shards.Shard(shardName)
+	if err != nil {
+		return err
+	}
+	ranges, err := selectShardRanges(ctx, m.shards.global.DB)
+	if err != nil {
+		return err
+	}
+	if len(ranges) > 0 {
+		return ErrShardsInitialized
+	}
+	maxId, err := maxUserId(ctx, shard)
+	if err != nil {
+		return err
+	}
+	if maxId == 0 {
+		m.logger.Info(ctx, "Shard has no users, nothing to initialize. shard: ", shard.Name)
+		return nil
+	}
+
+	sqlString := fmt.Sprintf("SELECT id, email FROM %v WHERE id > ? ORDER BY id LIMIT ?", TAB_NAME_USER)
+	insertSql := m.shards.global.Dialect.InsertIgnore(fmt.Sprintf("INSERT INTO %v (email, user_id) VALUES (?,?)",
+		TAB_NAME_USER_EMAIL))
+	var lastId uint64
+	for lastId < maxId {
+		rows, err := shard.Master.QueryContext(ctx, sqlString, lastId, SHARD_MOVE_BATCH)
+		if err != nil {
+			return err
+		}
+		emails := make(map[uint64]string, SHARD_MOVE_BATCH)
+		for rows.Next() {
+			var email string
+			if err = rows.Scan(&lastId, &email); err != nil {
+				break
+			}
+			emails[lastId] = email
+		}
+		if err = errors.Join(err, rows.Err(), rows.Close()); err != nil {
+			return err
+		}
+		if len(emails) == 0 {
+			break
+		}
+		err = m.shards.global.WithTx(ctx, func(tx *Tx) error {
+			for userId, email := range emails {
+				if _, err := tx.ExecContext(ctx, insertSql, email, userId); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		m.logger.Info(ctx, "Save emails of users, shard: ", shard.Name, ", last user_id: ", lastId)
+	}
+
+	sqlString = fmt.Sprintf("INSERT INTO %v (start_id, end_id, next_id, shard, state) VALUES (?,?,?,?,?)",
+		TAB_NAME_SHARD_RANGE)
+	_, err = m.shards.global.ExecContext(ctx, sqlString, 1, maxId+1, maxId+1, shard.Name, model.SHARD_RANGE_STATE_ACTIVE)
+	if err != nil {
+		return err
+	}
+	m.logger.Info(ctx, "Initialize shard done, shard: ", shard.Name, ", users up to: ", maxId)
+	return nil
+}
+
+// Move moves the range starting at startId to the shard named to. The writes of the range are stopped first, and
+// the users are copied after wait, which must be longer than the refresh interval of the ranges so that all instances
+// have stopped writing. The range is then routed to the new shard, and the users are deleted from the old shard
+// after another wait. A move which failed before the range is routed to the new shard can be run again, the range is
+// copied again from the start.
+func (m *ShardMover) Move(ctx context.Context, startId uint64, to string, wait time.Duration) error {
+	target, err := m.shards.Shard(to)
+	if err != nil {
+		return err
+	}
+	r, err := m.selectRange(ctx, startId)
+	if err != nil {
+		return err
+	}
+	source, err := m.shards.Shard(r.Shard)
+	if err != nil {
+		return err
+	}
+	if source == target {
+		return fmt.Errorf("range %v is already in shard %v", startId, to)
+	}
+
+	// 1. stop writes, new users are not allocated in a moving range either.
+	if err = m.setRange(ctx, r.Id, source.Name, model.SHARD_RANGE_STATE_MOVING); err != nil {
+		return err
+	}
+	m.logger.Info(ctx, "Stop writes of range, start_id: ", startId, ", wait: ", wait)
+	if err = sleep(ctx, wait); err != nil {
+		return err
+	}
+	// next_id does not change once the range is moving.
+	if r, err = m.selectRange(ctx, startId); err != nil {
+		return err
+	}
+
+	// 2. copy the users allocated in the range.
+	for from := r.StartId; from < r.NextId; from += SHARD_MOVE_BATCH {
+		until := min(from+SHARD_MOVE_BATCH, r.NextId)
+		if err = m.copyUsers(ctx, source, target, from, until); err != nil {
+			m.logger.Error(ctx, "Fail to copy users, from: ", from, ", err: ", err.Error())
+			return err
+		}
+		m.logger.Info(ctx, "Copy users done, until: ", until)
+	}
+
+	// 3. route the range to the target.
+	if err = m.setRange(ctx, r.Id, target.Name, model.SHARD_RANGE_STATE_ACTIVE); err != nil {
+		return err
+	}
+	m.logger.Info(ctx, "Range is moved, start_id: ", startId, ", shard: ", target.Name, ", wait: ", wait)
+	if err = sleep(ctx, wait); err != nil {
+		return err
+	}
+
+	// 4. delete the users from the source, which is no longer read after wait.
+	for from := r.StartId; from < r.NextId; from += SHARD_MOVE_BATCH {
+		until := min(from+SHARD_MOVE_BATCH, r.NextId)
+		err = source.Master.WithTx(ctx, func(tx *Tx) error {
+			return deleteUsers(ctx, tx, from, until)
+		})
+		if err != nil {
+			m.logger.Error(ctx, "Fail to delete moved users, from: ", from, ", err: ", err.Error())
+			return err
+		}
+	}
+	m.logger.Info(ctx, "Move range done, start_id: ", startId, ", from: ", source.Name, ", to: ", target.Name)
+	return nil
+}
+
+func (m *ShardMover) selectRange(ctx context.Context, startId uint64) (*model.ShardRange, error) {
+	ranges, err := selectShardRanges(ctx, m.shards.global.DB)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range ranges {
+		if r.StartId == startId {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no range starts at %v", sql.ErrNoRows, startId)
+}
+
+func (m *ShardMover) setRange(ctx context.Context, rangeId uint64, shard string, state string) error {
+	sqlString := fmt.Sprintf("UPDATE %v SET shard = ?, state = ? WHERE id = ?", TAB_NAME_SHARD_RANGE)
+	_, err := m.shards.global.ExecContext(ctx, sqlString, shard, state, rangeId)
+	return err
+}
+
+// copyUsers replaces the rows of the users in [from, until) in target with those in source.
+func (m *ShardMover) copyUsers(ctx context.Context, source *Shard, target *Shard, from uint64, until uint64) error {
+	return target.Master.WithTx(ctx, func(tx *Tx) error {
+		if err := deleteUsers(ctx, tx, from, until); err != nil {
+			return err
+		}
+		for _, table := range shardTables {
+			if err := copyRows(ctx, source.Master, tx, table, from, until); err != nil {
+				return fmt.Errorf("copy %v: %w", table.name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// copyRows copies the rows of the users in [from, until) of the table from source into tx.
+func copyRows(ctx context.Context, source *DBMaster, tx *Tx, table shardTable, from uint64, until uint64) error {
+	sqlString := fmt.Sprintf("SELECT * FROM %v WHERE %v >= ? AND %v < ? ORDER BY id", table.name,
+		table.userIdColumn, table.userIdColumn)
+	rows, err := source.QueryContext(ctx, sqlString, from, until)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	// the ids are generated by the target unless they are the user ids.
+	inserted := make([]int, 0, len(columns))
+	for i, column := range columns {
+		if column != "id" || table.userIdColumn == "id" {
+			inserted = append(inserted, i)
+		}
+	}
+	names := make([]string, 0, len(inserted))
+	for _, i := range inserted {
+		names = append(names, columns[i])
+	}
+	insertSql := fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v)", table.name, strings.Join(names, ", "),
+		strings.TrimSuffix(strings.Repeat("?,", len(names)), ","))
+
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(pointers...); err != nil {
+			return err
+		}
+		args := make([]any, 0, len(inserted))
+		for _, i := range inserted {
+			args = append(args, copyValue(tx.Dialect, values[i]))
+		}
+		if _, err = tx.ExecContext(ctx, insertSql, args...); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// copyValue converts a scanned value to the argument inserting it again. Text and json are scanned as bytes, which
+// are taken as binary by some drivers, and sqlite keeps timestamps as the text CURRENT_TIMESTAMP formats.
+func copyValue(sqlDialect dialect.Dialect, value any) any {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		if sqlDialect.Name() == dialect.DRIVER_SQLITE {
+			return v.UTC().Format(time.DateTime)
+		}
+	}
+	return value
+}
+
+// deleteUsers deletes the rows of the users in [from, until) of all shard tables in tx.
+func deleteUsers(ctx context.Context, tx *Tx, from uint64, until uint64) error {
+	for _, table := range shardTables {
+		sqlString := fmt.Sprintf("DELETE FROM %v WHERE %v >= ? AND %v < ?", table.name, table.userIdColumn,
+			table.userIdColumn)
+		if _, err := tx.ExecContext(ctx, sqlString, from, until); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func maxUserId(ctx context.Context, shard *Shard) (uint64, error) {
+	var maxId uint64
+	err := shard.Master.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(id), 0) FROM %v", TAB_NAME_USER)).Scan(&maxId)
+	return maxId, err
+}
+
+// sleep waits for d, it returns the error of ctx if ctx is done before.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
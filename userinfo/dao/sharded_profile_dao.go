@@ -0,0 +1,255 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"loggers"
+	"sync/atomic"
+	"time"
+	"user-server/cache"
+	"user-server/model"
+)
+
+const (
+	// REDIS_KEY_LOCK_USERNAME_PREFIX locks a username while it is checked on all shards and taken on one of them.
+	REDIS_KEY_LOCK_USERNAME_PREFIX = "userinfo:lock_username:"
+	SHARDED_USERNAME_LOCK          = time.Second * 10
+)
+
+// ShardedProfileDao is the ProfileRepository on the shards. A profile is kept in the shard of its user, while
+// usernames are looked up on all shards since they are unique across the shards. The cache and the user id filter
+// are shared by the shards.
+type ShardedProfileDao struct {
+	shards  *ShardMap
+	daos    map[string]*ProfileDao
+	dbCache cache.Cache
+	logger  *logger.Logger
+}
+
+func NewShardedProfileDao(shards *ShardMap, dbCache cache.Cache, loader *cache.Loader, local *cache.Local,
+	policy *cache.Policy, timeouts *Timeouts, logger *logger.Logger) *ShardedProfileDao {
+	filterSlaves := make([]*DBSlave, 0, len(shards.Shards()))
+	for _, shard := range shards.Shards() {
+		filterSlaves = append(filterSlaves, shard.Slave)
+	}
+	filterRebuildStarted := &atomic.Int64{}
+	daos := make(map[string]*ProfileDao, len(shards.Shards()))
+	for _, shard := range shards.Shards() {
+		dao := NewProfileDao(shard.Master, shard.Slave, dbCache, loader, local, policy, shard.Router, timeouts, logger)
+		dao.filterSlaves = filterSlaves
+		dao.filterRebuildStarted = filterRebuildStarted
+		daos[shard.Name] = dao
+	}
+	return &ShardedProfileDao{
+		shards:  shards,
+		daos:    daos,
+		dbCache: dbCache,
+		logger:  logger,
+	}
+}
+
+// readDao returns the DAO of the shard to read the user from, sql.ErrNoRows is returned if the user id is in no range.
+func (d *ShardedProfileDao) readDao(ctx context.Context, userId uint64) (*ProfileDao, error) {
+	shard, err := d.shards.ShardOf(ctx, userId)
+	if errors.Is(err, ErrNoShard) {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		d.logger.Error(ctx, "Fail to find shard of user, err: ", err.Error())
+		return nil, err
+	}
+	return d.daos[shard.Name], nil
+}
+
+// writeDao returns the DAO of the shard to write the user to, sql.ErrNoRows is returned if the user id is in no
+// range, and ErrShardRangeMoving while the range of the user is being moved.
+func (d *ShardedProfileDao) writeDao(ctx context.Context, userId uint64) (*ProfileDao, error) {
+	shard, err := d.shards.WriteShardOf(ctx, userId)
+	if errors.Is(err, ErrNoShard) {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		d.logger.Error(ctx, "Fail to find shard to write user, err: ", err.Error())
+		return nil, err
+	}
+	return d.daos[shard.Name], nil
+}
+
+func (d *ShardedProfileDao) GetProfileById(ctx context.Context, userId uint64) (*model.Profile, error) {
+	dao, err := d.readDao(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return dao.GetProfileById(ctx, userId)
+}
+
+// GetUserIdByUsername looks the username up on the shards in turn, the first shard finding it caches the mapping.
+func (d *ShardedProfileDao) GetUserIdByUsername(ctx context.Context, username string) (uint64, error) {
+	for _, shard := range d.shards.Shards() {
+		userId, err := d.daos[shard.Name].GetUserIdByUsername(ctx, username)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return userId, err
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (d *ShardedProfileDao) GetUserIdByPreviousUsername(ctx context.Context, username string) (uint64, error) {
+	for _, shard := range d.shards.Shards() {
+		userId, err := d.daos[shard.Name].GetUserIdByPreviousUsername(ctx, username)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return userId, err
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (d *ShardedProfileDao) IsUsernameTaken(ctx context.Context, userId uint64, username string) (bool, error) {
+	for _, shard := range d.shards.Shards() {
+		taken, err := d.daos[shard.Name].IsUsernameTaken(ctx, userId, username)
+		if err != nil || taken {
+			return taken, err
+		}
+	}
+	return false, nil
+}
+
+// ChangeUsername changes the username on the shard of the user, see ShardMap.lockUsername.
+func (d *ShardedProfileDao) ChangeUsername(ctx context.Context, userId uint64, username string, policy *model.UsernamePolicy) error {
+	d.logger.Info(ctx, "Call ShardedProfileDao.ChangeUsername, username: ", username)
+	dao, err := d.writeDao(ctx, userId)
+	if err != nil {
+		return err
+	}
+	unlock, err := d.shards.lockUsername(ctx, d.dbCache, dao.dbMaster, userId, username)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return dao.ChangeUsername(ctx, userId, username, policy)
+}
+
+// DeleteUsernameFromCache removes a stale username mapping, the mappings of all shards are in the same cache.
+func (d *ShardedProfileDao) DeleteUsernameFromCache(ctx context.Context, username string) {
+	d.daos[d.shards.Shards()[0].Name].DeleteUsernameFromCache(ctx, username)
+}
+
+// Update updates the profile on the shard of the user. A username set by the update is locked and checked on the
+// other shards as ChangeUsername does.
+func (d *ShardedProfileDao) Update(ctx context.Context, userId uint64, profile *model.Profile) error {
+	dao, unlock, err := d.writeDaoWithUsername(ctx, userId, profile.Username)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return dao.Update(ctx, userId, profile)
+}
+
+// Upsert creates or updates the profile on the shard of the user, a profile can not be created for a user id
+// which has not been allocated.
+func (d *ShardedProfileDao) Upsert(ctx context.Context, profile *model.Profile) error {
+	dao, unlock, err := d.writeDaoWithUsername(ctx, profile.UserId, profile.Username)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return dao.Upsert(ctx, profile)
+}
+
+func (d *ShardedProfileDao) Insert(ctx context.Context, profile *model.Profile) error {
+	dao, unlock, err := d.writeDaoWithUsername(ctx, profile.UserId, profile.Username)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return dao.Insert(ctx, profile)
+}
+
+// writeDaoWithUsername returns the DAO of the shard to write the user to, with the username locked across the
+// shards unless it is empty. unlock must be called after the write.
+func (d *ShardedProfileDao) writeDaoWithUsername(ctx context.Context, userId uint64, username string) (*ProfileDao, func(), error) {
+	dao, err := d.writeDao(ctx, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if username == "" {
+		return dao, func() {}, nil
+	}
+	unlock, err := d.shards.lockUsername(ctx, d.dbCache, dao.dbMaster, userId, username)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dao, unlock, nil
+}
+
+func (d *ShardedProfileDao) Delete(ctx context.Context, userId uint64) error {
+	dao, err := d.writeDao(ctx, userId)
+	if err != nil {
+		return err
+	}
+	return dao.Delete(ctx, userId)
+}
+
+func (d *ShardedProfileDao) Restore(ctx context.Context, userId uint64, window time.Duration) error {
+	dao, err := d.writeDao(ctx, userId)
+	if err != nil {
+		return err
+	}
+	return dao.Restore(ctx, userId, window)
+}
+
+// MarkProfileExists updates the user id filter and the cache, which are shared by the shards.
+func (d *ShardedProfileDao) MarkProfileExists(ctx context.Context, userId uint64) {
+	d.daos[d.shards.Shards()[0].Name].MarkProfileExists(ctx, userId)
+}
+
+// RebuildUserIdFilter rebuilds the user id filter from the profiles of all shards.
+func (d *ShardedProfileDao) RebuildUserIdFilter(ctx context.Context) error {
+	return d.daos[d.shards.Shards()[0].Name].RebuildUserIdFilter(ctx)
+}
+
+// ShardedProfileHistoryDao is the ProfileHistoryRepository on the shards, the histories are kept in the shard
+// of the user.
+type ShardedProfileHistoryDao struct {
+	shards *ShardMap
+	daos   map[string]*ProfileHistoryDao
+	logger *logger.Logger
+}
+
+func NewShardedProfileHistoryDao(shards *ShardMap, timeouts *Timeouts, logger *logger.Logger) *ShardedProfileHistoryDao {
+	daos := make(map[string]*ProfileHistoryDao, len(shards.Shards()))
+	for _, shard := range shards.Shards() {
+		daos[shard.Name] = NewProfileHistoryDao(shard.Router, timeouts, logger)
+	}
+	return &ShardedProfileHistoryDao{
+		shards: shards,
+		daos:   daos,
+		logger: logger,
+	}
+}
+
+// List returns no histories if the user id is in no range.
+func (d *ShardedProfileHistoryDao) List(ctx context.Context, userId uint64, beforeVersion uint64, limit int) ([]*model.ProfileHistory, error) {
+	shard, err := d.shards.ShardOf(ctx, userId)
+	if errors.Is(err, ErrNoShard) {
+		return make([]*model.ProfileHistory, 0), nil
+	}
+	if err != nil {
+		d.logger.Error(ctx, "Fail to find shard of user, err: ", err.Error())
+		return nil, err
+	}
+	return d.daos[shard.Name].List(ctx, userId, beforeVersion, limit)
+}
+
+func (d *ShardedProfileHistoryDao) GetAt(ctx context.Context, userId uint64, timestamp int64) (*model.ProfileHistory, error) {
+	shard, err := d.shards.ShardOf(ctx, userId)
+	if errors.Is(err, ErrNoShard) {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		d.logger.Error(ctx, "Fail to find shard of user, err: ", err.Error())
+		return nil, err
+	}
+	return d.daos[shard.Name].GetAt(ctx, userId, timestamp)
+}
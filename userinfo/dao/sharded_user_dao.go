@@ -0,0 +1,144 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"loggers"
+	"user-server/cache"
+	"user-server/model"
+)
+
+// SHARD_ALLOCATE_ATTEMPTS bounds the retries of allocating a user id when the allocations race to create a range.
+const SHARD_ALLOCATE_ATTEMPTS = 3
+
+// ShardedUserDao is the UserRepository on the shards. The email of each user is kept in the global database to find
+// the shard of the user, and to keep emails unique across the shards.
+type ShardedUserDao struct {
+	shards   *ShardMap
+	daos     map[string]*UserDao
+	dbCache  cache.Cache
+	timeouts *Timeouts
+	logger   *logger.Logger
+}
+
+func NewShardedUserDao(shards *ShardMap, dbCache cache.Cache, timeouts *Timeouts, logger *logger.Logger) *ShardedUserDao {
+	daos := make(map[string]*UserDao, len(shards.Shards()))
+	for _, shard := range shards.Shards() {
+		daos[shard.Name] = NewUserDao(shard.Master, timeouts, logger)
+	}
+	return &ShardedUserDao{
+		shards:   shards,
+		daos:     daos,
+		dbCache:  dbCache,
+		timeouts: timeouts,
+		logger:   logger,
+	}
+}
+
+func (d *ShardedUserDao) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	d.logger.Info(ctx, "Call ShardedUserDao.GetUserByEmail, email: ", email)
+	userId, err := d.getUserIdByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return d.GetUserById(ctx, userId)
+}
+
+func (d *ShardedUserDao) getUserIdByEmail(ctx context.Context, email string) (uint64, error) {
+	ctx, cancel := d.timeouts.Read(ctx, "ShardedUserDao.GetUserByEmail")
+	defer cancel()
+	sqlString := fmt.Sprintf("SELECT user_id FROM %v WHERE email = ?", TAB_NAME_USER_EMAIL)
+	var userId uint64
+	err := d.shards.global.QueryRowContext(ctx, sqlString, email).Scan(&userId)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to scan data, err: ", err.Error())
+		return 0, err
+	}
+	return userId, nil
+}
+
+// GetUserById reads the user from its shard, sql.ErrNoRows is returned if the user id is in no range.
+func (d *ShardedUserDao) GetUserById(ctx context.Context, userId uint64) (*model.User, error) {
+	shard, err := d.shards.ShardOf(ctx, userId)
+	if errors.Is(err, ErrNoShard) {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		d.logger.Error(ctx, "Fail to find shard of user, err: ", err.Error())
+		return nil, err
+	}
+	return d.daos[shard.Name].GetUserById(ctx, userId)
+}
+
+// InsertWithProfile allocates the id of the user on the next shard together with the email in the global database,
+// then inserts the user and its profile to the shard. The email is released if the insert to the shard fails.
+// The username of the profile is locked across the shards as ShardedProfileDao.ChangeUsername does.
+func (d *ShardedUserDao) InsertWithProfile(ctx context.Context, user *model.User, profile *model.Profile) (uint64, error) {
+	d.logger.Info(ctx, "Call ShardedUserDao.InsertWithProfile, user: ", user, ", profile: ", profile)
+	shard := d.shards.nextShard()
+	if profile.Username != "" {
+		unlock, err := d.shards.lockUsername(ctx, d.dbCache, shard.Master, 0, profile.Username)
+		if err != nil {
+			return 0, err
+		}
+		defer unlock()
+	}
+	userId, err := d.reserveEmail(ctx, shard, user.Email)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to allocate user id, err: ", err.Error())
+		return 0, err
+	}
+	if d.shards.lookup(userId) == nil {
+		// the range is created by the allocation, the user is read at once after it is inserted.
+		if err = d.shards.Load(ctx); err != nil {
+			d.releaseEmail(ctx, user.Email, userId)
+			return 0, err
+		}
+	}
+	inserted := *user
+	inserted.Id = userId
+	if _, err = d.daos[shard.Name].InsertWithProfile(ctx, &inserted, profile); err != nil {
+		d.releaseEmail(ctx, user.Email, userId)
+		return 0, err
+	}
+	d.logger.Info(ctx, "Insert user with profile into shard succeed, shard: ", shard.Name, ", user_id: ", userId)
+	return userId, nil
+}
+
+// reserveEmail allocates the user id on the shard and saves the email of it, in one transaction of the global database.
+func (d *ShardedUserDao) reserveEmail(ctx context.Context, shard *Shard, email string) (uint64, error) {
+	ctx, cancel := d.timeouts.Write(ctx, "ShardedUserDao.InsertWithProfile")
+	defer cancel()
+	var userId uint64
+	var err error
+	for attempt := 1; attempt <= SHARD_ALLOCATE_ATTEMPTS; attempt++ {
+		err = d.shards.global.WithTx(ctx, func(tx *Tx) error {
+			id, err := d.shards.allocateUserId(ctx, tx, shard)
+			if err != nil {
+				return err
+			}
+			userId = id
+			sqlString := fmt.Sprintf("INSERT INTO %v (email, user_id) VALUES (?,?)", TAB_NAME_USER_EMAIL)
+			_, err = tx.ExecContext(ctx, sqlString, email, userId)
+			return err
+		})
+		if !d.shards.global.Dialect.IsDuplicateKey(err, SHARD_RANGE_UNIQUE_KEY_START_ID) {
+			break
+		}
+		d.logger.Warning(ctx, "Shard range is created concurrently, retry to allocate user id.")
+	}
+	return userId, err
+}
+
+// releaseEmail deletes the email of the user who failed to be inserted, so that the email can be registered again.
+func (d *ShardedUserDao) releaseEmail(ctx context.Context, email string, userId uint64) {
+	ctx, cancel := d.timeouts.Write(context.WithoutCancel(ctx), "ShardedUserDao.InsertWithProfile")
+	defer cancel()
+	sqlString := fmt.Sprintf("DELETE FROM %v WHERE email = ? AND user_id = ?", TAB_NAME_USER_EMAIL)
+	if _, err := d.shards.global.ExecContext(ctx, sqlString, email, userId); err != nil {
+		// the email can not be registered until the row is deleted by hand.
+		d.logger.Error(ctx, "Fail to release email of user not inserted, user_id: ", userId, ", err: ", err.Error())
+	}
+}
@@ -0,0 +1,59 @@
+package dao
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	DAO_READ_TIMEOUT_DEFAULT  = time.Second
+	DAO_WRITE_TIMEOUT_DEFAULT = time.Second * 3
+)
+
+// Timeouts bounds the time of each DAO operation, including its cache calls, so that a slow query is cancelled
+// instead of holding the connection after the caller gives up. An operation, e.g. ProfileDao.GetProfileById,
+// takes its own timeout if configured, otherwise the one of reads or writes. The deadline of the caller still
+// applies if it is earlier. A nil Timeouts only applies the deadline of the caller.
+type Timeouts struct {
+	read       time.Duration
+	write      time.Duration
+	operations map[string]time.Duration
+}
+
+// NewTimeouts creates the timeouts, zero read or write timeout means the default one.
+func NewTimeouts(read time.Duration, write time.Duration, operations map[string]time.Duration) *Timeouts {
+	if read <= 0 {
+		read = DAO_READ_TIMEOUT_DEFAULT
+	}
+	if write <= 0 {
+		write = DAO_WRITE_TIMEOUT_DEFAULT
+	}
+	return &Timeouts{
+		read:       read,
+		write:      write,
+		operations: operations,
+	}
+}
+
+// Read returns the context of a read operation, cancel must be called when the operation is done.
+func (t *Timeouts) Read(ctx context.Context, operation string) (context.Context, context.CancelFunc) {
+	if t == nil {
+		return context.WithCancel(ctx)
+	}
+	return t.with(ctx, operation, t.read)
+}
+
+// Write returns the context of a write operation, cancel must be called when the operation is done.
+func (t *Timeouts) Write(ctx context.Context, operation string) (context.Context, context.CancelFunc) {
+	if t == nil {
+		return context.WithCancel(ctx)
+	}
+	return t.with(ctx, operation, t.write)
+}
+
+func (t *Timeouts) with(ctx context.Context, operation string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if operationTimeout, ok := t.operations[operation]; ok && operationTimeout > 0 {
+		timeout = operationTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,72 @@
+package dao
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTimeouts(t *testing.T) {
+	operations := map[string]time.Duration{
+		"ProfileDao.GetProfileById": time.Millisecond * 200,
+		"ProfileDao.Update":         time.Minute,
+		"ProfileDao.Insert":         0,
+	}
+	configured := NewTimeouts(time.Second*10, time.Second*20, operations)
+	cases := []struct {
+		name      string
+		timeouts  *Timeouts
+		write     bool
+		operation string
+		// deadline is the deadline of the caller, zero means none.
+		deadline time.Duration
+		// expected is the timeout applied, zero means no deadline.
+		expected time.Duration
+	}{
+		{name: "default read", timeouts: NewTimeouts(0, 0, nil), operation: "ProfileDao.GetProfileById",
+			expected: DAO_READ_TIMEOUT_DEFAULT},
+		{name: "default write", timeouts: NewTimeouts(0, 0, nil), write: true, operation: "ProfileDao.Update",
+			expected: DAO_WRITE_TIMEOUT_DEFAULT},
+		{name: "read", timeouts: configured, operation: "ProfileDao.GetProfileByUsername", expected: time.Second * 10},
+		{name: "write", timeouts: configured, write: true, operation: "ProfileDao.Upsert", expected: time.Second * 20},
+		{name: "read override", timeouts: configured, operation: "ProfileDao.GetProfileById",
+			expected: time.Millisecond * 200},
+		{name: "write override", timeouts: configured, write: true, operation: "ProfileDao.Update", expected: time.Minute},
+		{name: "zero override", timeouts: configured, write: true, operation: "ProfileDao.Insert",
+			expected: time.Second * 20},
+		{name: "earlier deadline of caller", timeouts: configured, operation: "ProfileDao.GetProfileByUsername",
+			deadline: time.Second, expected: time.Second},
+		{name: "nil", operation: "ProfileDao.GetProfileById"},
+		{name: "nil with deadline of caller", operation: "ProfileDao.GetProfileById", deadline: time.Second,
+			expected: time.Second},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			if c.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, c.deadline)
+				defer cancel()
+			}
+			start := time.Now()
+			var cancel context.CancelFunc
+			if c.write {
+				ctx, cancel = c.timeouts.Write(ctx, c.operation)
+			} else {
+				ctx, cancel = c.timeouts.Read(ctx, c.operation)
+			}
+			deadline, ok := ctx.Deadline()
+			if c.expected == 0 {
+				if ok {
+					t.Errorf("expect no deadline, got %v", deadline.Sub(start))
+				}
+			} else if timeout := deadline.Sub(start); !ok || (timeout-c.expected).Abs() > time.Millisecond*100 {
+				t.Errorf("expect timeout %v, got %v", c.expected, timeout)
+			}
+			cancel()
+			if ctx.Err() == nil {
+				t.Errorf("expect context cancelled")
+			}
+		})
+	}
+}
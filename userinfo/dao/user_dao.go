@@ -10,23 +10,27 @@ import (
 const TAB_NAME_USER = "user_tab"
 
 type UserDao struct {
-	db     *DBMaster
-	logger *logger.Logger
+	db       *DBMaster
+	timeouts *Timeouts
+	logger   *logger.Logger
 }
 
-func NewUserDao(db *DBMaster, logger *logger.Logger) *UserDao {
+func NewUserDao(db *DBMaster, timeouts *Timeouts, logger *logger.Logger) *UserDao {
 	return &UserDao{
-		db:     db,
-		logger: logger,
+		db:       db,
+		timeouts: timeouts,
+		logger:   logger,
 	}
 }
 
 func (d *UserDao) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
 	d.logger.Info(ctx, "Call UserDao.GetUserByEmail, email: ", email)
+	ctx, cancel := d.timeouts.Read(ctx, "UserDao.GetUserByEmail")
+	defer cancel()
 	user := &model.User{}
 	sqlString := fmt.Sprintf("SELECT id, name, password, email, status"+
 		" FROM %v WHERE email = ?", TAB_NAME_USER)
-	row := d.db.QueryRow(sqlString, email)
+	row := d.db.QueryRowContext(ctx, sqlString, email)
 
 	err := row.Scan(
 		&user.Id,
@@ -43,11 +47,37 @@ func (d *UserDao) GetUserByEmail(ctx context.Context, email string) (*model.User
 	return user, nil
 }
 
+func (d *UserDao) GetUserById(ctx context.Context, userId uint64) (*model.User, error) {
+	d.logger.Info(ctx, "Call UserDao.GetUserById, user_id: ", userId)
+	ctx, cancel := d.timeouts.Read(ctx, "UserDao.GetUserById")
+	defer cancel()
+	user := &model.User{}
+	sqlString := fmt.Sprintf("SELECT id, name, password, email, status"+
+		" FROM %v WHERE id = ?", TAB_NAME_USER)
+	row := d.db.QueryRowContext(ctx, sqlString, userId)
+
+	err := row.Scan(
+		&user.Id,
+		&user.Name,
+		&user.Password,
+		&user.Email,
+		&user.Status,
+	)
+	if err != nil {
+		d.logger.Error(ctx, "Fail to scan data, err: ", err.Error())
+		return nil, err
+	}
+	d.logger.Info(ctx, "Get user done, user_id: ", user.Id)
+	return user, nil
+}
+
 func (d *UserDao) Insert(ctx context.Context, user *model.User) error {
 	d.logger.Info(ctx, "Call UserDao.Insert, user: ", user)
+	ctx, cancel := d.timeouts.Write(ctx, "UserDao.Insert")
+	defer cancel()
 	updateFields, args := user.UpdateFields()
 	sqlString := user.InsertSql(updateFields, TAB_NAME_USER)
-	_, err := d.db.Exec(sqlString, args...)
+	_, err := d.db.ExecContext(ctx, sqlString, args...)
 	d.logger.Debug(ctx, "sql: ", sqlString)
 	if err != nil {
 		d.logger.Error(ctx, "Fail to insert into sql DB, err: ", err.Error())
@@ -56,3 +86,35 @@ func (d *UserDao) Insert(ctx context.Context, user *model.User) error {
 	d.logger.Info(ctx, "Insert user into sql DB succeed.")
 	return nil
 }
+
+// InsertWithProfile inserts the user and its default profile in one transaction, and returns the id of the user.
+func (d *UserDao) InsertWithProfile(ctx context.Context, user *model.User, profile *model.Profile) (uint64, error) {
+	d.logger.Info(ctx, "Call UserDao.InsertWithProfile, user: ", user, ", profile: ", profile)
+	ctx, cancel := d.timeouts.Write(ctx, "UserDao.InsertWithProfile")
+	defer cancel()
+	var userId uint64
+	err := d.db.WithTx(ctx, func(tx *Tx) error {
+		updateFields, args := user.UpdateFields()
+		sqlString := user.InsertSql(updateFields, TAB_NAME_USER)
+		id, err := tx.Dialect.InsertId(ctx, tx, sqlString, args...)
+		if err != nil {
+			return err
+		}
+		userId = id
+
+		// the new user is the actor who creates the profile.
+		traceData, _ := ctx.Value(logger.TraceDataKey{}).(logger.TraceData)
+		traceData.UserId = userId
+		profile.UserId = userId
+		if err = checkUsernameWrite(ctx, tx, userId, profile.Username, nil); err != nil {
+			return err
+		}
+		return insertProfile(context.WithValue(ctx, logger.TraceDataKey{}, traceData), tx, profile)
+	})
+	if err != nil {
+		d.logger.Error(ctx, "Fail to insert user with profile into sql DB, err: ", err.Error())
+		return 0, err
+	}
+	d.logger.Info(ctx, "Insert user with profile into sql DB succeed, user_id: ", userId)
+	return userId, nil
+}
@@ -0,0 +1,137 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	DRIVER_MYSQL    = "mysql"
+	DRIVER_POSTGRES = "postgres"
+	DRIVER_SQLITE   = "sqlite3"
+)
+
+var (
+	// ErrUnknownDriver is returned when the configured driver has no dialect.
+	ErrUnknownDriver = errors.New("unknown sql driver")
+	// ErrReplicationStopped is returned when the database is a replica but does not replicate.
+	ErrReplicationStopped = errors.New("replication stopped")
+)
+
+// Querier is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// UniqueKey is a unique key of a table. Name is the name of the key in mysql and of the constraint in postgres,
+// sqlite reports the columns instead.
+type UniqueKey struct {
+	Table   string
+	Name    string
+	Columns []string
+}
+
+// Dialect builds the parts of SQL which differ between databases. Queries are written with '?' placeholders for
+// all dialects, and the SQL returned by a dialect takes its arguments with '?' as well, in the order of its
+// parameters.
+//
+// Timestamp expressions are the SQL of a timestamp, e.g. Now() or a column.
+type Dialect interface {
+	// Name is the driver of the dialect in config, which is also the directory of its migrations.
+	Name() string
+	// DriverName is the driver registered to database/sql.
+	DriverName() string
+	// DSN returns the data source name of the database. db is the file of the database for sqlite.
+	DSN(user string, password string, host string, port string, db string) string
+
+	// Now is the current timestamp in seconds.
+	Now() string
+	// NowMillis is the current timestamp in milliseconds at least.
+	NowMillis() string
+	// AddSeconds adds the seconds of an argument to the timestamp.
+	AddSeconds(timestamp string) string
+	// AddMicroseconds adds the microseconds of an argument to the timestamp.
+	AddMicroseconds(timestamp string) string
+	// MicrosecondsBetween returns the microseconds from the timestamp from to the timestamp to.
+	MicrosecondsBetween(from string, to string) string
+	// UnixTimestamp converts the timestamp to unix seconds.
+	UnixTimestamp(timestamp string) string
+	// FromUnixTimestamp converts unix seconds to a timestamp.
+	FromUnixTimestamp(seconds string) string
+	// JSONMergePatch merges the json object of an argument into the column as RFC 7396 says, null values remove
+	// the keys. The column is taken as an empty object if it is null.
+	JSONMergePatch(column string) string
+
+	// ForUpdate locks the selected rows until the transaction ends.
+	ForUpdate() string
+	// ForUpdateSkipLocked locks the selected rows, and skips the rows locked by other transactions.
+	ForUpdateSkipLocked() string
+	// LockRange locks the rows counted by a query and the gaps between them, so that the count does not change
+	// until the transaction ends. It is empty if the database can not lock gaps, LockKey must be used instead.
+	LockRange(shared bool) string
+	// LockKey locks the key until the transaction of q ends. It does nothing if LockRange locks the gaps.
+	LockKey(ctx context.Context, q Querier, key string) error
+	// Lock takes the named lock of the session of q, it waits at most timeout and reports whether it is taken.
+	Lock(ctx context.Context, q Querier, name string, timeout time.Duration) (bool, error)
+	Unlock(ctx context.Context, q Querier, name string) error
+
+	// OnConflictUpdate is appended to an insert, to update the columns with the inserted values instead if the
+	// row exists with the same key.
+	OnConflictUpdate(key []string, columns ...string) string
+	// InsertIgnore turns an insert into the one which skips the rows existing with the same unique keys.
+	InsertIgnore(insert string) string
+	// InsertId executes the insert of a single row, and returns the id generated for it.
+	InsertId(ctx context.Context, q Querier, insert string, args ...any) (uint64, error)
+	// IsDuplicateKey reports whether err is a duplicate entry error on the unique key.
+	IsDuplicateKey(err error, key UniqueKey) bool
+
+	// ReplicationLag returns how far the replica lags behind the master, which is 0 if db is not a replica.
+	ReplicationLag(ctx context.Context, db *sql.DB) (time.Duration, error)
+}
+
+// New returns the dialect of the driver in config.
+func New(driver string) (Dialect, error) {
+	switch driver {
+	case DRIVER_MYSQL:
+		return MySQL{}, nil
+	case DRIVER_POSTGRES:
+		return Postgres{}, nil
+	case DRIVER_SQLITE:
+		return SQLite{}, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnknownDriver, driver)
+}
+
+// Rebind replaces the '?' placeholders of query with '$1', '$2' and so on. Quoted strings and identifiers are
+// kept as they are.
+func Rebind(query string) string {
+	if strings.IndexByte(query, '?') < 0 {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteString(fmt.Sprintf("$%d", n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,108 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	cases := map[string]string{
+		"SELECT 1": "SELECT 1",
+		"SELECT a FROM t WHERE b = ? AND c IN (?,?)":                                "SELECT a FROM t WHERE b = $1 AND c IN ($2,$3)",
+		"SELECT '?' FROM t WHERE \"?\" = ?":                                         "SELECT '?' FROM t WHERE \"?\" = $1",
+		"UPDATE t SET j = COALESCE(j,'{}'::jsonb) || CAST(? AS jsonb) WHERE id = ?": "UPDATE t SET j = COALESCE(j,'{}'::jsonb) || CAST($1 AS jsonb) WHERE id = $2",
+	}
+	for query, expected := range cases {
+		if rebound := Rebind(query); rebound != expected {
+			t.Errorf("expect %q, got %q", expected, rebound)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, driver := range []string{DRIVER_MYSQL, DRIVER_POSTGRES, DRIVER_SQLITE} {
+		d, err := New(driver)
+		if err != nil || d.Name() != driver {
+			t.Errorf("expect dialect of %v, got %v, err: %v", driver, d, err)
+		}
+	}
+	if _, err := New("oracle"); !errors.Is(err, ErrUnknownDriver) {
+		t.Errorf("expect ErrUnknownDriver, got %v", err)
+	}
+}
+
+// openSQLite opens a new sqlite database with a table t of a unique key on k.
+func openSQLite(t *testing.T) *sql.DB {
+	d := SQLite{}
+	db, err := sql.Open(d.DriverName(), d.DSN("", "", "", "", filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatalf("open sqlite failed, err: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	_, err = db.Exec("CREATE TABLE t (id integer PRIMARY KEY AUTOINCREMENT, k text NOT NULL, v text, j text)")
+	if err == nil {
+		_, err = db.Exec("CREATE UNIQUE INDEX t_uk_k ON t (k)")
+	}
+	if err != nil {
+		t.Fatalf("create table failed, err: %v", err)
+	}
+	return db
+}
+
+func TestSQLite(t *testing.T) {
+	d := SQLite{}
+	db := openSQLite(t)
+	ctx := context.Background()
+	key := UniqueKey{Table: "t", Name: "t_uk_k", Columns: []string{"k"}}
+
+	id, err := d.InsertId(ctx, db, "INSERT INTO t (k, v, j) VALUES (?, ?, ?)", "a", "1", `{"x":1,"y":2}`)
+	if err != nil || id != 1 {
+		t.Fatalf("expect id 1, got %v, err: %v", id, err)
+	}
+	_, err = d.InsertId(ctx, db, "INSERT INTO t (k, v) VALUES (?, ?)", "a", "2")
+	if !d.IsDuplicateKey(err, key) {
+		t.Errorf("expect duplicate key, got %v", err)
+	}
+	if d.IsDuplicateKey(err, UniqueKey{Table: "t", Columns: []string{"v"}}) {
+		t.Errorf("expect no duplicate of another key, err: %v", err)
+	}
+
+	_, err = db.ExecContext(ctx, d.InsertIgnore("INSERT INTO t (k, v) VALUES (?, ?)"), "a", "3")
+	if err != nil {
+		t.Fatalf("insert ignore failed, err: %v", err)
+	}
+	_, err = db.ExecContext(ctx, "INSERT INTO t (k, v) VALUES (?, ?)"+d.OnConflictUpdate([]string{"k"}, "v"), "a", "4")
+	if err != nil {
+		t.Fatalf("upsert failed, err: %v", err)
+	}
+	_, err = db.ExecContext(ctx, fmt.Sprintf("UPDATE t SET j = %v WHERE k = ?", d.JSONMergePatch("j")), `{"x":null,"z":3}`, "a")
+	if err != nil {
+		t.Fatalf("merge json failed, err: %v", err)
+	}
+	var v, j string
+	if err = db.QueryRowContext(ctx, "SELECT v, j FROM t WHERE k = ?", "a").Scan(&v, &j); err != nil {
+		t.Fatalf("select failed, err: %v", err)
+	}
+	if v != "4" || j != `{"y":2,"z":3}` {
+		t.Errorf("expect v 4 and j {\"y\":2,\"z\":3}, got %v and %v", v, j)
+	}
+
+	var unix, seconds, micros int64
+	var future bool
+	sqlString := fmt.Sprintf("SELECT %v, %v, %v, %v > %v", d.UnixTimestamp(d.FromUnixTimestamp("?")),
+		d.UnixTimestamp(d.AddSeconds(d.FromUnixTimestamp("?"))),
+		d.MicrosecondsBetween(d.FromUnixTimestamp("?"), d.AddMicroseconds(d.FromUnixTimestamp("?"))),
+		d.AddSeconds(d.Now()), d.NowMillis())
+	err = db.QueryRowContext(ctx, sqlString, 1700000000, 1700000000, 60, 1700000000, 1700000000, 1500000, 10).
+		Scan(&unix, &seconds, &micros, &future)
+	if err != nil {
+		t.Fatalf("select timestamps failed, err: %v", err)
+	}
+	if unix != 1700000000 || seconds != 1700000060 || micros != 1500000 || !future {
+		t.Errorf("expect 1700000000, 1700000060, 1500000 and true, got %v, %v, %v and %v", unix, seconds, micros, future)
+	}
+}
@@ -0,0 +1,163 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const MYSQL_ERR_DUP_ENTRY = 1062
+
+// MySQL is the dialect of mysql 8.
+type MySQL struct{}
+
+func (MySQL) Name() string {
+	return DRIVER_MYSQL
+}
+
+func (MySQL) DriverName() string {
+	return DRIVER_MYSQL
+}
+
+func (MySQL) DSN(user string, password string, host string, port string, db string) string {
+	return fmt.Sprintf("%v:%v@tcp(%v:%v)/%v", user, password, host, port, db)
+}
+
+func (MySQL) Now() string {
+	return "NOW()"
+}
+
+func (MySQL) NowMillis() string {
+	return "NOW(3)"
+}
+
+func (MySQL) AddSeconds(timestamp string) string {
+	return fmt.Sprintf("%v + INTERVAL ? SECOND", timestamp)
+}
+
+func (MySQL) AddMicroseconds(timestamp string) string {
+	return fmt.Sprintf("%v + INTERVAL ? MICROSECOND", timestamp)
+}
+
+func (MySQL) MicrosecondsBetween(from string, to string) string {
+	return fmt.Sprintf("TIMESTAMPDIFF(MICROSECOND, %v, %v)", from, to)
+}
+
+func (MySQL) UnixTimestamp(timestamp string) string {
+	return fmt.Sprintf("UNIX_TIMESTAMP(%v)", timestamp)
+}
+
+func (MySQL) FromUnixTimestamp(seconds string) string {
+	return fmt.Sprintf("FROM_UNIXTIME(%v)", seconds)
+}
+
+func (MySQL) JSONMergePatch(column string) string {
+	return fmt.Sprintf("JSON_MERGE_PATCH(COALESCE(%v,'{}'),?)", column)
+}
+
+func (MySQL) ForUpdate() string {
+	return " FOR UPDATE"
+}
+
+func (MySQL) ForUpdateSkipLocked() string {
+	return " FOR UPDATE SKIP LOCKED"
+}
+
+// LockRange takes the next-key locks of InnoDB, which lock the gaps as well.
+func (MySQL) LockRange(shared bool) string {
+	if shared {
+		return " LOCK IN SHARE MODE"
+	}
+	return " FOR UPDATE"
+}
+
+func (MySQL) LockKey(ctx context.Context, q Querier, key string) error {
+	return nil
+}
+
+func (MySQL) Lock(ctx context.Context, q Querier, name string, timeout time.Duration) (bool, error) {
+	var locked sql.NullInt64
+	err := q.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, timeout.Seconds()).Scan(&locked)
+	if err != nil {
+		return false, err
+	}
+	return locked.Int64 == 1, nil
+}
+
+func (MySQL) Unlock(ctx context.Context, q Querier, name string) error {
+	_, err := q.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", name)
+	return err
+}
+
+func (MySQL) OnConflictUpdate(key []string, columns ...string) string {
+	updates := make([]string, 0, len(columns))
+	for _, column := range columns {
+		updates = append(updates, fmt.Sprintf("%v = VALUES(%v)", column, column))
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+}
+
+func (MySQL) InsertIgnore(insert string) string {
+	return strings.Replace(insert, "INSERT INTO", "INSERT IGNORE INTO", 1)
+}
+
+func (MySQL) InsertId(ctx context.Context, q Querier, insert string, args ...any) (uint64, error) {
+	result, err := q.ExecContext(ctx, insert, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return uint64(id), err
+}
+
+func (MySQL) IsDuplicateKey(err error, key UniqueKey) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != MYSQL_ERR_DUP_ENTRY {
+		return false
+	}
+	// the message is like "Duplicate entry '1' for key 'profile_tab.uk_user_id'".
+	return strings.Contains(mysqlErr.Message, "'"+key.Name+"'") || strings.Contains(mysqlErr.Message, "."+key.Name+"'")
+}
+
+// ReplicationLag reads Seconds_Behind_Master of the replica.
+func (MySQL) ReplicationLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	rows, err := db.QueryContext(ctx, "SHOW SLAVE STATUS")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	for i, column := range columns {
+		if column != "Seconds_Behind_Master" {
+			continue
+		}
+		if values[i] == nil {
+			return 0, ErrReplicationStopped
+		}
+		seconds, err := strconv.ParseInt(string(values[i]), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, fmt.Errorf("no Seconds_Behind_Master in slave status")
+}
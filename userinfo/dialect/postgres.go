@@ -0,0 +1,246 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// POSTGRES_DRIVER_NAME is pq with the '?' placeholders rebound, see Rebind.
+	POSTGRES_DRIVER_NAME = "userinfo-postgres"
+
+	POSTGRES_ERR_UNIQUE_VIOLATION = "23505"
+	POSTGRES_LOCK_RETRY_INTERVAL  = time.Millisecond * 100
+)
+
+func init() {
+	sql.Register(POSTGRES_DRIVER_NAME, rebindDriver{&pq.Driver{}})
+}
+
+// Postgres is the dialect of postgresql 14 and later.
+type Postgres struct{}
+
+func (Postgres) Name() string {
+	return DRIVER_POSTGRES
+}
+
+func (Postgres) DriverName() string {
+	return POSTGRES_DRIVER_NAME
+}
+
+func (Postgres) DSN(user string, password string, host string, port string, db string) string {
+	dsn := &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     net.JoinHostPort(host, port),
+		Path:     db,
+		RawQuery: "sslmode=disable",
+	}
+	return dsn.String()
+}
+
+func (Postgres) Now() string {
+	return "now()"
+}
+
+func (Postgres) NowMillis() string {
+	return "now()"
+}
+
+func (Postgres) AddSeconds(timestamp string) string {
+	return fmt.Sprintf("%v + ? * INTERVAL '1 second'", timestamp)
+}
+
+func (Postgres) AddMicroseconds(timestamp string) string {
+	return fmt.Sprintf("%v + ? * INTERVAL '1 microsecond'", timestamp)
+}
+
+func (Postgres) MicrosecondsBetween(from string, to string) string {
+	return fmt.Sprintf("CAST(EXTRACT(EPOCH FROM (%v) - (%v)) * -1000000 AS BIGINT)", from, to)
+}
+
+func (Postgres) UnixTimestamp(timestamp string) string {
+	return fmt.Sprintf("CAST(EXTRACT(EPOCH FROM %v) AS BIGINT)", timestamp)
+}
+
+func (Postgres) FromUnixTimestamp(seconds string) string {
+	return fmt.Sprintf("to_timestamp(%v)", seconds)
+}
+
+// JSONMergePatch concatenates the objects and strips the null values. The columns are objects of scalars, so it
+// is the same as RFC 7396.
+func (Postgres) JSONMergePatch(column string) string {
+	return fmt.Sprintf("jsonb_strip_nulls(COALESCE(%v,'{}'::jsonb) || CAST(? AS jsonb))", column)
+}
+
+func (Postgres) ForUpdate() string {
+	return " FOR UPDATE"
+}
+
+func (Postgres) ForUpdateSkipLocked() string {
+	return " FOR UPDATE SKIP LOCKED"
+}
+
+// LockRange is empty, postgres does not lock gaps and does not lock rows of aggregates.
+func (Postgres) LockRange(shared bool) string {
+	return ""
+}
+
+// LockKey takes a transaction level advisory lock on the hash of key.
+func (Postgres) LockKey(ctx context.Context, q Querier, key string) error {
+	_, err := q.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext(?))", key)
+	return err
+}
+
+// Lock tries a session level advisory lock on the hash of name until timeout.
+func (Postgres) Lock(ctx context.Context, q Querier, name string, timeout time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		var locked bool
+		err := q.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext(?))", name).Scan(&locked)
+		if err != nil || locked {
+			return locked, err
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(POSTGRES_LOCK_RETRY_INTERVAL):
+		}
+	}
+}
+
+func (Postgres) Unlock(ctx context.Context, q Querier, name string) error {
+	_, err := q.ExecContext(ctx, "SELECT pg_advisory_unlock(hashtext(?))", name)
+	return err
+}
+
+func (Postgres) OnConflictUpdate(key []string, columns ...string) string {
+	updates := make([]string, 0, len(columns))
+	for _, column := range columns {
+		updates = append(updates, fmt.Sprintf("%v = excluded.%v", column, column))
+	}
+	return fmt.Sprintf(" ON CONFLICT (%v) DO UPDATE SET %v", strings.Join(key, ", "), strings.Join(updates, ", "))
+}
+
+func (Postgres) InsertIgnore(insert string) string {
+	return insert + " ON CONFLICT DO NOTHING"
+}
+
+// InsertId returns the id of the inserted row, the table must have the column id.
+func (Postgres) InsertId(ctx context.Context, q Querier, insert string, args ...any) (uint64, error) {
+	var id uint64
+	err := q.QueryRowContext(ctx, insert+" RETURNING id", args...).Scan(&id)
+	return id, err
+}
+
+func (Postgres) IsDuplicateKey(err error, key UniqueKey) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != POSTGRES_ERR_UNIQUE_VIOLATION {
+		return false
+	}
+	return pqErr.Constraint == key.Name
+}
+
+// ReplicationLag is the age of the last replayed transaction, or 0 if the replica has replayed all it received.
+func (Postgres) ReplicationLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	var seconds sql.NullFloat64
+	err := db.QueryRowContext(ctx, "SELECT CASE WHEN NOT pg_is_in_recovery() THEN 0"+
+		" WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0"+
+		" ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END").Scan(&seconds)
+	if err != nil {
+		return 0, err
+	}
+	if !seconds.Valid {
+		return 0, ErrReplicationStopped
+	}
+	return time.Duration(seconds.Float64 * float64(time.Second)), nil
+}
+
+// rebindDriver wraps a driver taking '$n' placeholders, so that the queries written with '?' can be run as they are.
+type rebindDriver struct {
+	driver.Driver
+}
+
+func (d rebindDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &rebindConn{Conn: conn}, nil
+}
+
+// rebindConn rebinds the queries, and passes the optional interfaces of database/sql through to the wrapped conn.
+type rebindConn struct {
+	driver.Conn
+}
+
+func (c *rebindConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(Rebind(query))
+}
+
+func (c *rebindConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, Rebind(query))
+	}
+	return c.Conn.Prepare(Rebind(query))
+}
+
+func (c *rebindConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := c.Conn.(driver.QueryerContext); ok {
+		return queryer.QueryContext(ctx, Rebind(query), args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *rebindConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := c.Conn.(driver.ExecerContext); ok {
+		return execer.ExecContext(ctx, Rebind(query), args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *rebindConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //nolint:staticcheck // the fallback of database/sql as well.
+}
+
+func (c *rebindConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *rebindConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *rebindConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *rebindConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
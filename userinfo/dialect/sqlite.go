@@ -0,0 +1,122 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"strings"
+	"time"
+)
+
+// SQLITE_MILLIS_FORMAT is the format of timestamps in milliseconds, seconds are formatted as CURRENT_TIMESTAMP.
+const SQLITE_MILLIS_FORMAT = "%Y-%m-%d %H:%M:%f"
+
+// SQLite is the dialect of sqlite 3.35 and later, for running the service on a single host without mysql.
+// Timestamps are saved as UTC text, which sorts the same as the time.
+//
+// Transactions take the write lock when they begin, so the transactions of the same database run one at a time,
+// and the row locks are not needed.
+type SQLite struct{}
+
+func (SQLite) Name() string {
+	return DRIVER_SQLITE
+}
+
+func (SQLite) DriverName() string {
+	return DRIVER_SQLITE
+}
+
+// DSN opens the file db, user, password, host and port are not used.
+func (SQLite) DSN(user string, password string, host string, port string, db string) string {
+	return fmt.Sprintf("file:%v?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate", db)
+}
+
+func (SQLite) Now() string {
+	return "CURRENT_TIMESTAMP"
+}
+
+func (SQLite) NowMillis() string {
+	return fmt.Sprintf("strftime('%v', 'now')", SQLITE_MILLIS_FORMAT)
+}
+
+func (SQLite) AddSeconds(timestamp string) string {
+	return fmt.Sprintf("datetime(%v, ? || ' seconds')", timestamp)
+}
+
+func (SQLite) AddMicroseconds(timestamp string) string {
+	return fmt.Sprintf("strftime('%v', %v, (? / 1000000.0) || ' seconds')", SQLITE_MILLIS_FORMAT, timestamp)
+}
+
+// MicrosecondsBetween is rounded to milliseconds, which are the precision of the timestamps.
+func (SQLite) MicrosecondsBetween(from string, to string) string {
+	return fmt.Sprintf("CAST(ROUND((julianday(%v) - julianday(%v)) * -86400000) AS INTEGER) * 1000", from, to)
+}
+
+func (SQLite) UnixTimestamp(timestamp string) string {
+	return fmt.Sprintf("CAST(strftime('%%s', %v) AS INTEGER)", timestamp)
+}
+
+func (SQLite) FromUnixTimestamp(seconds string) string {
+	return fmt.Sprintf("datetime(%v, 'unixepoch')", seconds)
+}
+
+func (SQLite) JSONMergePatch(column string) string {
+	return fmt.Sprintf("json_patch(COALESCE(%v,'{}'),?)", column)
+}
+
+func (SQLite) ForUpdate() string {
+	return ""
+}
+
+func (SQLite) ForUpdateSkipLocked() string {
+	return ""
+}
+
+func (SQLite) LockRange(shared bool) string {
+	return ""
+}
+
+func (SQLite) LockKey(ctx context.Context, q Querier, key string) error {
+	return nil
+}
+
+// Lock always succeeds, a database file is migrated by the host which has it.
+func (SQLite) Lock(ctx context.Context, q Querier, name string, timeout time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (SQLite) Unlock(ctx context.Context, q Querier, name string) error {
+	return nil
+}
+
+func (SQLite) OnConflictUpdate(key []string, columns ...string) string {
+	return Postgres{}.OnConflictUpdate(key, columns...)
+}
+
+func (SQLite) InsertIgnore(insert string) string {
+	return insert + " ON CONFLICT DO NOTHING"
+}
+
+func (SQLite) InsertId(ctx context.Context, q Querier, insert string, args ...any) (uint64, error) {
+	return MySQL{}.InsertId(ctx, q, insert, args...)
+}
+
+func (SQLite) IsDuplicateKey(err error, key UniqueKey) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.ExtendedCode != sqlite3.ErrConstraintUnique {
+		return false
+	}
+	// the message is like "UNIQUE constraint failed: profile_tab.user_id".
+	columns := make([]string, 0, len(key.Columns))
+	for _, column := range key.Columns {
+		columns = append(columns, key.Table+"."+column)
+	}
+	return strings.HasSuffix(sqliteErr.Error(), ": "+strings.Join(columns, ", "))
+}
+
+// ReplicationLag is always 0, replicas of sqlite are the same file as the master.
+func (SQLite) ReplicationLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	return 0, nil
+}
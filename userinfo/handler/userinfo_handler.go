@@ -6,17 +6,20 @@ import (
 	"protos/userinfo"
 	"user-server/biz/account"
 	"user-server/biz/profile"
+	"user-server/biz/relation"
 )
 
 type UserinfoHandlerImpl struct {
-	accountBiz *account.AccountBiz
-	profileBiz *profile.ProfileBiz
+	accountBiz  *account.AccountBiz
+	profileBiz  *profile.ProfileBiz
+	relationBiz *relation.RelationBiz
 }
 
-func NewUserinfoHandlerImpl(profileBiz *profile.ProfileBiz, accountBiz *account.AccountBiz) *UserinfoHandlerImpl {
+func NewUserinfoHandlerImpl(profileBiz *profile.ProfileBiz, accountBiz *account.AccountBiz, relationBiz *relation.RelationBiz) *UserinfoHandlerImpl {
 	return &UserinfoHandlerImpl{
-		accountBiz: accountBiz,
-		profileBiz: profileBiz,
+		accountBiz:  accountBiz,
+		profileBiz:  profileBiz,
+		relationBiz: relationBiz,
 	}
 }
 
@@ -28,6 +31,10 @@ func (h *UserinfoHandlerImpl) DeleteProfile(ctx context.Context, in *userinfo.De
 	return h.profileBiz.DeleteProfile(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
 }
 
+func (h *UserinfoHandlerImpl) RestoreProfile(ctx context.Context, in *userinfo.RestoreProfileRequest, out *userinfo.RestoreProfileResponse) error {
+	return h.profileBiz.RestoreProfile(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
 func (h *UserinfoHandlerImpl) CreateProfile(ctx context.Context, in *userinfo.CreateProfileRequest, out *userinfo.CreateProfileResponse) error {
 	return h.profileBiz.CreateProfile(getTraceContext(ctx, in.GetRequestId(), in.GetProfile().GetUserId()), in, out)
 }
@@ -36,6 +43,22 @@ func (h *UserinfoHandlerImpl) UpdateProfile(ctx context.Context, in *userinfo.Up
 	return h.profileBiz.UpdateProfile(getTraceContext(ctx, in.GetRequestId(), in.GetProfile().GetUserId()), in, out)
 }
 
+func (h *UserinfoHandlerImpl) GetPublicProfile(ctx context.Context, in *userinfo.GetPublicProfileRequest, out *userinfo.GetPublicProfileResponse) error {
+	return h.profileBiz.GetPublicProfile(getTraceContext(ctx, in.GetRequestId(), in.GetViewerId()), in, out)
+}
+
+func (h *UserinfoHandlerImpl) ChangeUsername(ctx context.Context, in *userinfo.ChangeUsernameRequest, out *userinfo.ChangeUsernameResponse) error {
+	return h.profileBiz.ChangeUsername(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
+func (h *UserinfoHandlerImpl) GetProfileHistory(ctx context.Context, in *userinfo.GetProfileHistoryRequest, out *userinfo.GetProfileHistoryResponse) error {
+	return h.profileBiz.GetProfileHistory(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
+func (h *UserinfoHandlerImpl) GetProfileAt(ctx context.Context, in *userinfo.GetProfileAtRequest, out *userinfo.GetProfileAtResponse) error {
+	return h.profileBiz.GetProfileAt(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
 func (h *UserinfoHandlerImpl) Register(ctx context.Context, in *userinfo.RegisterRequest, out *userinfo.RegisterResponse) error {
 	return h.accountBiz.Register(getTraceContext(ctx, in.GetRequestId(), 0), in, out)
 }
@@ -52,6 +75,54 @@ func (h *UserinfoHandlerImpl) Authenticate(ctx context.Context, in *userinfo.Aut
 	return h.accountBiz.Authenticate(getTraceContext(ctx, in.GetRequestId(), 0), in, out)
 }
 
+func (h *UserinfoHandlerImpl) Follow(ctx context.Context, in *userinfo.RelationRequest, out *userinfo.RelationResponse) error {
+	return h.relationBiz.Follow(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
+func (h *UserinfoHandlerImpl) Unfollow(ctx context.Context, in *userinfo.RelationRequest, out *userinfo.RelationResponse) error {
+	return h.relationBiz.Unfollow(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
+func (h *UserinfoHandlerImpl) RequestFriend(ctx context.Context, in *userinfo.RelationRequest, out *userinfo.RelationResponse) error {
+	return h.relationBiz.RequestFriend(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
+func (h *UserinfoHandlerImpl) AcceptFriend(ctx context.Context, in *userinfo.RelationRequest, out *userinfo.RelationResponse) error {
+	return h.relationBiz.AcceptFriend(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
+func (h *UserinfoHandlerImpl) RejectFriend(ctx context.Context, in *userinfo.RelationRequest, out *userinfo.RelationResponse) error {
+	return h.relationBiz.RejectFriend(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
+func (h *UserinfoHandlerImpl) Block(ctx context.Context, in *userinfo.RelationRequest, out *userinfo.RelationResponse) error {
+	return h.relationBiz.Block(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
+func (h *UserinfoHandlerImpl) Unblock(ctx context.Context, in *userinfo.RelationRequest, out *userinfo.RelationResponse) error {
+	return h.relationBiz.Unblock(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
+func (h *UserinfoHandlerImpl) ListFollowers(ctx context.Context, in *userinfo.ListRelationsRequest, out *userinfo.ListRelationsResponse) error {
+	return h.relationBiz.ListFollowers(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
+func (h *UserinfoHandlerImpl) ListFollowing(ctx context.Context, in *userinfo.ListRelationsRequest, out *userinfo.ListRelationsResponse) error {
+	return h.relationBiz.ListFollowing(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
+func (h *UserinfoHandlerImpl) ListFriends(ctx context.Context, in *userinfo.ListRelationsRequest, out *userinfo.ListRelationsResponse) error {
+	return h.relationBiz.ListFriends(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
+func (h *UserinfoHandlerImpl) ListFriendRequests(ctx context.Context, in *userinfo.ListRelationsRequest, out *userinfo.ListRelationsResponse) error {
+	return h.relationBiz.ListFriendRequests(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
+func (h *UserinfoHandlerImpl) GetRelationCounts(ctx context.Context, in *userinfo.GetRelationCountsRequest, out *userinfo.GetRelationCountsResponse) error {
+	return h.relationBiz.GetRelationCounts(getTraceContext(ctx, in.GetRequestId(), in.GetUserId()), in, out)
+}
+
 func getTraceContext(ctx context.Context, requestId string, userId uint64) context.Context {
 	return context.WithValue(ctx, logger.TraceDataKey{}, logger.TraceData{
 		RequestId: requestId,
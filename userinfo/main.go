@@ -1,6 +1,30 @@
 package main
 
+import "os"
+
 func main() {
+	// userinfo warmcache [flags] writes profiles to the cache and exits, see warmCache.
+	if len(os.Args) > 1 && os.Args[1] == "warmcache" {
+		if err := warmCache(os.Args[2:]); err != nil {
+			panic(err)
+		}
+		return
+	}
+	// userinfo migrate [flags] up|down|status migrates the schema and exits, see migrate.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(os.Args[2:]); err != nil {
+			panic(err)
+		}
+		return
+	}
+	// userinfo reshard [flags] init|move|status moves users between the shards and exits, see reshard.
+	if len(os.Args) > 1 && os.Args[1] == "reshard" {
+		if err := reshard(os.Args[2:]); err != nil {
+			panic(err)
+		}
+		return
+	}
+
 	server := &Server{}
 
 	if err := server.Init(); err != nil {
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"user-server/conf"
+	"user-server/dialect"
+	"user-server/migration"
+)
+
+const MIGRATE_DOWN_STEPS_DEFAULT = 1
+
+// migrate applies or reverts the embedded schema migrations on mysql-master, or on the master of a shard given by
+// -shard, or prints their status. A database created before migrations were introduced is baselined by -baseline,
+// the migrations up to the version are recorded as applied without running them.
+//
+//	userinfo migrate -config=conf/userinfo.yaml up
+//	userinfo migrate -config=conf/userinfo.yaml -baseline=1 up
+//	userinfo migrate -config=conf/userinfo.yaml -shard=shard0 up
+//	userinfo migrate -config=conf/userinfo.yaml -steps=1 down
+//	userinfo migrate -config=conf/userinfo.yaml status
+func migrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	confPath := flags.String("config", "conf/userinfo.yaml", "define config file")
+	steps := flags.Int("steps", MIGRATE_DOWN_STEPS_DEFAULT, "migrations to revert by down")
+	shardName := flags.String("shard", "", "migrate the master of the shard instead of mysql-master")
+	baseline := flags.Uint64("baseline", 0, "record the migrations up to the version as applied before up")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	command := flags.Arg(0)
+	if command != "up" && command != "down" && command != "status" {
+		return fmt.Errorf("usage: userinfo migrate [-config=path] [-steps=n] [-shard=name] [-baseline=version] up|down|status")
+	}
+
+	config, err := conf.LoadConfig(*confPath)
+	if err != nil {
+		log.Println("load config file error, err: ", err)
+		return err
+	}
+	masterConf := config.MysqlMaster
+	if *shardName != "" {
+		shardConf, err := findShardConf(config, *shardName)
+		if err != nil {
+			return err
+		}
+		masterConf = shardConf.MysqlMaster
+	}
+	sqlMaster, err := openMysql(masterConf)
+	if err != nil {
+		log.Println("init sqlDB master failed, err: ", err.Error())
+		return err
+	}
+	defer sqlMaster.Close()
+	sqlDialect, err := dialect.New(masterConf.Driver)
+	if err != nil {
+		return err
+	}
+	migrator, err := migration.NewMigrator(sqlMaster, sqlDialect)
+	if err != nil {
+		log.Println("load migrations failed, err: ", err.Error())
+		return err
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		if *baseline > 0 {
+			migrations, err := migrator.Baseline(ctx, *baseline)
+			logMigrations("baselined", migrations)
+			if err != nil {
+				return err
+			}
+		}
+		migrations, err := migrator.Up(ctx)
+		logMigrations("applied", migrations)
+		return err
+	case "down":
+		migrations, err := migrator.Down(ctx, *steps)
+		logMigrations("reverted", migrations)
+		return err
+	default:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			switch {
+			case status.Dirty:
+				log.Printf("%04d_%v dirty", status.Version, status.Name)
+			case status.AppliedAt.IsZero():
+				log.Printf("%04d_%v pending", status.Version, status.Name)
+			default:
+				log.Printf("%04d_%v applied at %v", status.Version, status.Name, status.AppliedAt.Format("2006-01-02 15:04:05"))
+			}
+		}
+		return nil
+	}
+}
+
+func logMigrations(action string, migrations []*migration.Migration) {
+	for _, m := range migrations {
+		log.Printf("%04d_%v %v", m.Version, m.Name, action)
+	}
+	log.Printf("%v migrations %v", len(migrations), action)
+}
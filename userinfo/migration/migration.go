@@ -0,0 +1,405 @@
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"user-server/dialect"
+)
+
+const (
+	TAB_NAME_SCHEMA_MIGRATIONS = "schema_migrations"
+	// MIGRATE_LOCK_NAME is the named lock held while migrating, so that instances starting together
+	// do not apply the same migration twice.
+	MIGRATE_LOCK_NAME    = "userinfo:migrate"
+	MIGRATE_LOCK_TIMEOUT = time.Minute
+)
+
+var (
+	// ErrChecksumMismatch is returned when an applied migration is changed afterwards.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrDirty is returned when a migration failed halfway, the schema must be fixed by hand before migrating again.
+	ErrDirty = errors.New("dirty migration")
+	// ErrUnknownVersion is returned when the database has a migration which is not embedded, i.e. it is migrated
+	// by a newer build.
+	ErrUnknownVersion = errors.New("unknown migration version")
+	ErrLockTimeout    = errors.New("timeout to lock migrations")
+	// ErrNotBaselined is returned when migrating a database which has tables but no migration applied, i.e. it was
+	// created before migrations were introduced. The version its schema matches must be given by Baseline.
+	ErrNotBaselined = errors.New("database has tables but no migration applied, baseline it first")
+	// ErrBaselined is returned when baselining a database which has migrations applied.
+	ErrBaselined = errors.New("database has migrations applied")
+)
+
+// the migrations of each dialect are in sql/<driver>, e.g. sql/mysql.
+//
+//go:embed sql
+var files embed.FS
+
+// schemaMigrationsTables creates TAB_NAME_SCHEMA_MIGRATIONS in each dialect.
+var schemaMigrationsTables = map[string]string{
+	dialect.DRIVER_MYSQL: "CREATE TABLE IF NOT EXISTS `" + TAB_NAME_SCHEMA_MIGRATIONS + "` (" +
+		"`version` bigint unsigned NOT NULL," +
+		" `name` varchar(255) NOT NULL DEFAULT ''," +
+		" `checksum` char(64) NOT NULL DEFAULT '' COMMENT 'sha256 of the up migration'," +
+		" `dirty` tinyint(1) NOT NULL DEFAULT 0 COMMENT '1 if the migration failed halfway'," +
+		" `applied_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP," +
+		" PRIMARY KEY (`version`)" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci",
+	dialect.DRIVER_POSTGRES: "CREATE TABLE IF NOT EXISTS " + TAB_NAME_SCHEMA_MIGRATIONS + " (" +
+		"version bigint NOT NULL PRIMARY KEY," +
+		" name varchar(255) NOT NULL DEFAULT ''," +
+		" checksum char(64) NOT NULL DEFAULT ''," +
+		" dirty smallint NOT NULL DEFAULT 0," +
+		" applied_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP)",
+	dialect.DRIVER_SQLITE: "CREATE TABLE IF NOT EXISTS " + TAB_NAME_SCHEMA_MIGRATIONS + " (" +
+		"version integer NOT NULL PRIMARY KEY," +
+		" name text NOT NULL DEFAULT ''," +
+		" checksum text NOT NULL DEFAULT ''," +
+		" dirty integer NOT NULL DEFAULT 0," +
+		" applied_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP)",
+}
+
+// tablesQueries count the tables of the database other than TAB_NAME_SCHEMA_MIGRATIONS in each dialect.
+var tablesQueries = map[string]string{
+	dialect.DRIVER_MYSQL: "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE()" +
+		" AND table_name <> '" + TAB_NAME_SCHEMA_MIGRATIONS + "'",
+	dialect.DRIVER_POSTGRES: "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema()" +
+		" AND table_name <> '" + TAB_NAME_SCHEMA_MIGRATIONS + "'",
+	dialect.DRIVER_SQLITE: "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'" +
+		" AND name NOT IN ('" + TAB_NAME_SCHEMA_MIGRATIONS + "', 'sqlite_sequence')",
+}
+
+// file names are <version>_<name>.<up|down>.sql, e.g. 0001_init.up.sql.
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned change of the schema. Versions are applied in ascending order, and reverted by down
+// in descending order. The checksum of up is recorded when it is applied, an applied migration must not be changed.
+type Migration struct {
+	Version  uint64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status is a migration with the time it is applied, AppliedAt is zero if it is pending.
+type Status struct {
+	*Migration
+	AppliedAt time.Time
+	Dirty     bool
+}
+
+type applied struct {
+	checksum  string
+	dirty     bool
+	appliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    dialect.Dialect
+	migrations []*Migration
+}
+
+// NewMigrator creates a migrator of the embedded migrations of the dialect against db, which must be mysql-master.
+func NewMigrator(db *sql.DB, sqlDialect dialect.Dialect) (*Migrator, error) {
+	migrations, err := load(files, path.Join("sql", sqlDialect.Name()))
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: sqlDialect, migrations: migrations}, nil
+}
+
+// Up applies all pending migrations in order, and returns the applied ones. ErrNotBaselined is returned if the
+// database has tables but no migration applied.
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	var done []*Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		appliedVersions, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(appliedVersions) == 0 {
+			var tables int
+			if err = conn.QueryRowContext(ctx, tablesQueries[m.dialect.Name()]).Scan(&tables); err != nil {
+				return err
+			}
+			if tables > 0 {
+				return ErrNotBaselined
+			}
+		}
+		for _, migration := range m.migrations {
+			if _, ok := appliedVersions[migration.Version]; ok {
+				continue
+			}
+			if err = m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Baseline records the migrations up to version as applied without running them, for a database whose schema was
+// created by hand at that version. It returns the recorded ones, ErrBaselined is returned if any migration is applied.
+func (m *Migrator) Baseline(ctx context.Context, version uint64) ([]*Migration, error) {
+	var done []*Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		appliedVersions, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(appliedVersions) > 0 {
+			return ErrBaselined
+		}
+		if !slices.ContainsFunc(m.migrations, func(migration *Migration) bool { return migration.Version == version }) {
+			return fmt.Errorf("%w: %v", ErrUnknownVersion, version)
+		}
+		sqlString := fmt.Sprintf("INSERT INTO %v (version, name, checksum, dirty) VALUES (?, ?, ?, 0)", TAB_NAME_SCHEMA_MIGRATIONS)
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			if _, err = conn.ExecContext(ctx, sqlString, migration.Version, migration.Name, migration.Checksum); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts at most steps applied migrations, the latest first, and returns the reverted ones.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	var done []*Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		appliedVersions, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := appliedVersions[migration.Version]; !ok {
+				continue
+			}
+			if err = m.revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status returns all migrations in order, with whether they are applied. The checksums are not verified.
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	appliedVersions, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]*Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := &Status{Migration: migration}
+		if a, ok := appliedVersions[migration.Version]; ok {
+			status.AppliedAt = a.appliedAt
+			status.Dirty = a.dirty
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// verify checks that every applied migration is embedded and unchanged, and that none of them is dirty.
+func (m *Migrator) verify(ctx context.Context, conn *sql.Conn) (map[uint64]*applied, error) {
+	appliedVersions, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	embedded := make(map[uint64]*Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		embedded[migration.Version] = migration
+	}
+	for version, a := range appliedVersions {
+		migration, ok := embedded[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: %v", ErrUnknownVersion, version)
+		}
+		if a.dirty {
+			return nil, fmt.Errorf("%w: %v_%v", ErrDirty, version, migration.Name)
+		}
+		if a.checksum != migration.Checksum {
+			return nil, fmt.Errorf("%w: %v_%v", ErrChecksumMismatch, version, migration.Name)
+		}
+	}
+	return appliedVersions, nil
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[uint64]*applied, error) {
+	sqlString := schemaMigrationsTables[m.dialect.Name()]
+	if _, err := conn.ExecContext(ctx, sqlString); err != nil {
+		return nil, err
+	}
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT version, checksum, dirty, %v FROM %v",
+		m.dialect.UnixTimestamp("applied_at"), TAB_NAME_SCHEMA_MIGRATIONS))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	appliedVersions := make(map[uint64]*applied)
+	for rows.Next() {
+		var version uint64
+		var appliedAt int64
+		a := &applied{}
+		if err = rows.Scan(&version, &a.checksum, &a.dirty, &appliedAt); err != nil {
+			return nil, err
+		}
+		a.appliedAt = time.Unix(appliedAt, 0)
+		appliedVersions[version] = a
+	}
+	return appliedVersions, rows.Err()
+}
+
+// apply runs the up migration. DDL of mysql is committed implicitly, so the migration is marked dirty until all of
+// its statements succeed. Statements run one by one in the other dialects as well.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration *Migration) error {
+	sqlString := fmt.Sprintf("INSERT INTO %v (version, name, checksum, dirty) VALUES (?, ?, ?, 1)", TAB_NAME_SCHEMA_MIGRATIONS)
+	if _, err := conn.ExecContext(ctx, sqlString, migration.Version, migration.Name, migration.Checksum); err != nil {
+		return err
+	}
+	for _, statement := range splitStatements(migration.Up) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("apply %v_%v: %w", migration.Version, migration.Name, err)
+		}
+	}
+	_, err := conn.ExecContext(ctx, fmt.Sprintf("UPDATE %v SET dirty = 0 WHERE version = ?", TAB_NAME_SCHEMA_MIGRATIONS),
+		migration.Version)
+	return err
+}
+
+// revert runs the down migration, the migration is marked dirty until all of its statements succeed.
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, migration *Migration) error {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf("UPDATE %v SET dirty = 1 WHERE version = ?", TAB_NAME_SCHEMA_MIGRATIONS),
+		migration.Version)
+	if err != nil {
+		return err
+	}
+	for _, statement := range splitStatements(migration.Down) {
+		if _, err = conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("revert %v_%v: %w", migration.Version, migration.Name, err)
+		}
+	}
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %v WHERE version = ?", TAB_NAME_SCHEMA_MIGRATIONS), migration.Version)
+	return err
+}
+
+// withLock runs fn on a connection holding the named lock of migrations.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	locked, err := m.dialect.Lock(ctx, conn, MIGRATE_LOCK_NAME, MIGRATE_LOCK_TIMEOUT)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return ErrLockTimeout
+	}
+	defer m.dialect.Unlock(context.WithoutCancel(ctx), conn, MIGRATE_LOCK_NAME)
+	return fn(conn)
+}
+
+// load reads the migrations in dir of fsys, sorted by version. Each version must have both up and down.
+func load(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[uint64]*Migration)
+	for _, entry := range entries {
+		matches := fileNamePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration file name: %v", entry.Name())
+		}
+		version, err := strconv.ParseUint(matches[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		} else if migration.Name != matches[2] {
+			return nil, fmt.Errorf("migration %v has two names: %v and %v", version, migration.Name, matches[2])
+		}
+		if matches[3] == "up" {
+			migration.Up = string(content)
+			sum := sha256.Sum256(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %v_%v must have both up and down", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// splitStatements splits a migration into statements ending with ';' at the end of a line, since the mysql
+// driver runs one statement at a time. Statements must not have such a ';' inside, e.g. in a string or the body
+// of a function, which must be written in one line.
+func splitStatements(migration string) []string {
+	statements := make([]string, 0)
+	var statement strings.Builder
+	for _, line := range strings.Split(migration, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if statement.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			continue
+		}
+		statement.WriteString(line)
+		statement.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(statement.String()), ";"))
+			statement.Reset()
+		}
+	}
+	if s := strings.TrimSpace(statement.String()); s != "" {
+		statements = append(statements, s)
+	}
+	return statements
+}
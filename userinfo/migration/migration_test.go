@@ -0,0 +1,169 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"user-server/dialect"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	var versions []uint64
+	for _, driver := range []string{dialect.DRIVER_MYSQL, dialect.DRIVER_POSTGRES, dialect.DRIVER_SQLITE} {
+		migrations, err := load(files, path.Join("sql", driver))
+		if err != nil {
+			t.Fatalf("load %v failed, err: %v", driver, err)
+		}
+		if len(migrations) == 0 || migrations[0].Version != 1 {
+			t.Fatalf("expect migrations of %v from version 1, got %v", driver, migrations)
+		}
+		for i := 1; i < len(migrations); i++ {
+			if migrations[i].Version <= migrations[i-1].Version {
+				t.Errorf("expect ascending versions, got %v after %v", migrations[i].Version, migrations[i-1].Version)
+			}
+		}
+		if _, ok := schemaMigrationsTables[driver]; !ok {
+			t.Errorf("expect %v table of %v", TAB_NAME_SCHEMA_MIGRATIONS, driver)
+		}
+		// all dialects have the same versions.
+		if versions == nil {
+			for _, migration := range migrations {
+				versions = append(versions, migration.Version)
+			}
+		} else if len(migrations) != len(versions) || migrations[len(migrations)-1].Version != versions[len(versions)-1] {
+			t.Errorf("expect versions %v of %v, got %v", versions, driver, migrations)
+		}
+	}
+}
+
+func TestMigrateSQLite(t *testing.T) {
+	sqlDialect := dialect.SQLite{}
+	db, err := sql.Open(sqlDialect.DriverName(), sqlDialect.DSN("", "", "", "", filepath.Join(t.TempDir(), "userinfo.db")))
+	if err != nil {
+		t.Fatalf("open sqlite failed, err: %v", err)
+	}
+	defer db.Close()
+	migrator, err := NewMigrator(db, sqlDialect)
+	if err != nil {
+		t.Fatalf("load migrations failed, err: %v", err)
+	}
+	ctx := context.Background()
+	applied, err := migrator.Up(ctx)
+	if err != nil || len(applied) != len(migrator.migrations) {
+		t.Fatalf("expect all migrations applied, got %v, err: %v", applied, err)
+	}
+	if applied, err = migrator.Up(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("expect no migration applied again, got %v, err: %v", applied, err)
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("status failed, err: %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt.IsZero() || status.Dirty {
+			t.Errorf("expect %v applied, got %+v", status.Version, status)
+		}
+	}
+	reverted, err := migrator.Down(ctx, len(migrator.migrations))
+	if err != nil || len(reverted) != len(migrator.migrations) {
+		t.Fatalf("expect all migrations reverted, got %v, err: %v", reverted, err)
+	}
+	var tables int
+	err = db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name LIKE '%_tab'").Scan(&tables)
+	if err != nil || tables != 0 {
+		t.Errorf("expect no tables left, got %v, err: %v", tables, err)
+	}
+}
+
+func TestBaselineSQLite(t *testing.T) {
+	sqlDialect := dialect.SQLite{}
+	db, err := sql.Open(sqlDialect.DriverName(), sqlDialect.DSN("", "", "", "", filepath.Join(t.TempDir(), "userinfo.db")))
+	if err != nil {
+		t.Fatalf("open sqlite failed, err: %v", err)
+	}
+	defer db.Close()
+	migrator, err := NewMigrator(db, sqlDialect)
+	if err != nil {
+		t.Fatalf("load migrations failed, err: %v", err)
+	}
+	// the tables are created by hand as migration 1 does.
+	for _, statement := range splitStatements(migrator.migrations[0].Up) {
+		if _, err = db.Exec(statement); err != nil {
+			t.Fatalf("create tables failed, err: %v", err)
+		}
+	}
+	ctx := context.Background()
+	if _, err = migrator.Up(ctx); !errors.Is(err, ErrNotBaselined) {
+		t.Fatalf("expect ErrNotBaselined, got %v", err)
+	}
+	if _, err = migrator.Baseline(ctx, 100); !errors.Is(err, ErrUnknownVersion) {
+		t.Fatalf("expect ErrUnknownVersion, got %v", err)
+	}
+	baselined, err := migrator.Baseline(ctx, 1)
+	if err != nil || len(baselined) != 1 {
+		t.Fatalf("expect migration 1 baselined, got %v, err: %v", baselined, err)
+	}
+	if _, err = migrator.Baseline(ctx, 1); !errors.Is(err, ErrBaselined) {
+		t.Errorf("expect ErrBaselined, got %v", err)
+	}
+	// user 1 has duplicated profiles, which are removed before one profile per user is enforced.
+	_, err = db.Exec("INSERT INTO profile_tab (user_id, email, update_time) VALUES" +
+		" (1, 'a@example.com', '2024-01-01 00:00:00'), (1, 'b@example.com', '2024-01-02 00:00:00')," +
+		" (2, 'c@example.com', '2024-01-01 00:00:00')")
+	if err != nil {
+		t.Fatalf("insert profiles failed, err: %v", err)
+	}
+	applied, err := migrator.Up(ctx)
+	if err != nil || len(applied) != len(migrator.migrations)-1 {
+		t.Fatalf("expect the migrations after 1 applied, got %v, err: %v", applied, err)
+	}
+	var email string
+	if err = db.QueryRow("SELECT email FROM profile_tab WHERE user_id = 1").Scan(&email); err != nil || email != "b@example.com" {
+		t.Errorf("expect the latest profile kept, got %v, err: %v", email, err)
+	}
+	if err = db.QueryRow("SELECT email FROM profile_tab_duplicate_backup").Scan(&email); err != nil || email != "a@example.com" {
+		t.Errorf("expect the duplicated profile backed up, got %v, err: %v", email, err)
+	}
+	// the duplicated profiles are restored when reverted to migration 3.
+	if _, err = migrator.Down(ctx, len(migrator.migrations)-3); err != nil {
+		t.Fatalf("down failed, err: %v", err)
+	}
+	var profiles int
+	if err = db.QueryRow("SELECT COUNT(*) FROM profile_tab").Scan(&profiles); err != nil || profiles != 3 {
+		t.Errorf("expect 3 profiles restored, got %v, err: %v", profiles, err)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	invalids := []fstest.MapFS{
+		{"sql/0001_init.up.sql": {Data: []byte("CREATE TABLE t (id int);")}},
+		{"sql/init.up.sql": {Data: []byte("CREATE TABLE t (id int);")}},
+		{
+			"sql/0001_init.up.sql":    {Data: []byte("CREATE TABLE t (id int);")},
+			"sql/0001_other.down.sql": {Data: []byte("DROP TABLE t;")},
+		},
+	}
+	for _, fsys := range invalids {
+		if _, err := load(fsys, "sql"); err == nil {
+			t.Errorf("expect error, files: %v", fsys)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	migration := "-- comment\n\nCREATE TABLE t\n(\n    id int\n);\n\nALTER TABLE t\n    ADD COLUMN c int;\nDROP TABLE u"
+	statements := splitStatements(migration)
+	expected := []string{"CREATE TABLE t\n(\n    id int\n)", "ALTER TABLE t\n    ADD COLUMN c int", "DROP TABLE u"}
+	if len(statements) != len(expected) {
+		t.Fatalf("expect %v statements, got %q", len(expected), statements)
+	}
+	for i := range expected {
+		if statements[i] != expected[i] {
+			t.Errorf("expect %q, got %q", expected[i], statements[i])
+		}
+	}
+}
@@ -1,4 +1,5 @@
-USE userinfo;
+-- Tables of userinfo as script/init-db.sql created them before migrations were introduced. A database created by
+-- that script is baselined at this version by "userinfo migrate -baseline=1 up", which applies the later ones.
 
 CREATE TABLE `profile_tab`
 (
@@ -26,4 +27,4 @@ CREATE TABLE `user_tab`
     `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     PRIMARY KEY   (`id`),
     UNIQUE KEY    `email` (`email`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
\ No newline at end of file
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
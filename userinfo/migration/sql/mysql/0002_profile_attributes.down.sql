@@ -0,0 +1,4 @@
+ALTER TABLE `profile_tab`
+    DROP INDEX `idx_username`,
+    DROP COLUMN `privacy`,
+    DROP COLUMN `attributes`;
@@ -0,0 +1,6 @@
+-- Custom attributes of profiles and the visibility of their fields.
+
+ALTER TABLE `profile_tab`
+    ADD COLUMN `attributes` JSON COMMENT 'custom attributes defined in profile-attributes config' AFTER `avatar_url`,
+    ADD COLUMN `privacy`    JSON COMMENT 'visibility of fields, public, logged_in or private' AFTER `attributes`,
+    ADD KEY `idx_username` (`username`);
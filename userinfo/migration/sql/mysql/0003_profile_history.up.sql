@@ -0,0 +1,15 @@
+CREATE TABLE `profile_history_tab`
+(
+    `id`          bigint unsigned NOT NULL AUTO_INCREMENT,
+    `user_id`     bigint unsigned NOT NULL,
+    `version`     bigint unsigned NOT NULL COMMENT 'increases by 1 for each change of a user',
+    `operation`   varchar(16) NOT NULL DEFAULT '' COMMENT 'create, update or delete',
+    `changes`     JSON COMMENT 'changed fields with old and new values',
+    `snapshot`    JSON COMMENT 'profile after the change, null for delete',
+    `actor_id`    bigint unsigned NOT NULL DEFAULT 0,
+    `request_id`  varchar(64) NOT NULL DEFAULT '',
+    `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY   (`id`),
+    UNIQUE KEY    `uk_user_id_version` (`user_id`, `version`),
+    KEY           `idx_user_id_create_time` (`user_id`, `create_time`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,9 @@
+-- The duplicated profiles are restored from the backup.
+
+ALTER TABLE `profile_tab`
+    DROP INDEX `uk_user_id`,
+    ADD KEY `idx_user_id` (`user_id`);
+
+INSERT INTO `profile_tab` SELECT * FROM `profile_tab_duplicate_backup`;
+
+DROP TABLE IF EXISTS `profile_tab_duplicate_backup`;
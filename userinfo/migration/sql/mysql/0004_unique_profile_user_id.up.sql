@@ -0,0 +1,17 @@
+-- Enforce one profile per user. Duplicated profiles of a user are backed up and removed, only the latest updated
+-- one is kept.
+
+CREATE TABLE `profile_tab_duplicate_backup` LIKE `profile_tab`;
+
+INSERT INTO `profile_tab_duplicate_backup`
+SELECT p.* FROM `profile_tab` p
+JOIN `profile_tab` q ON p.user_id = q.user_id
+    AND (p.update_time < q.update_time OR (p.update_time = q.update_time AND p.id < q.id))
+GROUP BY p.id;
+
+DELETE p FROM `profile_tab` p
+JOIN `profile_tab_duplicate_backup` b ON p.id = b.id;
+
+ALTER TABLE `profile_tab`
+    DROP INDEX `idx_user_id`,
+    ADD UNIQUE KEY `uk_user_id` (`user_id`);
@@ -0,0 +1,5 @@
+-- Soft delete profiles, so that deleted profiles can be restored within the restore window.
+
+ALTER TABLE `profile_tab`
+    ADD COLUMN `deleted_at` timestamp NULL DEFAULT NULL COMMENT 'soft deleted profile can be restored within the restore window'
+    AFTER `update_time`;
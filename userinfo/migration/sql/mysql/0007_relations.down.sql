@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `friend_request_tab`;
+DROP TABLE IF EXISTS `relation_tab`;
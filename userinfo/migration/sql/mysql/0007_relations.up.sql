@@ -0,0 +1,25 @@
+CREATE TABLE `relation_tab`
+(
+    `id`          bigint unsigned NOT NULL AUTO_INCREMENT,
+    `user_id`     bigint unsigned NOT NULL,
+    `target_id`   bigint unsigned NOT NULL,
+    `type`        varchar(16) NOT NULL COMMENT 'user_id follows, befriends or blocks target_id',
+    `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY   (`id`),
+    UNIQUE KEY    `uk_user_id_target_id_type` (`user_id`, `target_id`, `type`),
+    KEY           `idx_user_id_type` (`user_id`, `type`),
+    KEY           `idx_target_id_type` (`target_id`, `type`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE `friend_request_tab`
+(
+    `id`           bigint unsigned NOT NULL AUTO_INCREMENT,
+    `from_user_id` bigint unsigned NOT NULL,
+    `to_user_id`   bigint unsigned NOT NULL,
+    `status`       varchar(16) NOT NULL COMMENT 'pending, accepted or rejected',
+    `create_time`  timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `update_time`  timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY    (`id`),
+    UNIQUE KEY     `uk_from_user_id_to_user_id` (`from_user_id`, `to_user_id`),
+    KEY            `idx_to_user_id_status` (`to_user_id`, `status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
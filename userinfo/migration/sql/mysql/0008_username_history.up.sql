@@ -0,0 +1,11 @@
+CREATE TABLE `username_history_tab`
+(
+    `id`             bigint unsigned NOT NULL AUTO_INCREMENT,
+    `user_id`        bigint unsigned NOT NULL,
+    `username`       varchar(255) NOT NULL COMMENT 'previous username of the user',
+    `reserved_until` timestamp NOT NULL COMMENT 'the username can not be taken by others and redirects to the user until then',
+    `create_time`    timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'when the username was changed',
+    PRIMARY KEY      (`id`),
+    KEY              `idx_username_reserved_until` (`username`, `reserved_until`),
+    KEY              `idx_user_id_create_time` (`user_id`, `create_time`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
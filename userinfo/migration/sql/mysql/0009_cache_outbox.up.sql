@@ -0,0 +1,11 @@
+CREATE TABLE `cache_outbox_tab`
+(
+    `id`              bigint unsigned NOT NULL AUTO_INCREMENT,
+    `cache_key`       varchar(255) NOT NULL COMMENT 'key to delete from the cache',
+    `stage`           tinyint unsigned NOT NULL DEFAULT 0 COMMENT '0: to delete, 1: to delete again after the replication lag',
+    `attempts`        int unsigned NOT NULL DEFAULT 0 COMMENT 'failed attempts of the stage',
+    `next_attempt_at` timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    `create_time`     timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) COMMENT 'when the change was committed',
+    PRIMARY KEY       (`id`),
+    KEY               `idx_next_attempt_at` (`next_attempt_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `user_email_tab`;
+DROP TABLE IF EXISTS `shard_range_tab`;
@@ -0,0 +1,28 @@
+-- Tables of the global database when user_tab and profile_tab are sharded by user id, see sharding in the config.
+-- They are created in the shards as well but left empty.
+
+CREATE TABLE `shard_range_tab`
+(
+    `id`          bigint unsigned NOT NULL AUTO_INCREMENT,
+    `start_id`    bigint unsigned NOT NULL COMMENT 'first user id of the range',
+    `end_id`      bigint unsigned NOT NULL COMMENT 'user id after the range',
+    `next_id`     bigint unsigned NOT NULL COMMENT 'next user id to allocate, the range is full if it reaches end_id',
+    `shard`       varchar(64) NOT NULL COMMENT 'name of the shard in the config',
+    `state`       varchar(16) NOT NULL DEFAULT 'active' COMMENT 'active, or moving while it is copied to another shard',
+    `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY   (`id`),
+    UNIQUE KEY    `uk_start_id` (`start_id`),
+    KEY           `idx_shard` (`shard`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE `user_email_tab`
+(
+    `id`          bigint unsigned NOT NULL AUTO_INCREMENT,
+    `email`       varchar(255) NOT NULL,
+    `user_id`     bigint unsigned NOT NULL,
+    `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY   (`id`),
+    UNIQUE KEY    `uk_email` (`email`),
+    UNIQUE KEY    `uk_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
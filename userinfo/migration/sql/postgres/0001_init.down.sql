@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_tab;
+DROP TABLE IF EXISTS profile_tab;
+DROP FUNCTION IF EXISTS set_update_time;
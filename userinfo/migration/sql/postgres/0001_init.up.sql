@@ -0,0 +1,38 @@
+-- Tables of userinfo as they were before migrations were introduced, the later changes are the next migrations.
+-- Names of constraints and indexes are unique in the schema, so they are prefixed with the table except uk_user_id,
+-- which is checked by the service.
+
+CREATE OR REPLACE FUNCTION set_update_time() RETURNS trigger AS $$ BEGIN NEW.update_time = now(); RETURN NEW; END $$ LANGUAGE plpgsql;
+
+CREATE TABLE profile_tab
+(
+    id          bigint GENERATED BY DEFAULT AS IDENTITY,
+    user_id     bigint NOT NULL,
+    username    varchar(255) NOT NULL DEFAULT '',
+    birthday    date,
+    email       varchar(255) NOT NULL DEFAULT '',
+    avatar_url  varchar(255) NOT NULL DEFAULT '',
+    create_time timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    update_time timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT profile_tab_pkey PRIMARY KEY (id),
+    CONSTRAINT profile_tab_email UNIQUE (email)
+);
+
+CREATE INDEX profile_tab_idx_user_id ON profile_tab (user_id);
+
+CREATE TRIGGER profile_tab_update_time BEFORE UPDATE ON profile_tab FOR EACH ROW EXECUTE FUNCTION set_update_time();
+
+CREATE TABLE user_tab
+(
+    id          bigint GENERATED BY DEFAULT AS IDENTITY,
+    name        varchar(255) NOT NULL DEFAULT '',
+    password    varchar(255) NOT NULL DEFAULT '',
+    email       varchar(255) NOT NULL DEFAULT '',
+    status      smallint NOT NULL DEFAULT 0,
+    create_time timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    update_time timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT user_tab_pkey PRIMARY KEY (id),
+    CONSTRAINT user_tab_email UNIQUE (email)
+);
+
+CREATE TRIGGER user_tab_update_time BEFORE UPDATE ON user_tab FOR EACH ROW EXECUTE FUNCTION set_update_time();
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS profile_tab_idx_username;
+
+ALTER TABLE profile_tab
+    DROP COLUMN privacy,
+    DROP COLUMN attributes;
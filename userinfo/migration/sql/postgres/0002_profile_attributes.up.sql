@@ -0,0 +1,7 @@
+-- Custom attributes of profiles and the visibility of their fields.
+
+ALTER TABLE profile_tab
+    ADD COLUMN attributes jsonb,
+    ADD COLUMN privacy    jsonb;
+
+CREATE INDEX profile_tab_idx_username ON profile_tab (username);
@@ -0,0 +1,16 @@
+CREATE TABLE profile_history_tab
+(
+    id          bigint GENERATED BY DEFAULT AS IDENTITY,
+    user_id     bigint NOT NULL,
+    version     bigint NOT NULL,
+    operation   varchar(16) NOT NULL DEFAULT '',
+    changes     jsonb,
+    snapshot    jsonb,
+    actor_id    bigint NOT NULL DEFAULT 0,
+    request_id  varchar(64) NOT NULL DEFAULT '',
+    create_time timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT profile_history_tab_pkey PRIMARY KEY (id),
+    CONSTRAINT profile_history_tab_uk_user_id_version UNIQUE (user_id, version)
+);
+
+CREATE INDEX profile_history_tab_idx_user_id_create_time ON profile_history_tab (user_id, create_time);
@@ -0,0 +1,9 @@
+-- The duplicated profiles are restored from the backup.
+
+ALTER TABLE profile_tab DROP CONSTRAINT uk_user_id;
+
+CREATE INDEX profile_tab_idx_user_id ON profile_tab (user_id);
+
+INSERT INTO profile_tab OVERRIDING SYSTEM VALUE SELECT * FROM profile_tab_duplicate_backup;
+
+DROP TABLE IF EXISTS profile_tab_duplicate_backup;
@@ -0,0 +1,15 @@
+-- Enforce one profile per user. Duplicated profiles of a user are backed up and removed, only the latest updated
+-- one is kept.
+
+CREATE TABLE profile_tab_duplicate_backup (LIKE profile_tab);
+
+INSERT INTO profile_tab_duplicate_backup
+SELECT p.* FROM profile_tab p
+WHERE EXISTS (SELECT 1 FROM profile_tab q WHERE q.user_id = p.user_id
+    AND (p.update_time < q.update_time OR (p.update_time = q.update_time AND p.id < q.id)));
+
+DELETE FROM profile_tab p USING profile_tab_duplicate_backup b WHERE p.id = b.id;
+
+DROP INDEX profile_tab_idx_user_id;
+
+ALTER TABLE profile_tab ADD CONSTRAINT uk_user_id UNIQUE (user_id);
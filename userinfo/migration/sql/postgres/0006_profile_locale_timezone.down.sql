@@ -0,0 +1,3 @@
+ALTER TABLE profile_tab
+    DROP COLUMN timezone,
+    DROP COLUMN locale;
@@ -0,0 +1,5 @@
+-- Add locale and time zone preferences to profiles.
+
+ALTER TABLE profile_tab
+    ADD COLUMN locale   varchar(35) NOT NULL DEFAULT '',
+    ADD COLUMN timezone varchar(64) NOT NULL DEFAULT '';
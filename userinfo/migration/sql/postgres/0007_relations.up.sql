@@ -0,0 +1,29 @@
+CREATE TABLE relation_tab
+(
+    id          bigint GENERATED BY DEFAULT AS IDENTITY,
+    user_id     bigint NOT NULL,
+    target_id   bigint NOT NULL,
+    type        varchar(16) NOT NULL,
+    create_time timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT relation_tab_pkey PRIMARY KEY (id),
+    CONSTRAINT relation_tab_uk_user_id_target_id_type UNIQUE (user_id, target_id, type)
+);
+
+CREATE INDEX relation_tab_idx_user_id_type ON relation_tab (user_id, type);
+CREATE INDEX relation_tab_idx_target_id_type ON relation_tab (target_id, type);
+
+CREATE TABLE friend_request_tab
+(
+    id           bigint GENERATED BY DEFAULT AS IDENTITY,
+    from_user_id bigint NOT NULL,
+    to_user_id   bigint NOT NULL,
+    status       varchar(16) NOT NULL,
+    create_time  timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    update_time  timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT friend_request_tab_pkey PRIMARY KEY (id),
+    CONSTRAINT friend_request_tab_uk_from_user_id_to_user_id UNIQUE (from_user_id, to_user_id)
+);
+
+CREATE INDEX friend_request_tab_idx_to_user_id_status ON friend_request_tab (to_user_id, status);
+
+CREATE TRIGGER friend_request_tab_update_time BEFORE UPDATE ON friend_request_tab FOR EACH ROW EXECUTE FUNCTION set_update_time();
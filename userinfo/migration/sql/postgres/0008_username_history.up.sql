@@ -0,0 +1,12 @@
+CREATE TABLE username_history_tab
+(
+    id             bigint GENERATED BY DEFAULT AS IDENTITY,
+    user_id        bigint NOT NULL,
+    username       varchar(255) NOT NULL,
+    reserved_until timestamptz NOT NULL,
+    create_time    timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT username_history_tab_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX username_history_tab_idx_username_reserved_until ON username_history_tab (username, reserved_until);
+CREATE INDEX username_history_tab_idx_user_id_create_time ON username_history_tab (user_id, create_time);
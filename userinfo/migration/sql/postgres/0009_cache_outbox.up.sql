@@ -0,0 +1,12 @@
+CREATE TABLE cache_outbox_tab
+(
+    id              bigint GENERATED BY DEFAULT AS IDENTITY,
+    cache_key       varchar(255) NOT NULL,
+    stage           smallint NOT NULL DEFAULT 0,
+    attempts        integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    create_time     timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT cache_outbox_tab_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX cache_outbox_tab_idx_next_attempt_at ON cache_outbox_tab (next_attempt_at);
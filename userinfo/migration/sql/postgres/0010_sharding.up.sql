@@ -0,0 +1,32 @@
+-- Tables of the global database when user_tab and profile_tab are sharded by user id, see sharding in the config.
+-- They are created in the shards as well but left empty. uk_start_id is not prefixed with the table, it is checked by
+-- the service.
+
+CREATE TABLE shard_range_tab
+(
+    id          bigint GENERATED BY DEFAULT AS IDENTITY,
+    start_id    bigint NOT NULL,
+    end_id      bigint NOT NULL,
+    next_id     bigint NOT NULL,
+    shard       varchar(64) NOT NULL,
+    state       varchar(16) NOT NULL DEFAULT 'active',
+    create_time timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    update_time timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT shard_range_tab_pkey PRIMARY KEY (id),
+    CONSTRAINT uk_start_id UNIQUE (start_id)
+);
+
+CREATE INDEX shard_range_tab_idx_shard ON shard_range_tab (shard);
+
+CREATE TRIGGER shard_range_tab_update_time BEFORE UPDATE ON shard_range_tab FOR EACH ROW EXECUTE FUNCTION set_update_time();
+
+CREATE TABLE user_email_tab
+(
+    id          bigint GENERATED BY DEFAULT AS IDENTITY,
+    email       varchar(255) NOT NULL,
+    user_id     bigint NOT NULL,
+    create_time timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT user_email_tab_pkey PRIMARY KEY (id),
+    CONSTRAINT user_email_tab_uk_email UNIQUE (email),
+    CONSTRAINT user_email_tab_uk_user_id UNIQUE (user_id)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_tab;
+DROP TABLE IF EXISTS profile_tab;
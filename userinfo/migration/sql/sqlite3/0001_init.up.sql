@@ -0,0 +1,35 @@
+-- Tables of userinfo as they were before migrations were introduced, the later changes are the next migrations.
+-- Timestamps are UTC text as CURRENT_TIMESTAMP formats them. Names of indexes are unique in the database, so they
+-- are prefixed with the table.
+
+CREATE TABLE profile_tab
+(
+    id          integer PRIMARY KEY AUTOINCREMENT,
+    user_id     integer NOT NULL,
+    username    text NOT NULL DEFAULT '',
+    birthday    date,
+    email       text NOT NULL DEFAULT '',
+    avatar_url  text NOT NULL DEFAULT '',
+    create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    update_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX profile_tab_idx_user_id ON profile_tab (user_id);
+CREATE UNIQUE INDEX profile_tab_email ON profile_tab (email);
+
+CREATE TRIGGER profile_tab_update_time AFTER UPDATE ON profile_tab FOR EACH ROW WHEN NEW.update_time = OLD.update_time BEGIN UPDATE profile_tab SET update_time = CURRENT_TIMESTAMP WHERE id = NEW.id; END;
+
+CREATE TABLE user_tab
+(
+    id          integer PRIMARY KEY AUTOINCREMENT,
+    name        text NOT NULL DEFAULT '',
+    password    text NOT NULL DEFAULT '',
+    email       text NOT NULL DEFAULT '',
+    status      integer NOT NULL DEFAULT 0,
+    create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    update_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX user_tab_email ON user_tab (email);
+
+CREATE TRIGGER user_tab_update_time AFTER UPDATE ON user_tab FOR EACH ROW WHEN NEW.update_time = OLD.update_time BEGIN UPDATE user_tab SET update_time = CURRENT_TIMESTAMP WHERE id = NEW.id; END;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS profile_tab_idx_username;
+
+ALTER TABLE profile_tab DROP COLUMN privacy;
+ALTER TABLE profile_tab DROP COLUMN attributes;
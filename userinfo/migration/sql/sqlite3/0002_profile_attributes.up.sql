@@ -0,0 +1,6 @@
+-- Custom attributes of profiles and the visibility of their fields.
+
+ALTER TABLE profile_tab ADD COLUMN attributes text;
+ALTER TABLE profile_tab ADD COLUMN privacy text;
+
+CREATE INDEX profile_tab_idx_username ON profile_tab (username);
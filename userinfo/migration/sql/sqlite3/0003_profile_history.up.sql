@@ -0,0 +1,15 @@
+CREATE TABLE profile_history_tab
+(
+    id          integer PRIMARY KEY AUTOINCREMENT,
+    user_id     integer NOT NULL,
+    version     integer NOT NULL,
+    operation   text NOT NULL DEFAULT '',
+    changes     text,
+    snapshot    text,
+    actor_id    integer NOT NULL DEFAULT 0,
+    request_id  text NOT NULL DEFAULT '',
+    create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX profile_history_tab_uk_user_id_version ON profile_history_tab (user_id, version);
+CREATE INDEX profile_history_tab_idx_user_id_create_time ON profile_history_tab (user_id, create_time);
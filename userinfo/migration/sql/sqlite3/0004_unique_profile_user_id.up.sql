@@ -0,0 +1,15 @@
+-- Enforce one profile per user. Duplicated profiles of a user are backed up and removed, only the latest updated
+-- one is kept.
+
+CREATE TABLE profile_tab_duplicate_backup AS SELECT * FROM profile_tab WHERE 0;
+
+INSERT INTO profile_tab_duplicate_backup
+SELECT p.* FROM profile_tab p
+WHERE EXISTS (SELECT 1 FROM profile_tab q WHERE q.user_id = p.user_id
+    AND (p.update_time < q.update_time OR (p.update_time = q.update_time AND p.id < q.id)));
+
+DELETE FROM profile_tab WHERE id IN (SELECT id FROM profile_tab_duplicate_backup);
+
+DROP INDEX profile_tab_idx_user_id;
+
+CREATE UNIQUE INDEX profile_tab_uk_user_id ON profile_tab (user_id);
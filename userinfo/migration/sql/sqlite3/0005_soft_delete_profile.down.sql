@@ -0,0 +1 @@
+ALTER TABLE profile_tab DROP COLUMN deleted_at;
@@ -0,0 +1,2 @@
+ALTER TABLE profile_tab DROP COLUMN timezone;
+ALTER TABLE profile_tab DROP COLUMN locale;
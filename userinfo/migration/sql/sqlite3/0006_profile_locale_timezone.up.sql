@@ -0,0 +1,4 @@
+-- Add locale and time zone preferences to profiles.
+
+ALTER TABLE profile_tab ADD COLUMN locale text NOT NULL DEFAULT '';
+ALTER TABLE profile_tab ADD COLUMN timezone text NOT NULL DEFAULT '';
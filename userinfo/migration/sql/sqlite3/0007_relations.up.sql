@@ -0,0 +1,27 @@
+CREATE TABLE relation_tab
+(
+    id          integer PRIMARY KEY AUTOINCREMENT,
+    user_id     integer NOT NULL,
+    target_id   integer NOT NULL,
+    type        text NOT NULL,
+    create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX relation_tab_uk_user_id_target_id_type ON relation_tab (user_id, target_id, type);
+CREATE INDEX relation_tab_idx_user_id_type ON relation_tab (user_id, type);
+CREATE INDEX relation_tab_idx_target_id_type ON relation_tab (target_id, type);
+
+CREATE TABLE friend_request_tab
+(
+    id           integer PRIMARY KEY AUTOINCREMENT,
+    from_user_id integer NOT NULL,
+    to_user_id   integer NOT NULL,
+    status       text NOT NULL,
+    create_time  timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    update_time  timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX friend_request_tab_uk_from_user_id_to_user_id ON friend_request_tab (from_user_id, to_user_id);
+CREATE INDEX friend_request_tab_idx_to_user_id_status ON friend_request_tab (to_user_id, status);
+
+CREATE TRIGGER friend_request_tab_update_time AFTER UPDATE ON friend_request_tab FOR EACH ROW WHEN NEW.update_time = OLD.update_time BEGIN UPDATE friend_request_tab SET update_time = CURRENT_TIMESTAMP WHERE id = NEW.id; END;
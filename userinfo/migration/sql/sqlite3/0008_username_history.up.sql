@@ -0,0 +1,11 @@
+CREATE TABLE username_history_tab
+(
+    id             integer PRIMARY KEY AUTOINCREMENT,
+    user_id        integer NOT NULL,
+    username       text NOT NULL,
+    reserved_until timestamp NOT NULL,
+    create_time    timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX username_history_tab_idx_username_reserved_until ON username_history_tab (username, reserved_until);
+CREATE INDEX username_history_tab_idx_user_id_create_time ON username_history_tab (user_id, create_time);
@@ -0,0 +1,13 @@
+-- Timestamps of the outbox are in milliseconds.
+
+CREATE TABLE cache_outbox_tab
+(
+    id              integer PRIMARY KEY AUTOINCREMENT,
+    cache_key       text NOT NULL,
+    stage           integer NOT NULL DEFAULT 0,
+    attempts        integer NOT NULL DEFAULT 0,
+    next_attempt_at text NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    create_time     text NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX cache_outbox_tab_idx_next_attempt_at ON cache_outbox_tab (next_attempt_at);
@@ -0,0 +1,30 @@
+-- Tables of the global database when user_tab and profile_tab are sharded by user id, see sharding in the config.
+-- They are created in the shards as well but left empty.
+
+CREATE TABLE shard_range_tab
+(
+    id          integer PRIMARY KEY AUTOINCREMENT,
+    start_id    integer NOT NULL,
+    end_id      integer NOT NULL,
+    next_id     integer NOT NULL,
+    shard       text NOT NULL,
+    state       text NOT NULL DEFAULT 'active',
+    create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    update_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX shard_range_tab_uk_start_id ON shard_range_tab (start_id);
+CREATE INDEX shard_range_tab_idx_shard ON shard_range_tab (shard);
+
+CREATE TRIGGER shard_range_tab_update_time AFTER UPDATE ON shard_range_tab FOR EACH ROW WHEN NEW.update_time = OLD.update_time BEGIN UPDATE shard_range_tab SET update_time = CURRENT_TIMESTAMP WHERE id = NEW.id; END;
+
+CREATE TABLE user_email_tab
+(
+    id          integer PRIMARY KEY AUTOINCREMENT,
+    email       text NOT NULL,
+    user_id     integer NOT NULL,
+    create_time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX user_email_tab_uk_email ON user_email_tab (email);
+CREATE UNIQUE INDEX user_email_tab_uk_user_id ON user_email_tab (user_id);
@@ -0,0 +1,221 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+)
+
+const (
+	ATTRIBUTE_TYPE_STRING = "string"
+	ATTRIBUTE_TYPE_INT    = "int"
+	ATTRIBUTE_TYPE_FLOAT  = "float"
+	ATTRIBUTE_TYPE_BOOL   = "bool"
+)
+
+const (
+	VISIBILITY_PUBLIC    = "public"
+	VISIBILITY_LOGGED_IN = "logged_in"
+	VISIBILITY_PRIVATE   = "private"
+)
+
+// AttributeDefinition describes a custom profile attribute.
+// Values of custom attributes are stored as a json object in the attributes column of profile_tab,
+// so adding a new attribute only needs a new definition instead of a schema change.
+type AttributeDefinition struct {
+	Name       string
+	Type       string
+	MaxLength  int
+	Pattern    string
+	Min        *float64
+	Max        *float64
+	Enum       []string
+	Visibility string
+
+	pattern *regexp.Regexp
+}
+
+// AttributeRegistry holds all the known attribute definitions and validates attribute values against them.
+type AttributeRegistry struct {
+	definitions map[string]*AttributeDefinition
+}
+
+func NewAttributeRegistry(definitions []*AttributeDefinition) (*AttributeRegistry, error) {
+	r := &AttributeRegistry{
+		definitions: make(map[string]*AttributeDefinition),
+	}
+	for _, d := range definitions {
+		if d.Name == "" {
+			return nil, fmt.Errorf("attribute name is empty")
+		}
+		if _, ok := r.definitions[d.Name]; ok {
+			return nil, fmt.Errorf("attribute %v is defined more than once", d.Name)
+		}
+		// attributes share the privacy settings with the built-in fields.
+		if _, ok := defaultFieldVisibility[d.Name]; ok {
+			return nil, fmt.Errorf("attribute %v conflicts with a profile field", d.Name)
+		}
+		switch d.Type {
+		case ATTRIBUTE_TYPE_STRING, ATTRIBUTE_TYPE_INT, ATTRIBUTE_TYPE_FLOAT, ATTRIBUTE_TYPE_BOOL:
+		default:
+			return nil, fmt.Errorf("attribute %v has unknown type %v", d.Name, d.Type)
+		}
+		switch d.Visibility {
+		case "":
+			d.Visibility = VISIBILITY_PRIVATE
+		case VISIBILITY_PUBLIC, VISIBILITY_LOGGED_IN, VISIBILITY_PRIVATE:
+		default:
+			return nil, fmt.Errorf("attribute %v has unknown visibility %v", d.Name, d.Visibility)
+		}
+		if d.Pattern != "" {
+			pattern, err := regexp.Compile(d.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("attribute %v has invalid pattern, err: %v", d.Name, err)
+			}
+			d.pattern = pattern
+		}
+		r.definitions[d.Name] = d
+	}
+	return r, nil
+}
+
+func (r *AttributeRegistry) Definition(name string) (*AttributeDefinition, bool) {
+	d, ok := r.definitions[name]
+	return d, ok
+}
+
+// Definitions returns all definitions sorted by name.
+func (r *AttributeRegistry) Definitions() []*AttributeDefinition {
+	definitions := make([]*AttributeDefinition, 0, len(r.definitions))
+	for _, d := range r.definitions {
+		definitions = append(definitions, d)
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Name < definitions[j].Name
+	})
+	return definitions
+}
+
+// Validate checks every attribute against its definition, and returns the errors keyed by attribute name.
+// Valid values are converted to their defined types. A nil value means removing the attribute, so it is always valid.
+func (r *AttributeRegistry) Validate(attributes map[string]any) map[string]error {
+	errs := make(map[string]error)
+	for name, value := range attributes {
+		if value == nil {
+			if _, ok := r.definitions[name]; !ok {
+				errs[name] = fmt.Errorf("attribute %v is not defined", name)
+			}
+			continue
+		}
+		v, err := r.Check(name, value)
+		if err != nil {
+			errs[name] = err
+			continue
+		}
+		attributes[name] = v
+	}
+	return errs
+}
+
+// Normalize converts attributes decoded from json to their defined types.
+// Attributes which are no longer defined or no longer valid are dropped.
+func (r *AttributeRegistry) Normalize(attributes map[string]any) map[string]any {
+	if attributes == nil {
+		return nil
+	}
+	normalized := make(map[string]any, len(attributes))
+	for name, value := range attributes {
+		v, err := r.Check(name, value)
+		if err != nil {
+			continue
+		}
+		normalized[name] = v
+	}
+	return normalized
+}
+
+// Check validates a single attribute value and returns it converted to the defined type.
+func (r *AttributeRegistry) Check(name string, value any) (any, error) {
+	d, ok := r.definitions[name]
+	if !ok {
+		return nil, fmt.Errorf("attribute %v is not defined", name)
+	}
+	switch d.Type {
+	case ATTRIBUTE_TYPE_STRING:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("attribute %v should be a string", name)
+		}
+		if d.MaxLength > 0 && len([]rune(s)) > d.MaxLength {
+			return nil, fmt.Errorf("attribute %v is longer than %v", name, d.MaxLength)
+		}
+		if d.pattern != nil && !d.pattern.MatchString(s) {
+			return nil, fmt.Errorf("attribute %v does not match pattern %v", name, d.Pattern)
+		}
+		if len(d.Enum) > 0 && !contains(d.Enum, s) {
+			return nil, fmt.Errorf("attribute %v should be one of %v", name, d.Enum)
+		}
+		return s, nil
+	case ATTRIBUTE_TYPE_INT:
+		n, ok := toFloat(value)
+		if !ok || n != math.Trunc(n) {
+			return nil, fmt.Errorf("attribute %v should be an integer", name)
+		}
+		if err := d.checkRange(n); err != nil {
+			return nil, err
+		}
+		return int64(n), nil
+	case ATTRIBUTE_TYPE_FLOAT:
+		n, ok := toFloat(value)
+		if !ok {
+			return nil, fmt.Errorf("attribute %v should be a number", name)
+		}
+		if err := d.checkRange(n); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case ATTRIBUTE_TYPE_BOOL:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("attribute %v should be a bool", name)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("attribute %v has unknown type %v", name, d.Type)
+}
+
+func (d *AttributeDefinition) checkRange(n float64) error {
+	if d.Min != nil && n < *d.Min {
+		return fmt.Errorf("attribute %v should not be less than %v", d.Name, *d.Min)
+	}
+	if d.Max != nil && n > *d.Max {
+		return fmt.Errorf("attribute %v should not be greater than %v", d.Name, *d.Max)
+	}
+	return nil
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
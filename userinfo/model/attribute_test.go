@@ -0,0 +1,49 @@
+package model
+
+import "testing"
+
+func newTestRegistry(t *testing.T) *AttributeRegistry {
+	max := float64(300)
+	r, err := NewAttributeRegistry([]*AttributeDefinition{
+		{Name: "nickname", Type: ATTRIBUTE_TYPE_STRING, MaxLength: 4},
+		{Name: "height_cm", Type: ATTRIBUTE_TYPE_INT, Max: &max},
+		{Name: "gender", Type: ATTRIBUTE_TYPE_STRING, Enum: []string{"male", "female"}},
+	})
+	if err != nil {
+		t.Fatalf("NewAttributeRegistry failed, err: %v", err)
+	}
+	return r
+}
+
+func TestAttributeRegistry_Validate(t *testing.T) {
+	r := newTestRegistry(t)
+	valid := map[string]any{"nickname": "lgk", "height_cm": int64(180), "gender": nil}
+	if errs := r.Validate(valid); len(errs) != 0 {
+		t.Errorf("expect valid, got errs: %v", errs)
+	}
+
+	invalids := []map[string]any{
+		{"nickname": "too long"},
+		{"height_cm": int64(301)},
+		{"height_cm": 1.5},
+		{"gender": "unknown"},
+		{"undefined": "x"},
+	}
+	for _, attributes := range invalids {
+		if errs := r.Validate(attributes); len(errs) != 1 {
+			t.Errorf("expect invalid, attributes: %v", attributes)
+		}
+	}
+}
+
+func TestAttributeRegistry_Normalize(t *testing.T) {
+	r := newTestRegistry(t)
+	// attributes decoded from json have float64 numbers.
+	attributes := r.Normalize(map[string]any{"height_cm": float64(180), "undefined": "x"})
+	if attributes["height_cm"] != int64(180) {
+		t.Errorf("expect height_cm converted to int64, got %T", attributes["height_cm"])
+	}
+	if _, ok := attributes["undefined"]; ok {
+		t.Errorf("expect undefined attribute dropped")
+	}
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+const (
+	// the key is deleted once the change is committed, and again after the replication lag of the slave,
+	// since a read racing with the change may have cached the data before the change.
+	CACHE_OUTBOX_STAGE_DELETE        = 0
+	CACHE_OUTBOX_STAGE_DOUBLE_DELETE = 1
+)
+
+// CacheOutboxEntry is a cache key to delete, written in the same transaction as the change of the cached data.
+type CacheOutboxEntry struct {
+	Id       uint64
+	CacheKey string
+	Stage    int
+	Attempts int
+}
+
+// CacheOutboxStats shows how far the deletion of cache keys falls behind the changes.
+type CacheOutboxStats struct {
+	// Pending is the number of keys to delete, including those to delete again.
+	Pending uint64
+	// Lag is the age of the oldest change whose key has not been deleted yet.
+	Lag time.Duration
+	// Relayed and Failed count the deletions since the relay is started.
+	Relayed uint64
+	Failed  uint64
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"fmt"
+	"golang.org/x/text/language"
+	"time"
+)
+
+// birthdayLayouts are the layouts of birthday in supported locales, the first one is the fallback.
+var birthdayLayouts = []struct {
+	tag    language.Tag
+	layout string
+}{
+	{language.Und, BIRTHDAY_LAYOUT},
+	{language.AmericanEnglish, "01/02/2006"},
+	{language.BritishEnglish, "02/01/2006"},
+	{language.German, "02.01.2006"},
+	{language.French, "02/01/2006"},
+	{language.Spanish, "02/01/2006"},
+	{language.Russian, "02.01.2006"},
+	{language.Chinese, "2006年1月2日"},
+	{language.Japanese, "2006年1月2日"},
+	{language.Korean, "2006년 1월 2일"},
+}
+
+var anyLanguage = language.MustParse("mul")
+
+var birthdayLayoutMatcher = func() language.Matcher {
+	tags := make([]language.Tag, 0, len(birthdayLayouts))
+	for _, l := range birthdayLayouts {
+		tags = append(tags, l.tag)
+	}
+	return language.NewMatcher(tags)
+}()
+
+// CanonicalLocale checks a BCP 47 language tag and returns it in canonical form, e.g. en-us to en-US.
+func CanonicalLocale(locale string) (string, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", fmt.Errorf("%v is not a valid BCP 47 language tag", locale)
+	}
+	return tag.String(), nil
+}
+
+// LocaleFromAcceptLanguage returns the most preferred locale in an Accept-Language header,
+// or empty string if there is none.
+func LocaleFromAcceptLanguage(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil {
+		return ""
+	}
+	for _, tag := range tags {
+		// "*" is parsed as "mul", which means any language.
+		if tag != language.Und && tag != anyLanguage {
+			return tag.String()
+		}
+	}
+	return ""
+}
+
+// CheckTimezone checks that timezone is an IANA time zone name in the tz database.
+func CheckTimezone(timezone string) error {
+	// LoadLocation accepts "Local" and "" which are not time zone names.
+	if timezone == "Local" {
+		return fmt.Errorf("%v is not an IANA time zone", timezone)
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("%v is not an IANA time zone", timezone)
+	}
+	return nil
+}
+
+// FormatBirthday renders the birthday in the locale of the profile.
+// Empty string is returned if the birthday is not set.
+func (p *Profile) FormatBirthday() string {
+	birthday, err := time.Parse(BIRTHDAY_LAYOUT, p.Birthday)
+	if err != nil {
+		return p.Birthday
+	}
+	tag, err := language.Parse(p.Locale)
+	if err != nil {
+		return birthday.Format(BIRTHDAY_LAYOUT)
+	}
+	_, i, _ := birthdayLayoutMatcher.Match(tag)
+	return birthday.Format(birthdayLayouts[i].layout)
+}
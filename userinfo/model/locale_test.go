@@ -0,0 +1,29 @@
+package model
+
+import "testing"
+
+func TestProfile_FormatBirthday(t *testing.T) {
+	cases := map[string]string{
+		"":      "2000-01-31",
+		"en-US": "01/31/2000",
+		"en-GB": "31/01/2000",
+		"de-AT": "31.01.2000",
+		"zh-CN": "2000年1月31日",
+		"xx":    "2000-01-31",
+	}
+	for locale, expected := range cases {
+		p := &Profile{Birthday: "2000-01-31", Locale: locale}
+		if got := p.FormatBirthday(); got != expected {
+			t.Errorf("locale %v: expect %v, got %v", locale, expected, got)
+		}
+	}
+}
+
+func TestLocaleFromAcceptLanguage(t *testing.T) {
+	if locale := LocaleFromAcceptLanguage("fr-CH, fr;q=0.9, en;q=0.8, *;q=0.5"); locale != "fr-CH" {
+		t.Errorf("expect fr-CH, got %v", locale)
+	}
+	if locale := LocaleFromAcceptLanguage("*"); locale != "" {
+		t.Errorf("expect empty locale, got %v", locale)
+	}
+}
@@ -0,0 +1,110 @@
+package model
+
+import "fmt"
+
+const (
+	PROFILE_FIELD_USERNAME = "username"
+	PROFILE_FIELD_BIRTHDAY = "birthday"
+	PROFILE_FIELD_EMAIL    = "email"
+	PROFILE_FIELD_AVATAR   = "avatar"
+	PROFILE_FIELD_LOCALE   = "locale"
+	PROFILE_FIELD_TIMEZONE = "timezone"
+)
+
+// defaultFieldVisibility is used when the user has not set the visibility of a field.
+// Id and user_id are always visible.
+var defaultFieldVisibility = map[string]string{
+	PROFILE_FIELD_USERNAME: VISIBILITY_PUBLIC,
+	PROFILE_FIELD_BIRTHDAY: VISIBILITY_PRIVATE,
+	PROFILE_FIELD_EMAIL:    VISIBILITY_PRIVATE,
+	PROFILE_FIELD_AVATAR:   VISIBILITY_PUBLIC,
+	PROFILE_FIELD_LOCALE:   VISIBILITY_PRIVATE,
+	PROFILE_FIELD_TIMEZONE: VISIBILITY_PRIVATE,
+}
+
+var visibilityRank = map[string]int{
+	VISIBILITY_PUBLIC:    0,
+	VISIBILITY_LOGGED_IN: 1,
+	VISIBILITY_PRIVATE:   2,
+}
+
+// Audience returns who the viewer is to the owner of the profile.
+// viewerId 0 means the viewer is not logged in.
+func Audience(ownerId uint64, viewerId uint64) string {
+	if viewerId == 0 {
+		return VISIBILITY_PUBLIC
+	}
+	if viewerId == ownerId {
+		return VISIBILITY_PRIVATE
+	}
+	return VISIBILITY_LOGGED_IN
+}
+
+// CheckVisibility checks a privacy setting. An empty visibility resets the field to its default visibility.
+func CheckVisibility(field string, visibility string, registry *AttributeRegistry) error {
+	_, isField := defaultFieldVisibility[field]
+	_, isAttribute := registry.Definition(field)
+	if !isField && !isAttribute {
+		return fmt.Errorf("field %v is not defined", field)
+	}
+	if _, ok := visibilityRank[visibility]; !ok && visibility != "" {
+		return fmt.Errorf("field %v has unknown visibility %v", field, visibility)
+	}
+	return nil
+}
+
+// Visibility returns the visibility of a field or a custom attribute.
+func (p *Profile) Visibility(field string, registry *AttributeRegistry) string {
+	if visibility, ok := p.Privacy[field]; ok && visibility != "" {
+		return visibility
+	}
+	if visibility, ok := defaultFieldVisibility[field]; ok {
+		return visibility
+	}
+	if d, ok := registry.Definition(field); ok {
+		return d.Visibility
+	}
+	return VISIBILITY_PRIVATE
+}
+
+// Project returns a copy of the profile that only contains fields the audience is permitted to see.
+func (p *Profile) Project(audience string, registry *AttributeRegistry) *Profile {
+	if audience == VISIBILITY_PRIVATE {
+		return p
+	}
+	visible := func(field string) bool {
+		return visibilityRank[p.Visibility(field, registry)] <= visibilityRank[audience]
+	}
+	projected := &Profile{
+		Id:     p.Id,
+		UserId: p.UserId,
+	}
+	if visible(PROFILE_FIELD_USERNAME) {
+		projected.Username = p.Username
+	}
+	if visible(PROFILE_FIELD_BIRTHDAY) {
+		projected.Birthday = p.Birthday
+	}
+	if visible(PROFILE_FIELD_EMAIL) {
+		projected.Email = p.Email
+	}
+	if visible(PROFILE_FIELD_AVATAR) {
+		projected.AvatarUrl = p.AvatarUrl
+	}
+	if visible(PROFILE_FIELD_LOCALE) {
+		projected.Locale = p.Locale
+	}
+	if visible(PROFILE_FIELD_TIMEZONE) {
+		projected.Timezone = p.Timezone
+	}
+	for name, value := range p.Attributes {
+		if !visible(name) {
+			continue
+		}
+		if projected.Attributes == nil {
+			projected.Attributes = make(map[string]any)
+		}
+		projected.Attributes[name] = value
+	}
+	return projected
+}
@@ -0,0 +1,34 @@
+package model
+
+import "testing"
+
+func TestProfile_Project(t *testing.T) {
+	r := newTestRegistry(t)
+	p := &Profile{
+		Id:         1,
+		UserId:     2,
+		Username:   "lgk",
+		Birthday:   "2000-01-01",
+		Email:      "lgk@example.com",
+		Attributes: map[string]any{"nickname": "k"},
+		Privacy:    map[string]string{PROFILE_FIELD_BIRTHDAY: VISIBILITY_LOGGED_IN, "nickname": VISIBILITY_PUBLIC},
+	}
+
+	public := p.Project(Audience(2, 0), r)
+	if public.Username != "lgk" || public.Birthday != "" || public.Email != "" {
+		t.Errorf("unexpected public projection: %+v", public)
+	}
+	if public.Attributes["nickname"] != "k" {
+		t.Errorf("expect public nickname, got: %v", public.Attributes)
+	}
+
+	loggedIn := p.Project(Audience(2, 3), r)
+	if loggedIn.Birthday != "2000-01-01" || loggedIn.Email != "" || loggedIn.Privacy != nil {
+		t.Errorf("unexpected logged in projection: %+v", loggedIn)
+	}
+
+	owner := p.Project(Audience(2, 2), r)
+	if owner.Email != "lgk@example.com" {
+		t.Errorf("expect owner sees everything, got: %+v", owner)
+	}
+}
@@ -1,11 +1,13 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
-	"time"
+	"maps"
 )
 
+const BIRTHDAY_LAYOUT = "2006-01-02"
+
 type Profile struct {
 	Id        uint64 `json:"id"`
 	UserId    uint64 `json:"user_id"`
@@ -13,6 +15,22 @@ type Profile struct {
 	Birthday  string `json:"birthday"`
 	Email     string `json:"email"`
 	AvatarUrl string `json:"avatar_url"`
+	// Locale is a BCP 47 language tag, and Timezone is an IANA time zone name.
+	Locale   string `json:"locale"`
+	Timezone string `json:"timezone"`
+	// Attributes are custom attributes defined in AttributeRegistry.
+	Attributes map[string]any `json:"attributes,omitempty"`
+	// Privacy maps fields and custom attributes to their visibility.
+	Privacy map[string]string `json:"privacy,omitempty"`
+}
+
+// Clone returns a copy of the profile which can be modified independently.
+// Values of attributes are scalars, so they are shared.
+func (p *Profile) Clone() *Profile {
+	c := *p
+	c.Attributes = maps.Clone(p.Attributes)
+	c.Privacy = maps.Clone(p.Privacy)
+	return &c
 }
 
 func (p *Profile) UpdateFields() ([]string, []any) {
@@ -31,13 +49,9 @@ func (p *Profile) UpdateFields() ([]string, []any) {
 		args = append(args, p.Username)
 	}
 	if p.Birthday != "" {
+		// birthday has been validated in the format of BIRTHDAY_LAYOUT by ProfileService.
 		fields = append(fields, "birthday")
-		date, err := time.Parse("2006-01-02", p.Birthday)
-		if err != nil {
-			log.Println("Parse birthday failed, using default date. Err: ", err.Error())
-			date = time.Date(1970, 1, 1, 0, 0, 0, 0, time.Local)
-		}
-		args = append(args, date)
+		args = append(args, p.Birthday)
 	}
 	if p.Email != "" {
 		fields = append(fields, "email")
@@ -47,13 +61,50 @@ func (p *Profile) UpdateFields() ([]string, []any) {
 		fields = append(fields, "avatar_url")
 		args = append(args, p.AvatarUrl)
 	}
+	if p.Locale != "" {
+		fields = append(fields, "locale")
+		args = append(args, p.Locale)
+	}
+	if p.Timezone != "" {
+		fields = append(fields, "timezone")
+		args = append(args, p.Timezone)
+	}
+	if len(p.Attributes) > 0 {
+		fields = append(fields, "attributes")
+		attributes, _ := json.Marshal(p.Attributes)
+		args = append(args, string(attributes))
+	}
+	if len(p.Privacy) > 0 {
+		fields = append(fields, "privacy")
+		// empty visibility is saved as null so that it is removed when merging.
+		privacy := make(map[string]any, len(p.Privacy))
+		for field, visibility := range p.Privacy {
+			if visibility == "" {
+				privacy[field] = nil
+			} else {
+				privacy[field] = visibility
+			}
+		}
+		privacyBytes, _ := json.Marshal(privacy)
+		args = append(args, string(privacyBytes))
+	}
 	return fields, args
 }
 
-func (p *Profile) UpdateSql(fields []string, tabName string) string {
+// JSONMerger builds the SQL merging a json object into a column, it is implemented by the sql dialects.
+type JSONMerger interface {
+	JSONMergePatch(column string) string
+}
+
+func (p *Profile) UpdateSql(fields []string, tabName string, merger JSONMerger) string {
 	setSql := "SET "
 	for i, field := range fields {
-		setSql = setSql + field + "=?"
+		if field == "attributes" || field == "privacy" {
+			// merge json objects into the existing ones, null values remove the keys.
+			setSql = setSql + field + "=" + merger.JSONMergePatch(field)
+		} else {
+			setSql = setSql + field + "=?"
+		}
 		if i < len(fields)-1 {
 			setSql += ","
 		}
@@ -62,6 +113,49 @@ func (p *Profile) UpdateSql(fields []string, tabName string) string {
 	return sqlString
 }
 
+// Merge updates the profile with the fields set in update as UpdateSql does. Attributes and privacy settings are
+// merged into the existing ones, and nil attributes and empty visibilities remove them.
+func (p *Profile) Merge(update *Profile) {
+	if update.Username != "" {
+		p.Username = update.Username
+	}
+	if update.Birthday != "" {
+		p.Birthday = update.Birthday
+	}
+	if update.Email != "" {
+		p.Email = update.Email
+	}
+	if update.AvatarUrl != "" {
+		p.AvatarUrl = update.AvatarUrl
+	}
+	if update.Locale != "" {
+		p.Locale = update.Locale
+	}
+	if update.Timezone != "" {
+		p.Timezone = update.Timezone
+	}
+	for name, value := range update.Attributes {
+		if p.Attributes == nil {
+			p.Attributes = make(map[string]any)
+		}
+		if value == nil {
+			delete(p.Attributes, name)
+		} else {
+			p.Attributes[name] = value
+		}
+	}
+	for field, visibility := range update.Privacy {
+		if p.Privacy == nil {
+			p.Privacy = make(map[string]string)
+		}
+		if visibility == "" {
+			delete(p.Privacy, field)
+		} else {
+			p.Privacy[field] = visibility
+		}
+	}
+}
+
 func (p *Profile) InsertSql(fields []string, tabName string) string {
 	fieldsSql := ""
 	for i, field := range fields {
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"reflect"
+	"sort"
+)
+
+const (
+	PROFILE_OPERATION_CREATE  = "create"
+	PROFILE_OPERATION_UPDATE  = "update"
+	PROFILE_OPERATION_DELETE  = "delete"
+	PROFILE_OPERATION_RESTORE = "restore"
+)
+
+// ProfileHistory is a version of a profile, recorded in the same transaction as the change.
+// Snapshot is the whole profile after the change, and it is nil for delete.
+type ProfileHistory struct {
+	Id         uint64
+	UserId     uint64
+	Version    uint64
+	Operation  string
+	Changes    map[string]*FieldChange
+	Snapshot   *Profile
+	ActorId    uint64
+	RequestId  string
+	CreateTime int64
+}
+
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// DiffProfiles returns the changed fields between two versions of a profile.
+// Either of them can be nil. Custom attributes and privacy settings are keyed as attributes.<name> and privacy.<field>.
+func DiffProfiles(before *Profile, after *Profile) map[string]*FieldChange {
+	if before == nil {
+		before = &Profile{}
+	}
+	if after == nil {
+		after = &Profile{}
+	}
+	changes := make(map[string]*FieldChange)
+	diff := func(field string, o any, n any) {
+		if !reflect.DeepEqual(o, n) {
+			changes[field] = &FieldChange{Old: o, New: n}
+		}
+	}
+	diff("username", before.Username, after.Username)
+	diff("birthday", before.Birthday, after.Birthday)
+	diff("email", before.Email, after.Email)
+	diff("avatar_url", before.AvatarUrl, after.AvatarUrl)
+	diff("locale", before.Locale, after.Locale)
+	diff("timezone", before.Timezone, after.Timezone)
+	for name := range union(before.Attributes, after.Attributes) {
+		diff("attributes."+name, before.Attributes[name], after.Attributes[name])
+	}
+	for field := range union(before.Privacy, after.Privacy) {
+		diff("privacy."+field, emptyToNil(before.Privacy[field]), emptyToNil(after.Privacy[field]))
+	}
+	return changes
+}
+
+// ChangedFields returns the changed fields sorted by name.
+func (h *ProfileHistory) ChangedFields() []string {
+	fields := make([]string, 0, len(h.Changes))
+	for field := range h.Changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func union[V any](a map[string]V, b map[string]V) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+func emptyToNil(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,35 @@
+package model
+
+import "testing"
+
+func TestDiffProfiles(t *testing.T) {
+	before := &Profile{
+		Username:   "lgk",
+		Birthday:   "2000-01-01",
+		Attributes: map[string]any{"nickname": "k"},
+	}
+	after := &Profile{
+		Username:   "lgk",
+		Birthday:   "2000-01-02",
+		Attributes: map[string]any{"gender": "male"},
+		Privacy:    map[string]string{PROFILE_FIELD_BIRTHDAY: VISIBILITY_PUBLIC},
+	}
+	h := &ProfileHistory{Changes: DiffProfiles(before, after)}
+	fields := h.ChangedFields()
+	expected := []string{"attributes.gender", "attributes.nickname", "birthday", "privacy.birthday"}
+	if len(fields) != len(expected) {
+		t.Fatalf("expect changes %v, got %v", expected, fields)
+	}
+	for i := range expected {
+		if fields[i] != expected[i] {
+			t.Errorf("expect changes %v, got %v", expected, fields)
+		}
+	}
+	if h.Changes["attributes.nickname"].New != nil {
+		t.Errorf("expect removed attribute has nil new value")
+	}
+
+	if len(DiffProfiles(nil, before)) != 3 {
+		t.Errorf("expect all fields changed when creating, got %v", DiffProfiles(nil, before))
+	}
+}
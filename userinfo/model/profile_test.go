@@ -26,7 +26,7 @@ func TestProfile_UpdateSql(t *testing.T) {
 		AvatarUrl: "",
 	}
 	fields, _ := p.UpdateFields()
-	t.Log(p.UpdateSql(fields, "profile_tab"))
+	t.Log(p.UpdateSql(fields, "profile_tab", nil))
 }
 
 func TestProfile_InsertSql(t *testing.T) {
@@ -41,3 +41,28 @@ func TestProfile_InsertSql(t *testing.T) {
 	fields, _ := p.UpdateFields()
 	t.Log(p.InsertSql(fields, "profile_tab"))
 }
+
+func TestProfile_Merge(t *testing.T) {
+	p := &Profile{
+		UserId:     2,
+		Username:   "alice",
+		Locale:     "en",
+		Attributes: map[string]any{"city": "Paris", "height": 170},
+		Privacy:    map[string]string{"birthday": "friends"},
+	}
+	p.Merge(&Profile{
+		Locale:     "fr",
+		Timezone:   "Europe/Paris",
+		Attributes: map[string]any{"city": nil, "job": "chef"},
+		Privacy:    map[string]string{"birthday": "", "email": "private"},
+	})
+	if p.Username != "alice" || p.Locale != "fr" || p.Timezone != "Europe/Paris" {
+		t.Errorf("expect username alice, locale fr and timezone Europe/Paris, got %v, %v and %v", p.Username, p.Locale, p.Timezone)
+	}
+	if len(p.Attributes) != 2 || p.Attributes["job"] != "chef" || p.Attributes["height"] != 170 {
+		t.Errorf("expect attributes height and job, got %v", p.Attributes)
+	}
+	if len(p.Privacy) != 1 || p.Privacy["email"] != "private" {
+		t.Errorf("expect privacy of email only, got %v", p.Privacy)
+	}
+}
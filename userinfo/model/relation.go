@@ -0,0 +1,26 @@
+package model
+
+const (
+	RELATION_TYPE_FOLLOW = "follow"
+	RELATION_TYPE_FRIEND = "friend"
+	RELATION_TYPE_BLOCK  = "block"
+
+	FRIEND_REQUEST_STATUS_PENDING  = "pending"
+	FRIEND_REQUEST_STATUS_ACCEPTED = "accepted"
+	FRIEND_REQUEST_STATUS_REJECTED = "rejected"
+)
+
+// Relation is a directed edge from UserId to TargetId in the relationship graph.
+// A friendship is saved as two edges, and a friend request is an edge from the sender to the receiver.
+type Relation struct {
+	Id       uint64
+	UserId   uint64
+	TargetId uint64
+}
+
+type RelationCounts struct {
+	Followers      uint64 `json:"followers"`
+	Following      uint64 `json:"following"`
+	Friends        uint64 `json:"friends"`
+	FriendRequests uint64 `json:"friend_requests"`
+}
@@ -0,0 +1,24 @@
+package model
+
+const (
+	SHARD_RANGE_STATE_ACTIVE = "active"
+	// SHARD_RANGE_STATE_MOVING stops the writes of the range while it is copied to another shard, reads still go
+	// to the shard it is moved from.
+	SHARD_RANGE_STATE_MOVING = "moving"
+)
+
+// ShardRange assigns the user ids in [StartId, EndId) to a shard. Ids of new users are allocated from NextId
+// of a range which is not full, i.e. NextId < EndId.
+type ShardRange struct {
+	Id      uint64
+	StartId uint64
+	EndId   uint64
+	NextId  uint64
+	Shard   string
+	State   string
+}
+
+// Contains reports whether the user id is in the range.
+func (r *ShardRange) Contains(userId uint64) bool {
+	return userId >= r.StartId && userId < r.EndId
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+const (
+	USERNAME_CHANGE_COOLDOWN_DEFAULT = time.Hour * 24 * 30
+	USERNAME_RESERVE_PERIOD_DEFAULT  = time.Hour * 24 * 90
+)
+
+// UsernamePolicy limits how often a user can change the username, and how long a previous username is reserved.
+// A reserved username can not be taken by other users, and lookups of it are redirected to its previous owner.
+type UsernamePolicy struct {
+	ChangeCooldown time.Duration
+	ReservePeriod  time.Duration
+}
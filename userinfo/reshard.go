@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"loggers"
+	"time"
+	"user-server/conf"
+	"user-server/dao"
+	"user-server/dialect"
+)
+
+// RESHARD_WAIT_REFRESHES is the wait of a move in refresh intervals of the ranges, see dao.ShardMover.Move.
+const RESHARD_WAIT_REFRESHES = 3
+
+// reshard assigns the existing users to a shard, moves a range of users to another shard, or prints the ranges.
+// init is run once before the service is started with the shards, a move is run while the service is serving.
+// The writes of the moved users fail with ERR_SHARD_RANGE_MOVING, i.e. 503, from the start of the move until the
+// instances reload the range after it is copied, i.e. for about wait plus the copy plus one refresh interval.
+//
+//	userinfo reshard -config=conf/userinfo.yaml -shard=shard0 init
+//	userinfo reshard -config=conf/userinfo.yaml -start=1000001 -to=shard1 move
+//	userinfo reshard -config=conf/userinfo.yaml status
+func reshard(args []string) error {
+	flags := flag.NewFlagSet("reshard", flag.ExitOnError)
+	confPath := flags.String("config", "conf/userinfo.yaml", "define config file")
+	shardName := flags.String("shard", "", "shard to assign the existing users to by init")
+	startId := flags.Uint64("start", 0, "first user id of the range to move")
+	to := flags.String("to", "", "shard to move the range to")
+	wait := flags.Duration("wait", 0, "wait for the instances to reload the ranges, 3 refresh intervals by default."+
+		" The writes of the range fail for about wait plus the copy plus one refresh interval")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	command := flags.Arg(0)
+	if command != "init" && command != "move" && command != "status" {
+		return fmt.Errorf("usage: userinfo reshard [-config=path] [-shard=name] [-start=id -to=name [-wait=d]] init|move|status")
+	}
+
+	config, err := conf.LoadConfig(*confPath)
+	if err != nil {
+		log.Println("load config file error, err: ", err)
+		return err
+	}
+	if config.Sharding == nil || len(config.Sharding.Shards) == 0 {
+		return fmt.Errorf("sharding is not configured")
+	}
+	sqlMaster, err := openMysql(config.MysqlMaster)
+	if err != nil {
+		log.Println("init sqlDB master failed, err: ", err.Error())
+		return err
+	}
+	defer sqlMaster.Close()
+	sqlDialect, err := dialect.New(config.MysqlMaster.Driver)
+	if err != nil {
+		return err
+	}
+	shards, err := openShards(config)
+	if err != nil {
+		log.Println("init shards failed, err: ", err.Error())
+		return err
+	}
+	defer func() {
+		for _, shard := range shards {
+			_ = shard.Master.Close()
+			_ = shard.Slave.Close()
+		}
+	}()
+
+	lgr := logger.NewLogger()
+	dbMaster := &dao.DBMaster{DB: sqlMaster, Dialect: sqlDialect}
+	shardMap := dao.NewShardMap(dbMaster, shards, config.Sharding.RangeSize, newTimeouts(config.QueryTimeouts), lgr)
+	mover := dao.NewShardMover(shardMap, lgr)
+	ctx := context.Background()
+	switch command {
+	case "init":
+		if *shardName == "" {
+			return fmt.Errorf("-shard is required by init")
+		}
+		return mover.Init(ctx, *shardName)
+	case "move":
+		if *startId == 0 || *to == "" {
+			return fmt.Errorf("-start and -to are required by move")
+		}
+		if *wait <= 0 {
+			refresh := config.Sharding.RefreshInterval
+			if refresh <= 0 {
+				refresh = dao.SHARD_MAP_REFRESH_INTERVAL_DEFAULT
+			}
+			*wait = refresh * RESHARD_WAIT_REFRESHES
+		}
+		started := time.Now()
+		if err = mover.Move(ctx, *startId, *to, *wait); err != nil {
+			return err
+		}
+		log.Printf("range %v moved to %v in %v", *startId, *to, time.Since(started))
+		return nil
+	default:
+		if err = shardMap.Load(ctx); err != nil {
+			return err
+		}
+		for _, r := range shardMap.Ranges() {
+			log.Printf("[%v, %v) %v %v, allocated: %v", r.StartId, r.EndId, r.Shard, r.State, r.NextId-r.StartId)
+		}
+		return nil
+	}
+}
@@ -1,22 +1,42 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/asim/go-micro/plugins/registry/etcd/v3"
 	"github.com/asim/go-micro/v3"
 	"github.com/asim/go-micro/v3/registry"
-	_ "github.com/jinzhu/gorm/dialects/mysql"
 	"github.com/redis/go-redis/v9"
 	"log"
 	"loggers"
 	"protos/userinfo"
+	"time"
+	// embed the tz database, timezones are validated against it even if the image has no zoneinfo.
+	_ "time/tzdata"
+	"user-server/cache"
 	"user-server/conf"
 	"user-server/dao"
+	"user-server/dialect"
+	"user-server/handler"
+	"user-server/migration"
+	"user-server/model"
+	"user-server/service/outbox"
 	"user-server/wire"
 )
 
+const (
+	LOCAL_CACHE_RESUBSCRIBE_INTERVAL = time.Second
+	CACHE_STATS_LOG_INTERVAL         = time.Minute
+
+	MYSQL_CONNECT_ATTEMPTS    = 5
+	MYSQL_CONNECT_BACKOFF     = time.Second
+	MYSQL_CONNECT_BACKOFF_MAX = time.Second * 8
+	MYSQL_PING_TIMEOUT        = time.Second * 3
+)
+
 type Server struct {
 	service micro.Service
 }
@@ -35,7 +55,6 @@ func (s *Server) Init() error {
 	log.Println("config file loaded, config: ", config)
 
 	mysqlMasterConf := config.MysqlMaster
-	mysqlSlaveConf := config.MysqlSlave
 	etcdConf := config.Etcd
 	microConf := config.Micro
 	redisConf := config.Redis
@@ -51,31 +70,120 @@ func (s *Server) Init() error {
 	)
 
 	// 3. init basic dependencies.
-	sqlMaster, err := sql.Open(mysqlMasterConf.Driver, fmt.Sprintf("%v:%v@tcp(%v:%v)/%v", mysqlMasterConf.Name,
-		mysqlMasterConf.Password, mysqlMasterConf.Host, mysqlMasterConf.Port, mysqlMasterConf.DB))
+	sqlMaster, err := openMysql(mysqlMasterConf)
 	if err != nil {
 		log.Println("init sqlDB master failed, err: ", err.Error())
+		return err
+	}
+	sqlDialect, err := dialect.New(mysqlMasterConf.Driver)
+	if err != nil {
+		return err
+	}
+	if config.MigrateOnStartup {
+		if err = migrateUp(sqlMaster, sqlDialect, "sqlDB master"); err != nil {
+			return err
+		}
 	}
 
-	sqlSlave, err := sql.Open(mysqlSlaveConf.Driver, fmt.Sprintf("%v:%v@tcp(%v:%v)/%v", mysqlSlaveConf.Name,
-		mysqlSlaveConf.Password, mysqlSlaveConf.Host, mysqlSlaveConf.Port, mysqlSlaveConf.DB))
+	dbSlave, err := openReplicas(config)
 	if err != nil {
 		log.Println("init sqlDB slave failed, err: ", err.Error())
+		return err
 	}
 
-	rdb := redis.NewClusterClient(&redis.ClusterOptions{
-		Addrs: redisConf.Addrs,
-	})
+	dbCache, err := newCache(redisConf)
+	if err != nil {
+		log.Println("init cache failed, err: ", err.Error())
+		return err
+	}
 
 	lgr := logger.NewLogger()
 
+	cachePolicy := newCachePolicy(config.Cache)
+	timeouts := newTimeouts(config.QueryTimeouts)
+	loader := cache.NewLoader(dbCache, redisConf.LoadLock)
+	local := newLocalCache(dbCache, config.LocalCache)
+	if local != nil {
+		go runLocalCache(local)
+	}
+
+	attributeRegistry, err := newAttributeRegistry(config.ProfileAttributes)
+	if err != nil {
+		log.Println("init attribute registry failed, err: ", err.Error())
+		return err
+	}
+
 	// 4. injection.
-	userinfoHandler := wire.InitUserinfoHandler(
-		&dao.DBMaster{DB: sqlMaster},
-		&dao.DBSlave{DB: sqlSlave},
-		rdb,
-		lgr,
-	)
+	ctx := context.Background()
+	usernamePolicy := newUsernamePolicy(config.Username)
+	dbMaster := &dao.DBMaster{DB: sqlMaster, Dialect: sqlDialect}
+	router := newDBRouter(dbMaster, dbSlave, dbCache, config.ReadRouting, lgr)
+	var userinfoHandler *handler.UserinfoHandlerImpl
+	var shards []*dao.Shard
+	cacheOutboxRelays := make(map[string]*outbox.CacheOutboxRelay)
+	if sharding := config.Sharding; sharding != nil && len(sharding.Shards) > 0 {
+		shards, err = openShards(config)
+		if err != nil {
+			log.Println("init shards failed, err: ", err.Error())
+			return err
+		}
+		for _, shard := range shards {
+			if config.MigrateOnStartup {
+				if err = migrateUp(shard.Master.DB, shard.Master.Dialect, "shard "+shard.Name); err != nil {
+					return err
+				}
+			}
+			shard.Router = newDBRouter(shard.Master, shard.Slave, dbCache, config.ReadRouting, lgr)
+			cacheOutboxRelays[shard.Name] = wire.InitCacheOutboxRelay(shard.Master, dbCache, local, cachePolicy, timeouts, lgr)
+		}
+		shardMap := dao.NewShardMap(dbMaster, shards, sharding.RangeSize, timeouts, lgr)
+		if err = dao.NewShardMover(shardMap, lgr).Verify(ctx); err != nil {
+			log.Println("verify shards failed, run userinfo reshard init first. err: ", err.Error())
+			return err
+		}
+		if err = shardMap.Load(ctx); err != nil {
+			log.Println("load shard ranges failed, err: ", err.Error())
+			return err
+		}
+		go shardMap.Run(ctx, sharding.RefreshInterval)
+		userinfoHandler = wire.InitShardedUserinfoHandler(
+			shardMap,
+			dbMaster,
+			dbSlave,
+			router,
+			dbCache,
+			loader,
+			local,
+			cachePolicy,
+			timeouts,
+			attributeRegistry,
+			usernamePolicy,
+			lgr,
+		)
+	} else {
+		userinfoHandler = wire.InitUserinfoHandler(
+			dbMaster,
+			dbSlave,
+			router,
+			dbCache,
+			loader,
+			local,
+			cachePolicy,
+			timeouts,
+			attributeRegistry,
+			usernamePolicy,
+			lgr,
+		)
+		cacheOutboxRelays[""] = wire.InitCacheOutboxRelay(dbMaster, dbCache, local, cachePolicy, timeouts, lgr)
+	}
+
+	for _, cacheOutboxRelay := range cacheOutboxRelays {
+		go cacheOutboxRelay.Run(ctx)
+	}
+	go logCacheStats(loader, local, cacheOutboxRelays)
+	if config.Admin != nil && config.Admin.Addr != "" {
+		go serveAdmin(config.Admin.Addr, dbMaster, dbSlave, shards)
+	}
 
 	// 5. init service
 	s.service.Init()
@@ -95,3 +203,336 @@ func (s *Server) Run() error {
 	}
 	return nil
 }
+
+func newTimeouts(queryTimeouts *conf.QueryTimeouts) *dao.Timeouts {
+	if queryTimeouts == nil {
+		queryTimeouts = &conf.QueryTimeouts{}
+	}
+	return dao.NewTimeouts(queryTimeouts.Read, queryTimeouts.Write, queryTimeouts.Operations)
+}
+
+// newDBRouter builds the router of reads and starts to check the replication lag of mysql-slave.
+func newDBRouter(dbMaster *dao.DBMaster, dbSlave *dao.DBSlave, dbCache cache.Cache, readRouting *conf.ReadRouting,
+	lgr *logger.Logger) *dao.DBRouter {
+	if readRouting == nil {
+		readRouting = &conf.ReadRouting{}
+	}
+	router := dao.NewDBRouter(dbMaster, dbSlave, dbCache, readRouting.PinWindow, readRouting.MaxLag, lgr)
+	go router.Run(context.Background(), readRouting.CheckInterval)
+	return router
+}
+
+func newAttributeRegistry(attributes []*conf.ProfileAttribute) (*model.AttributeRegistry, error) {
+	definitions := make([]*model.AttributeDefinition, 0, len(attributes))
+	for _, a := range attributes {
+		definitions = append(definitions, &model.AttributeDefinition{
+			Name:       a.Name,
+			Type:       a.Type,
+			MaxLength:  a.MaxLength,
+			Pattern:    a.Pattern,
+			Min:        a.Min,
+			Max:        a.Max,
+			Enum:       a.Enum,
+			Visibility: a.Visibility,
+		})
+	}
+	return model.NewAttributeRegistry(definitions)
+}
+
+// newUsernamePolicy applies the default limits to those not configured.
+func newUsernamePolicy(username *conf.Username) *model.UsernamePolicy {
+	policy := &model.UsernamePolicy{
+		ChangeCooldown: model.USERNAME_CHANGE_COOLDOWN_DEFAULT,
+		ReservePeriod:  model.USERNAME_RESERVE_PERIOD_DEFAULT,
+	}
+	if username == nil {
+		return policy
+	}
+	if username.ChangeCooldown > 0 {
+		policy.ChangeCooldown = username.ChangeCooldown
+	}
+	if username.ReservePeriod > 0 {
+		policy.ReservePeriod = username.ReservePeriod
+	}
+	return policy
+}
+
+// openMysql opens the pool of mysqlConf and pings it, see pingMysql.
+func openMysql(mysqlConf *conf.Mysql) (*sql.DB, error) {
+	db, err := newMysqlPool(mysqlConf)
+	if err != nil {
+		return nil, err
+	}
+	if err = pingMysql(db, mysqlConf); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// newMysqlPool opens the pool of mysqlConf with the dialect of its driver without connecting. Pool settings not
+// configured keep the defaults of database/sql.
+func newMysqlPool(mysqlConf *conf.Mysql) (*sql.DB, error) {
+	if mysqlConf == nil {
+		return nil, fmt.Errorf("mysql is not configured")
+	}
+	sqlDialect, err := dialect.New(mysqlConf.Driver)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(sqlDialect.DriverName(), sqlDialect.DSN(mysqlConf.Name, mysqlConf.Password, mysqlConf.Host,
+		mysqlConf.Port, mysqlConf.DB))
+	if err != nil {
+		return nil, err
+	}
+	if mysqlConf.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(mysqlConf.MaxOpenConns)
+	}
+	if mysqlConf.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(mysqlConf.MaxIdleConns)
+	}
+	if mysqlConf.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(mysqlConf.ConnMaxLifetime)
+	}
+	if mysqlConf.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(mysqlConf.ConnMaxIdleTime)
+	}
+	return db, nil
+}
+
+// pingMysql pings db, it retries with backoff for MYSQL_CONNECT_ATTEMPTS times in case mysql is still starting.
+func pingMysql(db *sql.DB, mysqlConf *conf.Mysql) error {
+	backoff := MYSQL_CONNECT_BACKOFF
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), MYSQL_PING_TIMEOUT)
+		err := db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt == MYSQL_CONNECT_ATTEMPTS {
+			return fmt.Errorf("ping mysql %v:%v: %w", mysqlConf.Host, mysqlConf.Port, err)
+		}
+		log.Printf("ping mysql %v:%v failed, retry in %v, err: %v", mysqlConf.Host, mysqlConf.Port, backoff, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, MYSQL_CONNECT_BACKOFF_MAX)
+	}
+}
+
+// openReplicas opens the replicas in mysql-slaves, or mysql-slave if there is no mysql-slaves, see openReplicaSet.
+func openReplicas(config *conf.Config) (*dao.DBSlave, error) {
+	slaves := config.MysqlSlaves
+	if len(slaves) == 0 && config.MysqlSlave != nil {
+		slaves = []*conf.Mysql{config.MysqlSlave}
+	}
+	return openReplicaSet(config.MysqlMaster, slaves)
+}
+
+// openReplicaSet opens the replicas of master. The replicas which can not be reached start ejected, and they are
+// readmitted by DBRouter once they are up. It fails if none is up.
+func openReplicaSet(master *conf.Mysql, slaves []*conf.Mysql) (*dao.DBSlave, error) {
+	if len(slaves) == 0 {
+		return nil, fmt.Errorf("mysql-slaves is not configured")
+	}
+	replicas := make([]*dao.Replica, 0, len(slaves))
+	var errs []error
+	for _, slave := range slaves {
+		if master != nil && slave.Driver != master.Driver {
+			_ = dao.NewDBSlave(replicas...).Close()
+			return nil, fmt.Errorf("driver %v of replica %v:%v differs from %v of master", slave.Driver, slave.Host,
+				slave.Port, master.Driver)
+		}
+		db, err := newMysqlPool(slave)
+		if err != nil {
+			_ = dao.NewDBSlave(replicas...).Close()
+			return nil, err
+		}
+		replica := dao.NewReplica(db, fmt.Sprintf("%v:%v", slave.Host, slave.Port), slave.Weight)
+		if err = pingMysql(db, slave); err != nil {
+			log.Println("replica is not up, it starts ejected. err: ", err.Error())
+			replica.Eject()
+			errs = append(errs, err)
+		}
+		replicas = append(replicas, replica)
+	}
+	dbSlave := dao.NewDBSlave(replicas...)
+	if len(errs) == len(replicas) {
+		_ = dbSlave.Close()
+		return nil, errors.Join(errs...)
+	}
+	return dbSlave, nil
+}
+
+// openShards opens the master and replicas of each shard in sharding, the routers of the shards are left to the
+// caller. The shards must use the driver of mysql-master, since users are copied between them.
+func openShards(config *conf.Config) ([]*dao.Shard, error) {
+	shards := make([]*dao.Shard, 0, len(config.Sharding.Shards))
+	names := make(map[string]bool, len(config.Sharding.Shards))
+	for _, shardConf := range config.Sharding.Shards {
+		if shardConf.Name == "" || names[shardConf.Name] {
+			return nil, fmt.Errorf("shard name %q is empty or duplicated", shardConf.Name)
+		}
+		names[shardConf.Name] = true
+		if shardConf.MysqlMaster == nil || shardConf.MysqlMaster.Driver != config.MysqlMaster.Driver {
+			return nil, fmt.Errorf("mysql-master of shard %v is not configured with driver %v", shardConf.Name,
+				config.MysqlMaster.Driver)
+		}
+		sqlMaster, err := openMysql(shardConf.MysqlMaster)
+		if err != nil {
+			return nil, fmt.Errorf("open master of shard %v: %w", shardConf.Name, err)
+		}
+		sqlDialect, err := dialect.New(shardConf.MysqlMaster.Driver)
+		if err != nil {
+			return nil, err
+		}
+		dbSlave, err := openReplicaSet(shardConf.MysqlMaster, shardConf.MysqlSlaves)
+		if err != nil {
+			return nil, fmt.Errorf("open replicas of shard %v: %w", shardConf.Name, err)
+		}
+		shards = append(shards, &dao.Shard{
+			Name:   shardConf.Name,
+			Master: &dao.DBMaster{DB: sqlMaster, Dialect: sqlDialect},
+			Slave:  dbSlave,
+		})
+	}
+	return shards, nil
+}
+
+// findShardConf returns the shard of the name in sharding.
+func findShardConf(config *conf.Config, name string) (*conf.Shard, error) {
+	if config.Sharding != nil {
+		for _, shardConf := range config.Sharding.Shards {
+			if shardConf.Name == name {
+				return shardConf, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %v", dao.ErrUnknownShard, name)
+}
+
+// migrateUp applies the pending migrations to db, name tells the database in the logs.
+func migrateUp(db *sql.DB, sqlDialect dialect.Dialect, name string) error {
+	migrator, err := migration.NewMigrator(db, sqlDialect)
+	if err != nil {
+		log.Println("load migrations failed, err: ", err.Error())
+		return err
+	}
+	migrations, err := migrator.Up(context.Background())
+	if err != nil {
+		log.Printf("migrate %v failed, err: %v", name, err.Error())
+		return err
+	}
+	log.Printf("%v migrated, applied migrations: %v", name, len(migrations))
+	return nil
+}
+
+// newCache creates the cache in the configured mode. Redis cluster is used if no mode is configured.
+func newCache(redisConf *conf.Redis) (cache.Cache, error) {
+	if redisConf == nil {
+		return nil, fmt.Errorf("redis is not configured")
+	}
+	switch redisConf.Mode {
+	case "", cache.MODE_CLUSTER:
+		return cache.NewRedisCache(redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    redisConf.Addrs,
+			Password: redisConf.Password,
+		})), nil
+	case cache.MODE_STANDALONE:
+		if len(redisConf.Addrs) == 0 {
+			return nil, fmt.Errorf("redis addr is not configured")
+		}
+		return cache.NewRedisCache(redis.NewClient(&redis.Options{
+			Addr:     redisConf.Addrs[0],
+			Password: redisConf.Password,
+			DB:       redisConf.DB,
+		})), nil
+	case cache.MODE_SENTINEL:
+		if redisConf.MasterName == "" {
+			return nil, fmt.Errorf("redis master-name is not configured")
+		}
+		return cache.NewRedisCache(redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    redisConf.MasterName,
+			SentinelAddrs: redisConf.Addrs,
+			Password:      redisConf.Password,
+			DB:            redisConf.DB,
+		})), nil
+	case cache.MODE_MEMORY:
+		return cache.NewMemoryCache(), nil
+	default:
+		return nil, fmt.Errorf("unknown redis mode %v", redisConf.Mode)
+	}
+}
+
+// newCachePolicy applies the default policy to the entities not configured.
+func newCachePolicy(cacheConf *conf.Cache) *cache.Policy {
+	policy := cache.NewPolicy()
+	if cacheConf == nil {
+		return policy
+	}
+	if cacheConf.SchemaVersion > 0 {
+		policy.SchemaVersion = cacheConf.SchemaVersion
+	}
+	applyCacheEntity(policy.Profile, cacheConf.Profile)
+	applyCacheEntity(policy.Username, cacheConf.Username)
+	applyCacheEntity(policy.RelationCounts, cacheConf.RelationCounts)
+	if cacheConf.UserIdFilterMax > 0 {
+		policy.UserIdFilterMax = min(cacheConf.UserIdFilterMax, cache.POLICY_USER_ID_FILTER_MAX_LIMIT)
+	}
+	return policy
+}
+
+func applyCacheEntity(entity *cache.EntityPolicy, entityConf *conf.CacheEntity) {
+	if entityConf == nil {
+		return
+	}
+	entity.Enabled = !entityConf.Disabled
+	if entityConf.TTL > 0 {
+		entity.TTL = entityConf.TTL
+	}
+	if entityConf.Jitter > 0 {
+		entity.Jitter = entityConf.Jitter
+	}
+}
+
+// newLocalCache returns nil if the local cache is not configured.
+func newLocalCache(dbCache cache.Cache, localConf *conf.LocalCache) *cache.Local {
+	if localConf == nil || localConf.Size <= 0 {
+		return nil
+	}
+	return cache.NewLocal(dbCache, dao.REDIS_CHANNEL_INVALIDATE_PROFILE, localConf.Size, localConf.Expiration)
+}
+
+// runLocalCache keeps receiving invalidations of the local cache from other instances.
+func runLocalCache(local *cache.Local) {
+	for {
+		err := local.Run(context.Background())
+		log.Println("receive local cache invalidations failed, err: ", err)
+		time.Sleep(LOCAL_CACHE_RESUBSCRIBE_INTERVAL)
+	}
+}
+
+// logCacheStats logs the statistics of the caches, and of the cache outbox of each shard, which is named "" if
+// not sharded.
+func logCacheStats(loader *cache.Loader, local *cache.Local, cacheOutboxRelays map[string]*outbox.CacheOutboxRelay) {
+	for range time.Tick(CACHE_STATS_LOG_INTERVAL) {
+		localStats, redisStats := local.Stats(), loader.Stats()
+		log.Printf("cache stats, local hit ratio: %.3f %+v, redis hit ratio: %.3f %+v",
+			localStats.HitRatio(), localStats, redisStats.HitRatio(), redisStats)
+		for shard, cacheOutboxRelay := range cacheOutboxRelays {
+			outboxStats, err := cacheOutboxRelay.Stats(context.Background())
+			if err != nil {
+				log.Println("get cache outbox stats failed, err: ", err.Error())
+				continue
+			}
+			log.Printf("cache outbox stats%v, pending: %v, lag: %v, relayed: %v, failed: %v", shardLabel(shard),
+				outboxStats.Pending, outboxStats.Lag, outboxStats.Relayed, outboxStats.Failed)
+		}
+	}
+}
+
+func shardLabel(shard string) string {
+	if shard == "" {
+		return ""
+	}
+	return " of shard " + shard
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 	"user-server/dao"
 	"user-server/model"
+	"user-server/service/profile"
 )
 
 const (
@@ -24,19 +25,36 @@ type UserClaim struct {
 }
 
 type AccountService struct {
-	userDao *dao.UserDao
-	logger  *logger.Logger
+	userDao        dao.UserRepository
+	profileService *profile.ProfileService
+	logger         *logger.Logger
 }
 
-func NewAccountService(userDao *dao.UserDao, logger *logger.Logger) *AccountService {
+func NewAccountService(userDao dao.UserRepository, profileService *profile.ProfileService, logger *logger.Logger) *AccountService {
 	return &AccountService{
-		userDao: userDao,
-		logger:  logger,
+		userDao:        userDao,
+		profileService: profileService,
+		logger:         logger,
 	}
 }
 
-func (s *AccountService) Register(ctx context.Context, email string, password string) error {
+// Register creates the user and its default profile atomically. Fields of the profile are optional,
+// and the locale is derived from acceptLanguage if it is not given.
+func (s *AccountService) Register(ctx context.Context, email string, password string, p *model.Profile, acceptLanguage string) error {
 	s.logger.Info(ctx, "Call AccountService.Register, email: ", email)
+	p.Email = email
+	if p.Locale == "" {
+		p.Locale = model.LocaleFromAcceptLanguage(acceptLanguage)
+	}
+	violations := s.profileService.ValidateProfile(p)
+	if len(violations) > 0 {
+		s.logger.Error(ctx, "Invalid profile, violations: ", violations)
+		return errs.NewWithViolations(errs.ERR_INVALID_PROFILE, violations)
+	}
+	if err := s.profileService.CheckUsernameAvailable(ctx, 0, p.Username, errs.ERR_REGISTER_INTERNAL); err != nil {
+		return err
+	}
+
 	// 1. check whether email has been registered.
 	user, err := s.userDao.GetUserByEmail(ctx, email)
 	//   1.1 if user exists, return error.
@@ -47,19 +65,25 @@ func (s *AccountService) Register(ctx context.Context, email string, password st
 	//   1.2 if err is sql DB internal error, return error.
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		s.logger.Error(ctx, "sql DB internal error, err: ", err.Error())
-		return errs.New(errs.ERR_REGISTER_INTERNAL)
+		return errs.NewFromErr(errs.ERR_REGISTER_INTERNAL, err)
 	}
 
-	// 2. save email and password.
+	// 2. save email and password, together with the default profile.
 	user = &model.User{
 		Password: password,
 		Email:    email,
 	}
-	err = s.userDao.Insert(ctx, user)
+	userId, err := s.userDao.InsertWithProfile(ctx, user, p)
+	if errors.Is(err, dao.ErrUsernameTaken) {
+		// taken concurrently after the check above.
+		return errs.New(errs.ERR_USERNAME_TAKEN)
+	}
 	if err != nil {
 		s.logger.Error(ctx, "Insert user failed, err: ", err.Error())
-		return errs.New(errs.ERR_REGISTER_INTERNAL)
+		return errs.NewFromErr(errs.ERR_REGISTER_INTERNAL, err)
 	}
+	s.profileService.MarkProfileExists(ctx, userId)
+	s.logger.Info(ctx, "Register succeed, user_id: ", userId)
 	return nil
 }
 
@@ -72,7 +96,7 @@ func (s *AccountService) Login(ctx context.Context, email string, password strin
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, errs.New(errs.ERR_LOGIN_NO_USER)
 		} else {
-			return nil, errs.New(errs.ERR_LOGIN_INTERNAL)
+			return nil, errs.NewFromErr(errs.ERR_LOGIN_INTERNAL, err)
 		}
 	}
 	if user.Password != password {
@@ -0,0 +1,111 @@
+package outbox
+
+import (
+	"context"
+	"loggers"
+	"sync/atomic"
+	"time"
+	"user-server/cache"
+	"user-server/dao"
+	"user-server/model"
+)
+
+const (
+	CACHE_OUTBOX_POLL_INTERVAL = time.Millisecond * 200
+	CACHE_OUTBOX_BATCH_SIZE    = 100
+	// CACHE_OUTBOX_DOUBLE_DELETE_DELAY should be longer than the replication lag of mysql-slave.
+	CACHE_OUTBOX_DOUBLE_DELETE_DELAY = time.Second
+	CACHE_OUTBOX_RETRY_BASE          = time.Second
+	CACHE_OUTBOX_RETRY_MAX           = time.Minute
+)
+
+// CacheOutboxRelay deletes the cache keys written to the outbox with the changes of the cached data. A key is deleted
+// once the change is committed and again after the replication lag, and failed deletions are retried with backoff,
+// so the cache converges even if the deletion right after the change fails or races with a read of the slave.
+type CacheOutboxRelay struct {
+	cacheOutboxDao *dao.CacheOutboxDao
+	dbCache        cache.Cache
+	local          *cache.Local
+	policy         *cache.Policy
+	logger         *logger.Logger
+
+	relayed atomic.Uint64
+	failed  atomic.Uint64
+}
+
+func NewCacheOutboxRelay(cacheOutboxDao *dao.CacheOutboxDao, dbCache cache.Cache, local *cache.Local, policy *cache.Policy,
+	logger *logger.Logger) *CacheOutboxRelay {
+	return &CacheOutboxRelay{
+		cacheOutboxDao: cacheOutboxDao,
+		dbCache:        dbCache,
+		local:          local,
+		policy:         policy,
+		logger:         logger,
+	}
+}
+
+// Run relays the outbox until ctx is done.
+func (r *CacheOutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(CACHE_OUTBOX_POLL_INTERVAL)
+	defer ticker.Stop()
+	for {
+		n, _ := r.RelayOnce(ctx)
+		// keep going without waiting if there may be more due entries.
+		if n == CACHE_OUTBOX_BATCH_SIZE {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce relays a batch of due entries, and returns the number of them.
+func (r *CacheOutboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	return r.cacheOutboxDao.RelayDue(ctx, CACHE_OUTBOX_BATCH_SIZE, func(entry *model.CacheOutboxEntry) (bool, time.Duration) {
+		// keys are written to the outbox without the schema version.
+		key := r.policy.Key(entry.CacheKey)
+		err := r.dbCache.Del(ctx, key)
+		if err == nil {
+			err = r.local.Invalidate(ctx, key)
+		}
+		if err != nil {
+			r.failed.Add(1)
+			r.logger.Error(ctx, "Fail to delete cache key from outbox, key: ", key, ", err: ", err.Error())
+			entry.Attempts++
+			return false, retryAfter(entry.Attempts)
+		}
+		r.relayed.Add(1)
+		if entry.Stage == model.CACHE_OUTBOX_STAGE_DELETE {
+			entry.Stage = model.CACHE_OUTBOX_STAGE_DOUBLE_DELETE
+			entry.Attempts = 0
+			return false, CACHE_OUTBOX_DOUBLE_DELETE_DELAY
+		}
+		return true, 0
+	})
+}
+
+// Stats returns the lag of the outbox and the deletions since the relay is started.
+func (r *CacheOutboxRelay) Stats(ctx context.Context) (*model.CacheOutboxStats, error) {
+	pending, lag, err := r.cacheOutboxDao.GetLag(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CacheOutboxStats{
+		Pending: pending,
+		Lag:     lag,
+		Relayed: r.relayed.Load(),
+		Failed:  r.failed.Load(),
+	}, nil
+}
+
+// retryAfter doubles the delay with each failed attempt up to CACHE_OUTBOX_RETRY_MAX.
+func retryAfter(attempts int) time.Duration {
+	delay := CACHE_OUTBOX_RETRY_BASE
+	for i := 1; i < attempts && delay < CACHE_OUTBOX_RETRY_MAX; i++ {
+		delay *= 2
+	}
+	return min(delay, CACHE_OUTBOX_RETRY_MAX)
+}
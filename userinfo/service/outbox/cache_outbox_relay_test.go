@@ -0,0 +1,141 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"loggers"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"user-server/cache"
+	"user-server/dao"
+	"user-server/dialect"
+	"user-server/migration"
+	"user-server/model"
+)
+
+func TestRetryAfter(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  CACHE_OUTBOX_RETRY_BASE,
+		2:  CACHE_OUTBOX_RETRY_BASE * 2,
+		4:  CACHE_OUTBOX_RETRY_BASE * 8,
+		20: CACHE_OUTBOX_RETRY_MAX,
+	}
+	for attempts, expected := range cases {
+		if delay := retryAfter(attempts); delay != expected {
+			t.Errorf("expect %v after %v attempts, got %v", expected, attempts, delay)
+		}
+	}
+}
+
+// failingCache fails the deletions while fail is set, as an unreachable redis does.
+type failingCache struct {
+	cache.Cache
+	fail bool
+}
+
+func (c *failingCache) Del(ctx context.Context, keys ...string) error {
+	if c.fail {
+		return errors.New("cache unreachable")
+	}
+	return c.Cache.Del(ctx, keys...)
+}
+
+// newTestRelay creates the relay on a migrated sqlite database in a temporary directory, logging there as well.
+func newTestRelay(t *testing.T, dbCache cache.Cache, policy *cache.Policy) (*CacheOutboxRelay, *sql.DB) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("get working directory failed, err: %v", err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("change working directory failed, err: %v", err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+	lgr := logger.NewLogger()
+
+	sqlDialect := dialect.SQLite{}
+	db, err := sql.Open(sqlDialect.DriverName(), sqlDialect.DSN("", "", "", "", filepath.Join(t.TempDir(), "userinfo.db")))
+	if err != nil {
+		t.Fatalf("open sqlite failed, err: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	migrator, err := migration.NewMigrator(db, sqlDialect)
+	if err == nil {
+		_, err = migrator.Up(context.Background())
+	}
+	if err != nil {
+		t.Fatalf("migrate failed, err: %v", err)
+	}
+	cacheOutboxDao := dao.NewCacheOutboxDao(&dao.DBMaster{DB: db, Dialect: sqlDialect}, nil, lgr)
+	return NewCacheOutboxRelay(cacheOutboxDao, dbCache, nil, policy, lgr), db
+}
+
+func TestCacheOutboxRelay_RelayOnce(t *testing.T) {
+	ctx := context.Background()
+	dbCache := &failingCache{Cache: cache.NewMemoryCache()}
+	policy := cache.NewPolicy()
+	relay, db := newTestRelay(t, dbCache, policy)
+	if _, err := db.Exec(fmt.Sprintf("INSERT INTO %v (cache_key) VALUES (?)", dao.TAB_NAME_CACHE_OUTBOX), "k"); err != nil {
+		t.Fatalf("insert outbox failed, err: %v", err)
+	}
+	key := policy.Key("k")
+	entry := func() *model.CacheOutboxEntry {
+		e := &model.CacheOutboxEntry{}
+		err := db.QueryRow(fmt.Sprintf("SELECT id, stage, attempts FROM %v", dao.TAB_NAME_CACHE_OUTBOX)).Scan(&e.Id, &e.Stage, &e.Attempts)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			t.Fatalf("select outbox failed, err: %v", err)
+		}
+		return e
+	}
+	relayDue := func(expected int) {
+		t.Helper()
+		// the entry is made due at once instead of waiting for the delay.
+		if _, err := db.Exec(fmt.Sprintf("UPDATE %v SET next_attempt_at = create_time", dao.TAB_NAME_CACHE_OUTBOX)); err != nil {
+			t.Fatalf("update outbox failed, err: %v", err)
+		}
+		if n, err := relay.RelayOnce(ctx); err != nil || n != expected {
+			t.Fatalf("expect %v relayed, got %v, err: %v", expected, n, err)
+		}
+	}
+
+	// 1. the key is deleted, and deleted again after the delay.
+	_ = dbCache.Set(ctx, key, "v", time.Minute)
+	relayDue(1)
+	if _, err := dbCache.Get(ctx, key); !errors.Is(err, cache.ErrMiss) {
+		t.Errorf("expect key deleted, got %v", err)
+	}
+	if e := entry(); e == nil || e.Stage != model.CACHE_OUTBOX_STAGE_DOUBLE_DELETE || e.Attempts != 0 {
+		t.Fatalf("expect entry of the delayed delete, got %+v", e)
+	}
+	if n, err := relay.RelayOnce(ctx); err != nil || n != 0 {
+		t.Errorf("expect the delayed delete not due, got %v relayed, err: %v", n, err)
+	}
+
+	// 2. a failed deletion is retried.
+	_ = dbCache.Set(ctx, key, "stale", time.Minute)
+	dbCache.fail = true
+	relayDue(1)
+	if e := entry(); e == nil || e.Stage != model.CACHE_OUTBOX_STAGE_DOUBLE_DELETE || e.Attempts != 1 {
+		t.Fatalf("expect entry retried, got %+v", e)
+	}
+
+	// 3. the entry is done once the delayed delete succeeds.
+	dbCache.fail = false
+	relayDue(1)
+	if _, err := dbCache.Get(ctx, key); !errors.Is(err, cache.ErrMiss) {
+		t.Errorf("expect stale key deleted, got %v", err)
+	}
+	if e := entry(); e != nil {
+		t.Errorf("expect entry deleted, got %+v", e)
+	}
+	stats, err := relay.Stats(ctx)
+	if err != nil || stats.Pending != 0 || stats.Relayed != 2 || stats.Failed != 1 {
+		t.Errorf("expect 2 relayed and 1 failed, got %+v, err: %v", stats, err)
+	}
+}
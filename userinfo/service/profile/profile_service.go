@@ -2,21 +2,39 @@ package profile
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	errs "errs"
 	"loggers"
+	"time"
 	"user-server/dao"
 	"user-server/model"
 )
 
+const (
+	PROFILE_HISTORY_PAGE_SIZE_DEFAULT = 20
+	PROFILE_HISTORY_PAGE_SIZE_MAX     = 100
+
+	// deleted profiles can be restored within the window, unless the user creates a new one.
+	PROFILE_RESTORE_WINDOW = time.Hour * 24 * 30
+)
+
 type ProfileService struct {
-	profileDao *dao.ProfileDao
-	logger     *logger.Logger
+	profileDao        dao.ProfileRepository
+	profileHistoryDao dao.ProfileHistoryRepository
+	attributeRegistry *model.AttributeRegistry
+	usernamePolicy    *model.UsernamePolicy
+	logger            *logger.Logger
 }
 
-func NewProfileService(profileDao *dao.ProfileDao, logger *logger.Logger) *ProfileService {
+func NewProfileService(profileDao dao.ProfileRepository, profileHistoryDao dao.ProfileHistoryRepository, attributeRegistry *model.AttributeRegistry,
+	usernamePolicy *model.UsernamePolicy, logger *logger.Logger) *ProfileService {
 	return &ProfileService{
-		profileDao: profileDao,
-		logger:     logger,
+		profileDao:        profileDao,
+		profileHistoryDao: profileHistoryDao,
+		attributeRegistry: attributeRegistry,
+		usernamePolicy:    usernamePolicy,
+		logger:            logger,
 	}
 }
 
@@ -25,37 +43,232 @@ func (s *ProfileService) GetProfile(ctx context.Context, userId uint64) (*model.
 	profile, err := s.profileDao.GetProfileById(ctx, userId)
 	if err != nil {
 		s.logger.Error(ctx, "Fail to get profile, err:", err.Error())
-		return nil, errs.New(errs.ERR_GET_PROFILE_FAILED)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errs.New(errs.ERR_PROFILE_NOT_FOUND)
+		}
+		return nil, errs.NewFromErr(errs.ERR_GET_PROFILE_FAILED, err)
 	}
+	profile.Attributes = s.attributeRegistry.Normalize(profile.Attributes)
 	return profile, nil
 }
 
+// GetPublicProfile finds a profile by user id or username, and only returns the fields the viewer is permitted to see.
+// viewerId 0 means the viewer is not logged in. If username is a reserved previous username, the profile of its
+// previous owner is returned, together with the username it is redirected from.
+func (s *ProfileService) GetPublicProfile(ctx context.Context, userId uint64, username string, viewerId uint64) (*model.Profile, string, error) {
+	s.logger.Info(ctx, "Call ProfileService.GetPublicProfile, user_id: ", userId, ", username: ", username)
+	if userId == 0 && username == "" {
+		return nil, "", errs.New(errs.ERR_GET_PROFILE_REQUEST)
+	}
+	profile, redirected, err := s.getProfileByIdOrUsername(ctx, userId, username)
+	if err != nil {
+		s.logger.Error(ctx, "Fail to get profile, err:", err.Error())
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, "", errs.New(errs.ERR_PROFILE_NOT_FOUND)
+		}
+		return nil, "", errs.NewFromErr(errs.ERR_GET_PROFILE_FAILED, err)
+	}
+	redirectedFrom := ""
+	if redirected {
+		redirectedFrom = username
+	}
+	profile.Attributes = s.attributeRegistry.Normalize(profile.Attributes)
+	return profile.Project(model.Audience(profile.UserId, viewerId), s.attributeRegistry), redirectedFrom, nil
+}
+
+// getProfileByIdOrUsername returns the profile, and whether it is found by a previous username.
+func (s *ProfileService) getProfileByIdOrUsername(ctx context.Context, userId uint64, username string) (*model.Profile, bool, error) {
+	if userId != 0 {
+		profile, err := s.profileDao.GetProfileById(ctx, userId)
+		return profile, false, err
+	}
+	profile, err := s.getProfileByUsername(ctx, username)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return profile, false, err
+	}
+	// the username might be changed, redirect to its previous owner.
+	userId, err = s.profileDao.GetUserIdByPreviousUsername(ctx, username)
+	if err != nil {
+		return nil, false, err
+	}
+	profile, err = s.profileDao.GetProfileById(ctx, userId)
+	return profile, true, err
+}
+
+func (s *ProfileService) getProfileByUsername(ctx context.Context, username string) (*model.Profile, error) {
+	userId, err := s.profileDao.GetUserIdByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := s.profileDao.GetProfileById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Username == username {
+		return profile, nil
+	}
+	// the username has been changed since it was cached, look it up again.
+	s.logger.Warning(ctx, "Cached username is stale, username: ", username, ", user_id: ", userId)
+	s.profileDao.DeleteUsernameFromCache(ctx, username)
+	userId, err = s.profileDao.GetUserIdByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return s.profileDao.GetProfileById(ctx, userId)
+}
+
 func (s *ProfileService) UpdateProfile(ctx context.Context, userId uint64, profile *model.Profile) error {
 	s.logger.Info(ctx, "Call ProfileService.UpdateProfile")
+	violations := s.ValidateProfile(profile)
+	if len(violations) > 0 {
+		s.logger.Error(ctx, "Invalid profile, violations: ", violations)
+		return errs.NewWithViolations(errs.ERR_INVALID_PROFILE, violations)
+	}
+	if err := s.checkUsername(ctx, userId, profile.Username, errs.ERR_UPDATE_PROFILE_FAILED); err != nil {
+		return err
+	}
 	err := s.profileDao.Update(ctx, userId, profile)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errs.New(errs.ERR_PROFILE_NOT_FOUND)
+	}
+	if usernameErr := usernameWriteError(err); usernameErr != nil {
+		return usernameErr
+	}
 	if err != nil {
 		s.logger.Error(ctx, "Fail to update profile, err:", err.Error())
-		return errs.New(errs.ERR_UPDATE_PROFILE_FAILED)
+		return newWriteError(errs.ERR_UPDATE_PROFILE_FAILED, err)
 	}
 	return nil
 }
 
-func (s *ProfileService) DeleteProfile(ctx context.Context, userId uint64) error {
+// DeleteProfile soft deletes the profile, and returns the unix timestamp until which it can be restored.
+func (s *ProfileService) DeleteProfile(ctx context.Context, userId uint64) (int64, error) {
 	s.logger.Info(ctx, "Call ProfileService.DeleteProfile.")
 	err := s.profileDao.Delete(ctx, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, errs.New(errs.ERR_PROFILE_NOT_FOUND)
+	}
 	if err != nil {
 		s.logger.Error(ctx, "Fail to delete profile, err:", err.Error())
-		return errs.New(errs.ERR_DELETE_PROFILE_FAILED)
+		return 0, newWriteError(errs.ERR_DELETE_PROFILE_FAILED, err)
+	}
+	return time.Now().Add(PROFILE_RESTORE_WINDOW).Unix(), nil
+}
+
+// RestoreProfile restores the profile deleted within PROFILE_RESTORE_WINDOW.
+func (s *ProfileService) RestoreProfile(ctx context.Context, userId uint64) error {
+	s.logger.Info(ctx, "Call ProfileService.RestoreProfile.")
+	err := s.profileDao.Restore(ctx, userId, PROFILE_RESTORE_WINDOW)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errs.New(errs.ERR_PROFILE_NOT_FOUND)
+	}
+	if err != nil {
+		s.logger.Error(ctx, "Fail to restore profile, err:", err.Error())
+		return newWriteError(errs.ERR_RESTORE_PROFILE_FAILED, err)
 	}
 	return nil
 }
 
-func (s *ProfileService) CreateProfile(ctx context.Context, profile *model.Profile) error {
+// CreateProfile creates the profile of a user. If the user already has one, it is updated,
+// or ERR_PROFILE_EXISTS is returned when failIfExists is set.
+func (s *ProfileService) CreateProfile(ctx context.Context, profile *model.Profile, failIfExists bool) error {
 	s.logger.Info(ctx, "Call ProfileService.CreateProfile, profile: ", profile)
-	err := s.profileDao.Insert(ctx, profile)
+	violations := s.ValidateProfile(profile)
+	if len(violations) > 0 {
+		s.logger.Error(ctx, "Invalid profile, violations: ", violations)
+		return errs.NewWithViolations(errs.ERR_INVALID_PROFILE, violations)
+	}
+	if err := s.checkUsername(ctx, profile.UserId, profile.Username, errs.ERR_CREATE_PROFILE_FAILED); err != nil {
+		return err
+	}
+	// removing an attribute or resetting a visibility makes no sense when creating.
+	for name, value := range profile.Attributes {
+		if value == nil {
+			delete(profile.Attributes, name)
+		}
+	}
+	for field, visibility := range profile.Privacy {
+		if visibility == "" {
+			delete(profile.Privacy, field)
+		}
+	}
+	if failIfExists {
+		err := s.profileDao.Insert(ctx, profile)
+		if errors.Is(err, dao.ErrProfileExists) {
+			return errs.New(errs.ERR_PROFILE_EXISTS)
+		}
+		if usernameErr := usernameWriteError(err); usernameErr != nil {
+			return usernameErr
+		}
+		if err != nil {
+			s.logger.Error(ctx, "Fail to create profile, err:", err.Error())
+			return newWriteError(errs.ERR_CREATE_PROFILE_FAILED, err)
+		}
+		return nil
+	}
+	// creating is idempotent by default, the profile created at registration is updated instead.
+	err := s.profileDao.Upsert(ctx, profile)
+	if usernameErr := usernameWriteError(err); usernameErr != nil {
+		return usernameErr
+	}
 	if err != nil {
-		s.logger.Error(ctx, "Fail to delete profile, err:", err.Error())
-		return errs.New(errs.ERR_CREATE_PROFILE_FAILED)
+		s.logger.Error(ctx, "Fail to create profile, err:", err.Error())
+		return newWriteError(errs.ERR_CREATE_PROFILE_FAILED, err)
 	}
 	return nil
 }
+
+// newWriteError returns ERR_SHARD_RANGE_MOVING while the user is being moved to another shard, which the caller
+// can retry after the move, or the error of code otherwise.
+func newWriteError(code int32, err error) error {
+	if errors.Is(err, dao.ErrShardRangeMoving) {
+		return errs.New(errs.ERR_SHARD_RANGE_MOVING)
+	}
+	return errs.NewFromErr(code, err)
+}
+
+// MarkProfileExists makes the profile created outside of the service visible to reads, e.g. at registration.
+func (s *ProfileService) MarkProfileExists(ctx context.Context, userId uint64) {
+	s.profileDao.MarkProfileExists(ctx, userId)
+}
+
+// GetProfileHistory returns a page of profile changes, newest first.
+// The returned version is the cursor for the next page, 0 means there are no more histories.
+func (s *ProfileService) GetProfileHistory(ctx context.Context, userId uint64, beforeVersion uint64, limit int) ([]*model.ProfileHistory, uint64, error) {
+	s.logger.Info(ctx, "Call ProfileService.GetProfileHistory")
+	if limit <= 0 {
+		limit = PROFILE_HISTORY_PAGE_SIZE_DEFAULT
+	}
+	if limit > PROFILE_HISTORY_PAGE_SIZE_MAX {
+		limit = PROFILE_HISTORY_PAGE_SIZE_MAX
+	}
+	histories, err := s.profileHistoryDao.List(ctx, userId, beforeVersion, limit)
+	if err != nil {
+		s.logger.Error(ctx, "Fail to get profile history, err:", err.Error())
+		return nil, 0, errs.NewFromErr(errs.ERR_GET_PROFILE_HISTORY_FAILED, err)
+	}
+	var next uint64
+	if len(histories) == limit && histories[len(histories)-1].Version > 1 {
+		next = histories[len(histories)-1].Version
+	}
+	return histories, next, nil
+}
+
+// GetProfileAt returns the profile as it was at timestamp, with the version of it.
+func (s *ProfileService) GetProfileAt(ctx context.Context, userId uint64, timestamp int64) (*model.Profile, uint64, error) {
+	s.logger.Info(ctx, "Call ProfileService.GetProfileAt, timestamp: ", timestamp)
+	history, err := s.profileHistoryDao.GetAt(ctx, userId, timestamp)
+	if err != nil {
+		s.logger.Error(ctx, "Fail to get profile history, err:", err.Error())
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, 0, errs.New(errs.ERR_PROFILE_NOT_FOUND)
+		}
+		return nil, 0, errs.NewFromErr(errs.ERR_GET_PROFILE_HISTORY_FAILED, err)
+	}
+	// the profile had been deleted at that time.
+	if history.Snapshot == nil {
+		return nil, history.Version, errs.New(errs.ERR_PROFILE_NOT_FOUND)
+	}
+	history.Snapshot.Attributes = s.attributeRegistry.Normalize(history.Snapshot.Attributes)
+	return history.Snapshot, history.Version, nil
+}
@@ -0,0 +1,91 @@
+package profile
+
+import (
+	errs "errs"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"time"
+	"unicode/utf8"
+	"user-server/model"
+)
+
+const (
+	USERNAME_MIN_LENGTH   = 3
+	USERNAME_MAX_LENGTH   = 32
+	AVATAR_URL_MAX_LENGTH = 255
+)
+
+var (
+	usernamePattern  = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	earliestBirthday = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// ValidateProfile checks the fields set in profile and returns all the violations.
+// Empty fields are not set, so they are not validated. A valid locale is converted to its canonical form.
+func (s *ProfileService) ValidateProfile(profile *model.Profile) []*errs.Violation {
+	violations := make([]*errs.Violation, 0)
+	violate := func(field string, reason string) {
+		violations = append(violations, &errs.Violation{Field: field, Reason: reason})
+	}
+
+	if profile.Username != "" {
+		length := utf8.RuneCountInString(profile.Username)
+		if length < USERNAME_MIN_LENGTH || length > USERNAME_MAX_LENGTH {
+			violate("username", fmt.Sprintf("length should be between %v and %v", USERNAME_MIN_LENGTH, USERNAME_MAX_LENGTH))
+		} else if !usernamePattern.MatchString(profile.Username) {
+			violate("username", "only letters, digits, '_', '.' and '-' are allowed")
+		}
+	}
+
+	if profile.Birthday != "" {
+		birthday, err := time.Parse(model.BIRTHDAY_LAYOUT, profile.Birthday)
+		if err != nil {
+			violate("birthday", fmt.Sprintf("should be in the format of %v", model.BIRTHDAY_LAYOUT))
+		} else if birthday.After(time.Now().UTC()) {
+			violate("birthday", "should not be in the future")
+		} else if birthday.Before(earliestBirthday) {
+			violate("birthday", fmt.Sprintf("should not be earlier than %v", earliestBirthday.Format(model.BIRTHDAY_LAYOUT)))
+		}
+	}
+
+	if profile.AvatarUrl != "" {
+		u, err := url.Parse(profile.AvatarUrl)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			violate("avatar", "should be an absolute http or https url")
+		} else if len(profile.AvatarUrl) > AVATAR_URL_MAX_LENGTH {
+			violate("avatar", fmt.Sprintf("should not be longer than %v", AVATAR_URL_MAX_LENGTH))
+		}
+	}
+
+	if profile.Locale != "" {
+		locale, err := model.CanonicalLocale(profile.Locale)
+		if err != nil {
+			violate("locale", err.Error())
+		} else {
+			profile.Locale = locale
+		}
+	}
+
+	if profile.Timezone != "" {
+		if err := model.CheckTimezone(profile.Timezone); err != nil {
+			violate("timezone", err.Error())
+		}
+	}
+
+	for name, err := range s.attributeRegistry.Validate(profile.Attributes) {
+		violate("attributes."+name, err.Error())
+	}
+
+	for field, visibility := range profile.Privacy {
+		if err := model.CheckVisibility(field, visibility, s.attributeRegistry); err != nil {
+			violate("privacy."+field, err.Error())
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		return violations[i].Field < violations[j].Field
+	})
+	return violations
+}
@@ -0,0 +1,45 @@
+package profile
+
+import (
+	"testing"
+	"time"
+	"user-server/model"
+)
+
+func TestProfileService_ValidateProfile(t *testing.T) {
+	r, _ := model.NewAttributeRegistry(nil)
+	s := &ProfileService{attributeRegistry: r}
+
+	valid := &model.Profile{
+		Username:  "lgk_1",
+		Birthday:  "2000-02-29",
+		AvatarUrl: "https://example.com/a.png",
+		Locale:    "zh-hant-tw",
+		Timezone:  "Asia/Taipei",
+	}
+	if violations := s.ValidateProfile(valid); len(violations) != 0 {
+		t.Errorf("expect valid, got violations: %v", violations)
+	}
+	if valid.Locale != "zh-Hant-TW" {
+		t.Errorf("expect canonical locale, got %v", valid.Locale)
+	}
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(model.BIRTHDAY_LAYOUT)
+	invalids := map[string]*model.Profile{
+		"unparseable birthday": {Birthday: "123"},
+		"future birthday":      {Birthday: tomorrow},
+		"short username":       {Username: "ab"},
+		"username charset":     {Username: "l g k"},
+		"avatar scheme":        {AvatarUrl: "javascript:alert(1)"},
+		"undefined attribute":  {Attributes: map[string]any{"undefined": "x"}},
+		"unknown visibility":   {Privacy: map[string]string{"email": "everyone"}},
+		"invalid locale":       {Locale: "english!"},
+		"unknown timezone":     {Timezone: "Mars/Olympus_Mons"},
+		"local timezone":       {Timezone: "Local"},
+	}
+	for name, p := range invalids {
+		if violations := s.ValidateProfile(p); len(violations) != 1 {
+			t.Errorf("%v: expect 1 violation, got %v", name, violations)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package profile
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	errs "errs"
+	"user-server/dao"
+	"user-server/model"
+)
+
+// ChangeUsername changes the username of a user at most once per cooldown.
+// The previous username is reserved for the user, and lookups of it are redirected to the user.
+func (s *ProfileService) ChangeUsername(ctx context.Context, userId uint64, username string) error {
+	s.logger.Info(ctx, "Call ProfileService.ChangeUsername, username: ", username)
+	if username == "" {
+		return errs.NewWithViolations(errs.ERR_INVALID_PROFILE, []*errs.Violation{{Field: "username", Reason: "should not be empty"}})
+	}
+	violations := s.ValidateProfile(&model.Profile{Username: username})
+	if len(violations) > 0 {
+		s.logger.Error(ctx, "Invalid username, violations: ", violations)
+		return errs.NewWithViolations(errs.ERR_INVALID_PROFILE, violations)
+	}
+	err := s.profileDao.ChangeUsername(ctx, userId, username, s.usernamePolicy)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return errs.New(errs.ERR_PROFILE_NOT_FOUND)
+	case errors.Is(err, dao.ErrUsernameTaken):
+		return errs.New(errs.ERR_USERNAME_TAKEN)
+	case errors.Is(err, dao.ErrUsernameCooldown):
+		return errs.New(errs.ERR_USERNAME_CHANGE_COOLDOWN)
+	default:
+		s.logger.Error(ctx, "Fail to change username, err:", err.Error())
+		return newWriteError(errs.ERR_CHANGE_USERNAME_FAILED, err)
+	}
+}
+
+// CheckUsernameAvailable checks that the username is neither used nor reserved by users other than userId.
+// userId is 0 for a user being registered. A failed lookup is returned with failedCode, the code of the caller.
+func (s *ProfileService) CheckUsernameAvailable(ctx context.Context, userId uint64, username string, failedCode int32) error {
+	if username == "" {
+		return nil
+	}
+	taken, err := s.profileDao.IsUsernameTaken(ctx, userId, username)
+	if err != nil {
+		s.logger.Error(ctx, "Fail to check username, err:", err.Error())
+		return errs.NewFromErr(failedCode, err)
+	}
+	if taken {
+		s.logger.Error(ctx, "Username is taken, username: ", username)
+		return errs.New(errs.ERR_USERNAME_TAKEN)
+	}
+	return nil
+}
+
+// checkUsername checks the username set by creating or updating a profile. A user without a username can set one
+// freely, but an existing username can only be changed by ChangeUsername, which applies the cooldown and the reservation.
+// Failed lookups are returned with failedCode.
+func (s *ProfileService) checkUsername(ctx context.Context, userId uint64, username string, failedCode int32) error {
+	if username == "" {
+		return nil
+	}
+	current, err := s.profileDao.GetProfileById(ctx, userId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logger.Error(ctx, "Fail to get profile, err:", err.Error())
+		return errs.NewFromErr(failedCode, err)
+	}
+	if current != nil && current.Username == username {
+		return nil
+	}
+	if current != nil && current.Username != "" {
+		return errUsernameSet()
+	}
+	return s.CheckUsernameAvailable(ctx, userId, username, failedCode)
+}
+
+// usernameWriteError maps the username errors of a profile write, which are checked again in the transaction
+// since checkUsername may race with other writes. nil is returned for other errors.
+func usernameWriteError(err error) error {
+	switch {
+	case errors.Is(err, dao.ErrUsernameTaken):
+		return errs.New(errs.ERR_USERNAME_TAKEN)
+	case errors.Is(err, dao.ErrUsernameSet):
+		return errUsernameSet()
+	default:
+		return nil
+	}
+}
+
+func errUsernameSet() error {
+	return errs.NewWithViolations(errs.ERR_INVALID_PROFILE, []*errs.Violation{
+		{Field: "username", Reason: "can only be changed by changing username"},
+	})
+}
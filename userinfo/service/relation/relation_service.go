@@ -0,0 +1,197 @@
+package relation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	errs "errs"
+	"loggers"
+	"user-server/dao"
+	"user-server/model"
+)
+
+const (
+	RELATION_PAGE_SIZE_DEFAULT = 20
+	RELATION_PAGE_SIZE_MAX     = 100
+)
+
+const (
+	LIST_FOLLOWERS       = "followers"
+	LIST_FOLLOWING       = "following"
+	LIST_FRIENDS         = "friends"
+	LIST_FRIEND_REQUESTS = "friend_requests"
+)
+
+type RelationService struct {
+	relationDao dao.RelationRepository
+	userDao     dao.UserRepository
+	logger      *logger.Logger
+}
+
+func NewRelationService(relationDao dao.RelationRepository, userDao dao.UserRepository, logger *logger.Logger) *RelationService {
+	return &RelationService{
+		relationDao: relationDao,
+		userDao:     userDao,
+		logger:      logger,
+	}
+}
+
+func (s *RelationService) Follow(ctx context.Context, userId uint64, targetId uint64) error {
+	s.logger.Info(ctx, "Call RelationService.Follow, target_id: ", targetId)
+	if err := s.checkTarget(ctx, userId, targetId); err != nil {
+		return err
+	}
+	return s.toRelationError(ctx, s.relationDao.Follow(ctx, userId, targetId))
+}
+
+func (s *RelationService) Unfollow(ctx context.Context, userId uint64, targetId uint64) error {
+	s.logger.Info(ctx, "Call RelationService.Unfollow, target_id: ", targetId)
+	if userId == targetId {
+		return errs.New(errs.ERR_INVALID_RELATION)
+	}
+	return s.toRelationError(ctx, s.relationDao.Unfollow(ctx, userId, targetId))
+}
+
+func (s *RelationService) RequestFriend(ctx context.Context, userId uint64, targetId uint64) error {
+	s.logger.Info(ctx, "Call RelationService.RequestFriend, target_id: ", targetId)
+	if err := s.checkTarget(ctx, userId, targetId); err != nil {
+		return err
+	}
+	return s.toRelationError(ctx, s.relationDao.RequestFriend(ctx, userId, targetId))
+}
+
+func (s *RelationService) AcceptFriend(ctx context.Context, userId uint64, fromUserId uint64) error {
+	s.logger.Info(ctx, "Call RelationService.AcceptFriend, from_user_id: ", fromUserId)
+	return s.toRelationError(ctx, s.relationDao.AcceptFriend(ctx, userId, fromUserId))
+}
+
+func (s *RelationService) RejectFriend(ctx context.Context, userId uint64, fromUserId uint64) error {
+	s.logger.Info(ctx, "Call RelationService.RejectFriend, from_user_id: ", fromUserId)
+	return s.toRelationError(ctx, s.relationDao.RejectFriend(ctx, userId, fromUserId))
+}
+
+func (s *RelationService) Block(ctx context.Context, userId uint64, targetId uint64) error {
+	s.logger.Info(ctx, "Call RelationService.Block, target_id: ", targetId)
+	if err := s.checkTarget(ctx, userId, targetId); err != nil {
+		return err
+	}
+	return s.toRelationError(ctx, s.relationDao.Block(ctx, userId, targetId))
+}
+
+func (s *RelationService) Unblock(ctx context.Context, userId uint64, targetId uint64) error {
+	s.logger.Info(ctx, "Call RelationService.Unblock, target_id: ", targetId)
+	if userId == targetId {
+		return errs.New(errs.ERR_INVALID_RELATION)
+	}
+	return s.toRelationError(ctx, s.relationDao.Unblock(ctx, userId, targetId))
+}
+
+// ListRelations returns a page of the related users of userId, newest first, together with the total number of them.
+// The returned cursor is for the next page, 0 means there are no more. A viewer blocked by userId can not list them,
+// viewerId 0 means the viewer is not logged in.
+func (s *RelationService) ListRelations(ctx context.Context, list string, userId uint64, viewerId uint64, beforeId uint64,
+	limit int) ([]uint64, uint64, uint64, error) {
+	s.logger.Info(ctx, "Call RelationService.ListRelations, list: ", list)
+	if viewerId != 0 && viewerId != userId {
+		blocked, err := s.relationDao.IsBlocked(ctx, userId, viewerId)
+		if err != nil {
+			s.logger.Error(ctx, "Fail to check block, err:", err.Error())
+			return nil, 0, 0, errs.NewFromErr(errs.ERR_LIST_RELATIONS_FAILED, err)
+		}
+		if blocked {
+			s.logger.Error(ctx, "Viewer is blocked, viewer_id: ", viewerId)
+			return nil, 0, 0, errs.New(errs.ERR_RELATION_BLOCKED)
+		}
+	}
+	if limit <= 0 {
+		limit = RELATION_PAGE_SIZE_DEFAULT
+	}
+	if limit > RELATION_PAGE_SIZE_MAX {
+		limit = RELATION_PAGE_SIZE_MAX
+	}
+	counts, err := s.GetCounts(ctx, userId)
+	if err != nil {
+		return nil, 0, 0, errs.New(errs.ERR_LIST_RELATIONS_FAILED)
+	}
+
+	var relations []*model.Relation
+	var total uint64
+	// fetch one more to know whether there is a next page.
+	switch list {
+	case LIST_FOLLOWERS:
+		relations, err = s.relationDao.ListFollowers(ctx, userId, beforeId, limit+1)
+		total = counts.Followers
+	case LIST_FOLLOWING:
+		relations, err = s.relationDao.ListFollowing(ctx, userId, beforeId, limit+1)
+		total = counts.Following
+	case LIST_FRIENDS:
+		relations, err = s.relationDao.ListFriends(ctx, userId, beforeId, limit+1)
+		total = counts.Friends
+	case LIST_FRIEND_REQUESTS:
+		relations, err = s.relationDao.ListFriendRequests(ctx, userId, beforeId, limit+1)
+		total = counts.FriendRequests
+	default:
+		return nil, 0, 0, errs.New(errs.ERR_LIST_RELATIONS_REQUEST)
+	}
+	if err != nil {
+		s.logger.Error(ctx, "Fail to list relations, err:", err.Error())
+		return nil, 0, 0, errs.NewFromErr(errs.ERR_LIST_RELATIONS_FAILED, err)
+	}
+
+	var next uint64
+	if len(relations) > limit {
+		relations = relations[:limit]
+		next = relations[limit-1].Id
+	}
+	userIds := make([]uint64, 0, len(relations))
+	for _, relation := range relations {
+		// followers and friend requests point to userId, the others point from userId.
+		if list == LIST_FOLLOWERS || list == LIST_FRIEND_REQUESTS {
+			userIds = append(userIds, relation.UserId)
+		} else {
+			userIds = append(userIds, relation.TargetId)
+		}
+	}
+	return userIds, next, total, nil
+}
+
+func (s *RelationService) GetCounts(ctx context.Context, userId uint64) (*model.RelationCounts, error) {
+	s.logger.Info(ctx, "Call RelationService.GetCounts")
+	counts, err := s.relationDao.GetCounts(ctx, userId)
+	if err != nil {
+		s.logger.Error(ctx, "Fail to get relation counts, err:", err.Error())
+		return nil, errs.NewFromErr(errs.ERR_GET_RELATION_COUNTS_FAILED, err)
+	}
+	return counts, nil
+}
+
+// checkTarget checks the target of a new relation is another existing user.
+func (s *RelationService) checkTarget(ctx context.Context, userId uint64, targetId uint64) error {
+	if targetId == 0 || userId == targetId {
+		s.logger.Error(ctx, "Invalid relation target, target_id: ", targetId)
+		return errs.New(errs.ERR_INVALID_RELATION)
+	}
+	_, err := s.userDao.GetUserById(ctx, targetId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errs.New(errs.ERR_RELATION_USER_NOT_FOUND)
+	}
+	if err != nil {
+		s.logger.Error(ctx, "Fail to get target user, err:", err.Error())
+		return errs.NewFromErr(errs.ERR_UPDATE_RELATION_FAILED, err)
+	}
+	return nil
+}
+
+func (s *RelationService) toRelationError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, dao.ErrRelationBlocked) {
+		return errs.New(errs.ERR_RELATION_BLOCKED)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return errs.New(errs.ERR_FRIEND_REQUEST_NOT_FOUND)
+	}
+	s.logger.Error(ctx, "Fail to update relation, err:", err.Error())
+	return errs.NewFromErr(errs.ERR_UPDATE_RELATION_FAILED, err)
+}
@@ -0,0 +1,101 @@
+package relation
+
+import (
+	"context"
+	errs "errs"
+	"fmt"
+	"github.com/asim/go-micro/v3/errors"
+	"loggers"
+	"os"
+	"testing"
+	"user-server/dao"
+	"user-server/model"
+)
+
+// newTestLogger creates a logger writing to a temporary directory instead of the directory of the package.
+func newTestLogger(t *testing.T) *logger.Logger {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("get working directory failed, err: %v", err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("change working directory failed, err: %v", err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+	return logger.NewLogger()
+}
+
+// newTestRelationService creates the service on the memory repositories with the users 1, 2 and 3.
+func newTestRelationService(t *testing.T) *RelationService {
+	db := dao.NewMemoryDB()
+	userDao := dao.NewMemoryUserDao(db)
+	for i := 1; i <= 3; i++ {
+		email := fmt.Sprintf("user%d@example.com", i)
+		if _, err := userDao.InsertWithProfile(context.Background(), &model.User{Email: email}, &model.Profile{Email: email}); err != nil {
+			t.Fatalf("insert user failed, err: %v", err)
+		}
+	}
+	return NewRelationService(dao.NewMemoryRelationDao(db), userDao, newTestLogger(t))
+}
+
+func expectCode(t *testing.T, err error, code int32) {
+	t.Helper()
+	if e := errors.FromError(err); err == nil || e.Code != code {
+		t.Errorf("expect code %v, got %v", code, err)
+	}
+}
+
+func TestRelationService_Update(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRelationService(t)
+	expectCode(t, s.Follow(ctx, 1, 1), errs.ERR_INVALID_RELATION)
+	expectCode(t, s.Follow(ctx, 1, 4), errs.ERR_RELATION_USER_NOT_FOUND)
+	expectCode(t, s.AcceptFriend(ctx, 1, 2), errs.ERR_FRIEND_REQUEST_NOT_FOUND)
+	if err := s.Follow(ctx, 1, 2); err != nil {
+		t.Fatalf("follow failed, err: %v", err)
+	}
+	if err := s.Block(ctx, 2, 1); err != nil {
+		t.Fatalf("block failed, err: %v", err)
+	}
+	expectCode(t, s.Follow(ctx, 1, 2), errs.ERR_RELATION_BLOCKED)
+	expectCode(t, s.RequestFriend(ctx, 2, 1), errs.ERR_RELATION_BLOCKED)
+	counts, err := s.GetCounts(ctx, 2)
+	if err != nil || counts.Followers != 0 {
+		t.Errorf("expect the follow removed by the block, got %+v, err: %v", counts, err)
+	}
+}
+
+func TestRelationService_ListRelations(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRelationService(t)
+	for _, userId := range []uint64{1, 3} {
+		if err := s.Follow(ctx, userId, 2); err != nil {
+			t.Fatalf("follow failed, err: %v", err)
+		}
+	}
+
+	userIds, next, total, err := s.ListRelations(ctx, LIST_FOLLOWERS, 2, 1, 0, 1)
+	if err != nil || len(userIds) != 1 || userIds[0] != 3 || next == 0 || total != 2 {
+		t.Fatalf("expect follower 3 of 2 with a next page, got %v, next: %v, total: %v, err: %v", userIds, next, total, err)
+	}
+	userIds, next, _, err = s.ListRelations(ctx, LIST_FOLLOWERS, 2, 1, next, 1)
+	if err != nil || len(userIds) != 1 || userIds[0] != 1 || next != 0 {
+		t.Fatalf("expect follower 1 on the last page, got %v, next: %v, err: %v", userIds, next, err)
+	}
+	_, _, _, err = s.ListRelations(ctx, "unknown", 2, 1, 0, 0)
+	expectCode(t, err, errs.ERR_LIST_RELATIONS_REQUEST)
+
+	// a viewer blocked by the user can not list the relations of the user, the user and the others can.
+	if err = s.Block(ctx, 2, 1); err != nil {
+		t.Fatalf("block failed, err: %v", err)
+	}
+	for _, list := range []string{LIST_FOLLOWERS, LIST_FOLLOWING, LIST_FRIENDS} {
+		_, _, _, err = s.ListRelations(ctx, list, 2, 1, 0, 0)
+		expectCode(t, err, errs.ERR_RELATION_BLOCKED)
+	}
+	for _, viewerId := range []uint64{0, 2, 3} {
+		if userIds, _, _, err = s.ListRelations(ctx, LIST_FOLLOWERS, 2, viewerId, 0, 0); err != nil || len(userIds) != 1 {
+			t.Errorf("expect follower 3 listed by %v, got %v, err: %v", viewerId, userIds, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"loggers"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"user-server/cache"
+	"user-server/conf"
+	"user-server/dao"
+)
+
+const (
+	WARM_CACHE_BATCH_DEFAULT = 500
+	WARM_CACHE_RATE_DEFAULT  = 2000
+)
+
+// warmCache streams the profiles from mysql-slave to the cache in batches, e.g. after the redis cluster is flushed.
+// The id of the last warmed profile is saved to the cursor file after each batch, and -resume continues from it.
+// If sharded, -shard is required and the shards are warmed one by one, each with its own cursor file.
+//
+//	userinfo warmcache -config=conf/userinfo.yaml -batch=500 -rate=2000 -resume
+//	userinfo warmcache -config=conf/userinfo.yaml -shard=shard0 -cursor=warmcache.shard0.cursor
+func warmCache(args []string) error {
+	flags := flag.NewFlagSet("warmcache", flag.ExitOnError)
+	confPath := flags.String("config", "conf/userinfo.yaml", "define config file")
+	batch := flags.Int("batch", WARM_CACHE_BATCH_DEFAULT, "profiles per batch")
+	rate := flags.Int("rate", WARM_CACHE_RATE_DEFAULT, "max profiles per second, 0 means unlimited")
+	cursorPath := flags.String("cursor", "warmcache.cursor", "file to save the progress")
+	resume := flags.Bool("resume", false, "continue from the progress in the cursor file")
+	shardName := flags.String("shard", "", "warm the profiles of the shard instead of those in mysql-slaves")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *batch <= 0 {
+		*batch = WARM_CACHE_BATCH_DEFAULT
+	}
+
+	config, err := conf.LoadConfig(*confPath)
+	if err != nil {
+		log.Println("load config file error, err: ", err)
+		return err
+	}
+	var dbSlave *dao.DBSlave
+	if *shardName == "" && config.Sharding != nil && len(config.Sharding.Shards) > 0 {
+		// mysql-slaves keep no profiles once sharded.
+		return fmt.Errorf("sharding is configured, warm each shard with -shard")
+	}
+	if *shardName != "" {
+		var shardConf *conf.Shard
+		if shardConf, err = findShardConf(config, *shardName); err != nil {
+			return err
+		}
+		dbSlave, err = openReplicaSet(shardConf.MysqlMaster, shardConf.MysqlSlaves)
+	} else {
+		dbSlave, err = openReplicas(config)
+	}
+	if err != nil {
+		log.Println("init sqlDB slave failed, err: ", err.Error())
+		return err
+	}
+	defer dbSlave.Close()
+	dbCache, err := newCache(config.Redis)
+	if err != nil {
+		log.Println("init cache failed, err: ", err.Error())
+		return err
+	}
+	cachePolicy := newCachePolicy(config.Cache)
+	if !cachePolicy.Profile.Enabled {
+		log.Println("profile cache is disabled, nothing to warm.")
+		return nil
+	}
+	lgr := logger.NewLogger()
+	// only mysql-slave and the cache are used to warm.
+	profileDao := dao.NewProfileDao(nil, dbSlave, dbCache, cache.NewLoader(dbCache, 0), nil, cachePolicy,
+		nil, nil, lgr)
+
+	var afterId uint64
+	if *resume {
+		afterId, err = readWarmCacheCursor(*cursorPath)
+		if err != nil {
+			log.Println("read cursor failed, err: ", err.Error())
+			return err
+		}
+	}
+	ctx := context.Background()
+	total, err := profileDao.CountProfiles(ctx, afterId)
+	if err != nil {
+		return err
+	}
+	log.Printf("warm cache started, profiles to warm: %v, after id: %v", total, afterId)
+
+	start := time.Now()
+	var warmed int
+	for {
+		batchStart := time.Now()
+		lastId, n, err := profileDao.WarmCache(ctx, afterId, *batch)
+		warmed += n
+		afterId = lastId
+		if saveErr := os.WriteFile(*cursorPath, []byte(strconv.FormatUint(afterId, 10)), 0644); saveErr != nil {
+			log.Println("save cursor failed, err: ", saveErr.Error())
+		}
+		if err != nil {
+			log.Printf("warm cache failed at id %v, resume with -resume, err: %v", afterId, err)
+			return err
+		}
+		elapsed := time.Since(start)
+		log.Printf("warmed %v/%v profiles (%.1f%%), last id: %v, elapsed: %v, %.0f profiles/s",
+			warmed, total, percent(warmed, total), afterId, elapsed.Round(time.Second), float64(warmed)/elapsed.Seconds())
+		if n < *batch {
+			break
+		}
+		// sleep out the rest of the time the batch is allowed to take.
+		if *rate > 0 {
+			time.Sleep(time.Duration(n)*time.Second/time.Duration(*rate) - time.Since(batchStart))
+		}
+	}
+	log.Printf("warm cache done, profiles: %v, elapsed: %v", warmed, time.Since(start).Round(time.Second))
+	return nil
+}
+
+func readWarmCacheCursor(cursorPath string) (uint64, error) {
+	data, err := os.ReadFile(cursorPath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func percent(n int, total uint64) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(n) * 100 / float64(total)
+}
@@ -0,0 +1,49 @@
+package wire
+
+import (
+	"github.com/google/wire"
+	"user-server/biz/account"
+	"user-server/biz/profile"
+	"user-server/biz/relation"
+	"user-server/dao"
+	"user-server/handler"
+	account2 "user-server/service/account"
+	profile2 "user-server/service/profile"
+	relation2 "user-server/service/relation"
+)
+
+// DaoSet provides the repositories on sql DB and cache.
+var DaoSet = wire.NewSet(
+	dao.NewProfileDao, dao.NewProfileHistoryDao, dao.NewUserDao, dao.NewRelationDao,
+	wire.Bind(new(dao.ProfileRepository), new(*dao.ProfileDao)),
+	wire.Bind(new(dao.ProfileHistoryRepository), new(*dao.ProfileHistoryDao)),
+	wire.Bind(new(dao.UserRepository), new(*dao.UserDao)),
+	wire.Bind(new(dao.RelationRepository), new(*dao.RelationDao)),
+)
+
+// ShardedDaoSet provides the repositories of users and profiles on the shards of dao.ShardMap, and the relations on
+// the global sql DB.
+var ShardedDaoSet = wire.NewSet(
+	dao.NewShardedProfileDao, dao.NewShardedProfileHistoryDao, dao.NewShardedUserDao, dao.NewRelationDao,
+	wire.Bind(new(dao.ProfileRepository), new(*dao.ShardedProfileDao)),
+	wire.Bind(new(dao.ProfileHistoryRepository), new(*dao.ShardedProfileHistoryDao)),
+	wire.Bind(new(dao.UserRepository), new(*dao.ShardedUserDao)),
+	wire.Bind(new(dao.RelationRepository), new(*dao.RelationDao)),
+)
+
+// MemoryDaoSet provides the repositories on a dao.MemoryDB, so that the handler can be built without external services.
+var MemoryDaoSet = wire.NewSet(
+	dao.NewMemoryProfileDao, dao.NewMemoryProfileHistoryDao, dao.NewMemoryUserDao, dao.NewMemoryRelationDao,
+	wire.Bind(new(dao.ProfileRepository), new(*dao.MemoryProfileDao)),
+	wire.Bind(new(dao.ProfileHistoryRepository), new(*dao.MemoryProfileHistoryDao)),
+	wire.Bind(new(dao.UserRepository), new(*dao.MemoryUserDao)),
+	wire.Bind(new(dao.RelationRepository), new(*dao.MemoryRelationDao)),
+)
+
+// HandlerSet provides the handler with its bizs and services on the repositories.
+var HandlerSet = wire.NewSet(
+	profile.NewProfileBiz, profile2.NewProfileService,
+	account.NewAccountBiz, account2.NewAccountService,
+	relation.NewRelationBiz, relation2.NewRelationService,
+	handler.NewUserinfoHandlerImpl,
+)
@@ -5,17 +5,30 @@ package wire
 
 import (
 	"github.com/google/wire"
-	"github.com/redis/go-redis/v9"
 	"loggers"
-	"user-server/biz/account"
-	"user-server/biz/profile"
+	"user-server/cache"
 	"user-server/dao"
 	"user-server/handler"
-	account2 "user-server/service/account"
-	profile2 "user-server/service/profile"
+	"user-server/model"
+	"user-server/service/outbox"
 )
 
-func InitUserinfoHandler(*dao.DBMaster, *dao.DBSlave, *redis.ClusterClient, *logger.Logger) *handler.UserinfoHandlerImpl {
-	wire.Build(dao.NewProfileDao, profile.NewProfileBiz, profile2.NewProfileService, account.NewAccountBiz, account2.NewAccountService, dao.NewUserDao, handler.NewUserinfoHandlerImpl)
+func InitUserinfoHandler(*dao.DBMaster, *dao.DBSlave, *dao.DBRouter, cache.Cache, *cache.Loader, *cache.Local, *cache.Policy, *dao.Timeouts, *model.AttributeRegistry, *model.UsernamePolicy, *logger.Logger) *handler.UserinfoHandlerImpl {
+	wire.Build(DaoSet, HandlerSet)
 	return &handler.UserinfoHandlerImpl{}
 }
+
+func InitShardedUserinfoHandler(*dao.ShardMap, *dao.DBMaster, *dao.DBSlave, *dao.DBRouter, cache.Cache, *cache.Loader, *cache.Local, *cache.Policy, *dao.Timeouts, *model.AttributeRegistry, *model.UsernamePolicy, *logger.Logger) *handler.UserinfoHandlerImpl {
+	wire.Build(ShardedDaoSet, HandlerSet)
+	return &handler.UserinfoHandlerImpl{}
+}
+
+func InitMemoryUserinfoHandler(*dao.MemoryDB, *model.AttributeRegistry, *model.UsernamePolicy, *logger.Logger) *handler.UserinfoHandlerImpl {
+	wire.Build(MemoryDaoSet, HandlerSet)
+	return &handler.UserinfoHandlerImpl{}
+}
+
+func InitCacheOutboxRelay(*dao.DBMaster, cache.Cache, *cache.Local, *cache.Policy, *dao.Timeouts, *logger.Logger) *outbox.CacheOutboxRelay {
+	wire.Build(dao.NewCacheOutboxDao, outbox.NewCacheOutboxRelay)
+	return &outbox.CacheOutboxRelay{}
+}
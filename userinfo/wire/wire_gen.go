@@ -7,25 +7,69 @@
 package wire
 
 import (
-	"github.com/redis/go-redis/v9"
 	"loggers"
 	account2 "user-server/biz/account"
 	profile2 "user-server/biz/profile"
+	relation2 "user-server/biz/relation"
+	"user-server/cache"
 	"user-server/dao"
 	"user-server/handler"
+	"user-server/model"
 	"user-server/service/account"
+	"user-server/service/outbox"
 	"user-server/service/profile"
+	"user-server/service/relation"
 )
 
 // Injectors from wire.go:
 
-func InitUserinfoHandler(dbMaster *dao.DBMaster, dbSlave *dao.DBSlave, clusterClient *redis.ClusterClient, loggerLogger *logger.Logger) *handler.UserinfoHandlerImpl {
-	profileDao := dao.NewProfileDao(dbMaster, dbSlave, clusterClient, loggerLogger)
-	profileService := profile.NewProfileService(profileDao, loggerLogger)
+func InitUserinfoHandler(dbMaster *dao.DBMaster, dbSlave *dao.DBSlave, dbRouter *dao.DBRouter, cacheCache cache.Cache, loader *cache.Loader, local *cache.Local, policy *cache.Policy, timeouts *dao.Timeouts, attributeRegistry *model.AttributeRegistry, usernamePolicy *model.UsernamePolicy, loggerLogger *logger.Logger) *handler.UserinfoHandlerImpl {
+	profileDao := dao.NewProfileDao(dbMaster, dbSlave, cacheCache, loader, local, policy, dbRouter, timeouts, loggerLogger)
+	profileHistoryDao := dao.NewProfileHistoryDao(dbRouter, timeouts, loggerLogger)
+	profileService := profile.NewProfileService(profileDao, profileHistoryDao, attributeRegistry, usernamePolicy, loggerLogger)
 	profileBiz := profile2.NewProfileBiz(profileService, loggerLogger)
-	userDao := dao.NewUserDao(dbMaster, loggerLogger)
-	accountService := account.NewAccountService(userDao, loggerLogger)
+	userDao := dao.NewUserDao(dbMaster, timeouts, loggerLogger)
+	accountService := account.NewAccountService(userDao, profileService, loggerLogger)
 	accountBiz := account2.NewAccountBiz(accountService, loggerLogger)
-	userinfoHandlerImpl := handler.NewUserinfoHandlerImpl(profileBiz, accountBiz)
+	relationDao := dao.NewRelationDao(dbMaster, dbSlave, cacheCache, policy, dbRouter, timeouts, loggerLogger)
+	relationService := relation.NewRelationService(relationDao, userDao, loggerLogger)
+	relationBiz := relation2.NewRelationBiz(relationService, loggerLogger)
+	userinfoHandlerImpl := handler.NewUserinfoHandlerImpl(profileBiz, accountBiz, relationBiz)
 	return userinfoHandlerImpl
 }
+
+func InitShardedUserinfoHandler(shardMap *dao.ShardMap, dbMaster *dao.DBMaster, dbSlave *dao.DBSlave, dbRouter *dao.DBRouter, cacheCache cache.Cache, loader *cache.Loader, local *cache.Local, policy *cache.Policy, timeouts *dao.Timeouts, attributeRegistry *model.AttributeRegistry, usernamePolicy *model.UsernamePolicy, loggerLogger *logger.Logger) *handler.UserinfoHandlerImpl {
+	shardedProfileDao := dao.NewShardedProfileDao(shardMap, cacheCache, loader, local, policy, timeouts, loggerLogger)
+	shardedProfileHistoryDao := dao.NewShardedProfileHistoryDao(shardMap, timeouts, loggerLogger)
+	profileService := profile.NewProfileService(shardedProfileDao, shardedProfileHistoryDao, attributeRegistry, usernamePolicy, loggerLogger)
+	profileBiz := profile2.NewProfileBiz(profileService, loggerLogger)
+	shardedUserDao := dao.NewShardedUserDao(shardMap, cacheCache, timeouts, loggerLogger)
+	accountService := account.NewAccountService(shardedUserDao, profileService, loggerLogger)
+	accountBiz := account2.NewAccountBiz(accountService, loggerLogger)
+	relationDao := dao.NewRelationDao(dbMaster, dbSlave, cacheCache, policy, dbRouter, timeouts, loggerLogger)
+	relationService := relation.NewRelationService(relationDao, shardedUserDao, loggerLogger)
+	relationBiz := relation2.NewRelationBiz(relationService, loggerLogger)
+	userinfoHandlerImpl := handler.NewUserinfoHandlerImpl(profileBiz, accountBiz, relationBiz)
+	return userinfoHandlerImpl
+}
+
+func InitMemoryUserinfoHandler(memoryDB *dao.MemoryDB, attributeRegistry *model.AttributeRegistry, usernamePolicy *model.UsernamePolicy, loggerLogger *logger.Logger) *handler.UserinfoHandlerImpl {
+	memoryProfileDao := dao.NewMemoryProfileDao(memoryDB)
+	memoryProfileHistoryDao := dao.NewMemoryProfileHistoryDao(memoryDB)
+	profileService := profile.NewProfileService(memoryProfileDao, memoryProfileHistoryDao, attributeRegistry, usernamePolicy, loggerLogger)
+	profileBiz := profile2.NewProfileBiz(profileService, loggerLogger)
+	memoryUserDao := dao.NewMemoryUserDao(memoryDB)
+	accountService := account.NewAccountService(memoryUserDao, profileService, loggerLogger)
+	accountBiz := account2.NewAccountBiz(accountService, loggerLogger)
+	memoryRelationDao := dao.NewMemoryRelationDao(memoryDB)
+	relationService := relation.NewRelationService(memoryRelationDao, memoryUserDao, loggerLogger)
+	relationBiz := relation2.NewRelationBiz(relationService, loggerLogger)
+	userinfoHandlerImpl := handler.NewUserinfoHandlerImpl(profileBiz, accountBiz, relationBiz)
+	return userinfoHandlerImpl
+}
+
+func InitCacheOutboxRelay(dbMaster *dao.DBMaster, cacheCache cache.Cache, local *cache.Local, policy *cache.Policy, timeouts *dao.Timeouts, loggerLogger *logger.Logger) *outbox.CacheOutboxRelay {
+	cacheOutboxDao := dao.NewCacheOutboxDao(dbMaster, timeouts, loggerLogger)
+	cacheOutboxRelay := outbox.NewCacheOutboxRelay(cacheOutboxDao, cacheCache, local, policy, loggerLogger)
+	return cacheOutboxRelay
+}
@@ -0,0 +1,74 @@
+package wire
+
+import (
+	"context"
+	errs "errs"
+	"github.com/asim/go-micro/v3/errors"
+	"loggers"
+	"os"
+	"protos/userinfo"
+	"testing"
+	"user-server/dao"
+	"user-server/model"
+)
+
+// newTestLogger creates a logger writing to a temporary directory instead of the directory of the package.
+func newTestLogger(t *testing.T) *logger.Logger {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("get working directory failed, err: %v", err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("change working directory failed, err: %v", err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+	return logger.NewLogger()
+}
+
+func TestInitMemoryUserinfoHandler(t *testing.T) {
+	registry, err := model.NewAttributeRegistry(nil)
+	if err != nil {
+		t.Fatalf("create attribute registry failed, err: %v", err)
+	}
+	policy := &model.UsernamePolicy{ChangeCooldown: model.USERNAME_CHANGE_COOLDOWN_DEFAULT, ReservePeriod: model.USERNAME_RESERVE_PERIOD_DEFAULT}
+	h := InitMemoryUserinfoHandler(dao.NewMemoryDB(), registry, policy, newTestLogger(t))
+	ctx := context.Background()
+
+	for _, username := range []string{"alice", "bob"} {
+		err = h.Register(ctx, &userinfo.RegisterRequest{Email: username + "@example.com", Password: "secret", Username: username},
+			&userinfo.RegisterResponse{})
+		if err != nil {
+			t.Fatalf("register %v failed, err: %v", username, err)
+		}
+	}
+	err = h.Register(ctx, &userinfo.RegisterRequest{Email: "alice@example.com", Password: "secret"}, &userinfo.RegisterResponse{})
+	if e := errors.FromError(err); e.Code != errs.ERR_EMAIL_IS_REGISTERED {
+		t.Errorf("expect code %v registering twice, got %v", errs.ERR_EMAIL_IS_REGISTERED, err)
+	}
+
+	login := &userinfo.LoginResponse{}
+	if err = h.Login(ctx, &userinfo.LoginRequest{Email: "alice@example.com", Password: "secret"}, login); err != nil {
+		t.Fatalf("login failed, err: %v", err)
+	}
+	auth := &userinfo.AuthResponse{}
+	if err = h.Authenticate(ctx, &userinfo.AuthRequest{Token: login.Token}, auth); err != nil {
+		t.Fatalf("authenticate failed, err: %v", err)
+	}
+	profile := &userinfo.GetPublicProfileResponse{}
+	if err = h.GetPublicProfile(ctx, &userinfo.GetPublicProfileRequest{Username: "bob"}, profile); err != nil {
+		t.Fatalf("get profile of bob failed, err: %v", err)
+	}
+
+	bobId := profile.GetProfile().GetUserId()
+	if err = h.Follow(ctx, &userinfo.RelationRequest{UserId: auth.UserId, TargetId: bobId}, &userinfo.RelationResponse{}); err != nil {
+		t.Fatalf("follow failed, err: %v", err)
+	}
+	counts := &userinfo.GetRelationCountsResponse{}
+	if err = h.GetRelationCounts(ctx, &userinfo.GetRelationCountsRequest{UserId: bobId}, counts); err != nil || counts.GetCounts().GetFollowers() != 1 {
+		t.Errorf("expect 1 follower of bob, got %v, err: %v", counts.GetCounts(), err)
+	}
+	err = h.Follow(ctx, &userinfo.RelationRequest{UserId: auth.UserId, TargetId: bobId + 1}, &userinfo.RelationResponse{})
+	if e := errors.FromError(err); e.Code != errs.ERR_RELATION_USER_NOT_FOUND {
+		t.Errorf("expect code %v following a missing user, got %v", errs.ERR_RELATION_USER_NOT_FOUND, err)
+	}
+}